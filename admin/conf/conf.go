@@ -3,6 +3,7 @@ package conf
 import (
 	"flag"
 	"os"
+	"strconv"
 	
 	"github.com/yincongcyincong/MuseBot/logger"
 )
@@ -11,6 +12,9 @@ type BaseConfig struct {
 	DBType *string `json:"db_type"`
 	DBConf *string `json:"db_conf"`
 	
+	Migrate     *string `json:"migrate"`
+	AutoMigrate *bool   `json:"auto_migrate"`
+	
 	SessionKey *string `json:"session_key"`
 	
 	AdminPort *string `json:"admin_port"`
@@ -21,6 +25,8 @@ var BaseConfInfo = new(BaseConfig)
 func InitConfig() {
 	BaseConfInfo.DBType = flag.String("db_type", "sqlite3", "db type")
 	BaseConfInfo.DBConf = flag.String("db_conf", "./data/telegram_admin_bot.db", "db conf")
+	BaseConfInfo.Migrate = flag.String("migrate", "", "print db migrations and exit: status, up applies pending migrations first")
+	BaseConfInfo.AutoMigrate = flag.Bool("auto_migrate", true, "apply pending db migrations when start")
 	BaseConfInfo.SessionKey = flag.String("session_key", "telegram_bot_session_key", "session key")
 	BaseConfInfo.AdminPort = flag.String("admin_port", "18080", "admin port")
 	
@@ -34,6 +40,14 @@ func InitConfig() {
 		*BaseConfInfo.DBConf = os.Getenv("DB_CONF")
 	}
 	
+	if os.Getenv("MIGRATE") != "" {
+		*BaseConfInfo.Migrate = os.Getenv("MIGRATE")
+	}
+	
+	if os.Getenv("AUTO_MIGRATE") != "" {
+		*BaseConfInfo.AutoMigrate, _ = strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
+	}
+	
 	if os.Getenv("SESSION_KEY") != "" {
 		*BaseConfInfo.SessionKey = os.Getenv("SESSION_KEY")
 	}
@@ -44,6 +58,8 @@ func InitConfig() {
 	
 	logger.Info("CONF", "DBType", *BaseConfInfo.DBType)
 	logger.Info("CONF", "DBConf", *BaseConfInfo.DBConf)
+	logger.Info("CONF", "Migrate", *BaseConfInfo.Migrate)
+	logger.Info("CONF", "AutoMigrate", *BaseConfInfo.AutoMigrate)
 	logger.Info("CONF", "SessionKey", *BaseConfInfo.SessionKey)
 	logger.Info("CONF", "AdminPort", *BaseConfInfo.AdminPort)
 }
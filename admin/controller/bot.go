@@ -15,7 +15,6 @@ import (
 	"strings"
 	"time"
 	
-	mcpParam "github.com/yincongcyincong/mcp-client-go/clients/param"
 	"github.com/yincongcyincong/MuseBot/admin/checkpoint"
 	adminConf "github.com/yincongcyincong/MuseBot/admin/conf"
 	"github.com/yincongcyincong/MuseBot/admin/db"
@@ -24,6 +23,7 @@ import (
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
 	"github.com/yincongcyincong/MuseBot/utils"
+	mcpParam "github.com/yincongcyincong/mcp-client-go/clients/param"
 )
 
 type Bot struct {
@@ -291,6 +291,15 @@ func AddUserToken(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ForgetBotUser forward request which hard deletes all data of bot user
+func ForgetBotUser(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/user/forget")
+}
+
+func GetBotDeletionReceipts(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/user/forget/receipts")
+}
+
 func GetBotUser(w http.ResponseWriter, r *http.Request) {
 	botInfo, err := getBot(r)
 	if err != nil {
@@ -569,6 +578,90 @@ func DisableBotMCPConf(w http.ResponseWriter, r *http.Request) {
 	_, err = io.Copy(w, resp.Body)
 }
 
+func GetBotAgentConf(w http.ResponseWriter, r *http.Request) {
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.Error("get bot conf error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	resp, err := adminUtils.GetCrtClient(botInfo).Get(strings.TrimSuffix(botInfo.Address, "/") + "/agent/get")
+	if err != nil {
+		logger.Error("get bot agent conf error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.Error("copy response body error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
+func UpdateBotAgentConf(w http.ResponseWriter, r *http.Request) {
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.Error("get bot conf error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Error("read request body error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	name := r.URL.Query().Get("name")
+	req, err := http.NewRequest("POST", strings.TrimSuffix(botInfo.Address, "/")+"/agent/update?name="+url.QueryEscape(name), bytes.NewBuffer(body))
+	if err != nil {
+		logger.Error("Error creating request", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(req)
+	if err != nil {
+		logger.Error("update bot agent conf error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.Error("copy response body error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
+
+func DeleteBotAgentConf(w http.ResponseWriter, r *http.Request) {
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.Error("get bot conf error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	name := r.URL.Query().Get("name")
+	resp, err := adminUtils.GetCrtClient(botInfo).Get(strings.TrimSuffix(botInfo.Address, "/") + "/agent/delete?name=" + url.QueryEscape(name))
+	if err != nil {
+		logger.Error("delete bot agent conf error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+}
+
 func GetPrepareMCPServer(w http.ResponseWriter, r *http.Request) {
 	botInfo, err := getBot(r)
 	if err != nil {
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	
+	adminUtils "github.com/yincongcyincong/MuseBot/admin/utils"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func GetBotRagFiles(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/rag/file/list")
+}
+
+func UploadBotRagFile(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/rag/file/upload")
+}
+
+func DeleteBotRagFile(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/rag/file/delete")
+}
+
+func ReindexBotRagFile(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/rag/file/reindex")
+}
+
+func QueryBotRag(w http.ResponseWriter, r *http.Request) {
+	proxyBotRag(w, r, "/rag/query")
+}
+
+// proxyBotRag forward request to api of bot, query params except bot id and body are kept
+func proxyBotRag(w http.ResponseWriter, r *http.Request, path string) {
+	botInfo, err := getBot(r)
+	if err != nil {
+		logger.Error("get bot conf error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	query := r.URL.Query()
+	query.Del("id")
+	req, err := http.NewRequest(r.Method, strings.TrimSuffix(botInfo.Address, "/")+path+"?"+query.Encode(), r.Body)
+	if err != nil {
+		logger.Error("Error creating request", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	
+	resp, err := adminUtils.GetCrtClient(botInfo).Do(req)
+	if err != nil {
+		logger.Error("request bot rag api error", "path", path, "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		logger.Error("copy response body error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+}
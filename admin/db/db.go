@@ -2,26 +2,28 @@ package db
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
+	"io"
 	"os"
+	"time"
 	
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/yincongcyincong/MuseBot/admin/conf"
 	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
 const (
 	sqlite3CreateTableSQL = `
-			CREATE TABLE users (
+			CREATE TABLE IF NOT EXISTS users (
 				id INTEGER PRIMARY KEY AUTOINCREMENT,
 				username VARCHAR(255) NOT NULL DEFAULT '',
 				password VARCHAR(100) NOT NULL DEFAULT '',
 				create_time int(10) NOT NULL DEFAULT '0',
 				update_time int(10) NOT NULL DEFAULT '0'
 			);
-			CREATE TABLE bot (
+			CREATE TABLE IF NOT EXISTS bot (
 				id INTEGER PRIMARY KEY AUTOINCREMENT,
 				address VARCHAR(255) NOT NULL DEFAULT '',
 				name VARCHAR(255) NOT NULL DEFAULT '',
@@ -31,9 +33,8 @@ const (
 				create_time int(10) NOT NULL DEFAULT '0',
 				update_time int(10) NOT NULL DEFAULT '0',
 				is_deleted int(10) NOT NULL DEFAULT '0'
-			);
-			insert into users values(1, 'admin', '21232f297a57a5a743894a0e4a801fc3', strftime('%s','now'), strftime('%s','now'))`
-	
+			);`
+			
 	mysqlCreateUsersSQL = `
 			CREATE TABLE IF NOT EXISTS users (
 				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
@@ -42,19 +43,43 @@ const (
 				create_time int(10) NOT NULL DEFAULT '0',
 				update_time int(10) NOT NULL DEFAULT '0'
 			);`
-	
+			
 	mysqlCreateBotSQL = `
 			CREATE TABLE IF NOT EXISTS bot (
 				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
 				address VARCHAR(255) NOT NULL DEFAULT '',
-			    name VARCHAR(255) NOT NULL DEFAULT '',
+				name VARCHAR(255) NOT NULL DEFAULT '',
+				key_file TEXT NOT NULL,
 				crt_file TEXT NOT NULL,
-			    secret_file TEXT NOT NULL,
+				ca_file TEXT NOT NULL,
 				create_time int(10) NOT NULL DEFAULT '0',
 				update_time int(10) NOT NULL DEFAULT '0',
 				is_deleted int(10) NOT NULL DEFAULT '0'
 			);`
-	MysqlInsertAdmin = `insert into users values(1, 'admin', '21232f297a57a5a743894a0e4a801fc3', Now(), Now())`
+			
+	postgresCreateTableSQL = `
+			CREATE TABLE IF NOT EXISTS users (
+				id SERIAL PRIMARY KEY,
+				username VARCHAR(255) NOT NULL DEFAULT '',
+				password VARCHAR(100) NOT NULL DEFAULT '',
+				create_time BIGINT NOT NULL DEFAULT 0,
+				update_time BIGINT NOT NULL DEFAULT 0
+			);
+			CREATE TABLE IF NOT EXISTS bot (
+				id SERIAL PRIMARY KEY,
+				address VARCHAR(255) NOT NULL DEFAULT '',
+				name VARCHAR(255) NOT NULL DEFAULT '',
+				key_file TEXT NOT NULL,
+				crt_file TEXT NOT NULL,
+				ca_file TEXT NOT NULL,
+				create_time BIGINT NOT NULL DEFAULT 0,
+				update_time BIGINT NOT NULL DEFAULT 0,
+				is_deleted INT NOT NULL DEFAULT 0
+			);`
+			
+	// default admin user, password is admin
+	adminUsername = "admin"
+	adminPassword = "21232f297a57a5a743894a0e4a801fc3"
 )
 
 var (
@@ -73,82 +98,111 @@ func InitTable() {
 		logger.Info("✅ create direction success")
 	}
 	
-	DB, err = sql.Open(*conf.BaseConfInfo.DBType, *conf.BaseConfInfo.DBConf)
+	DB, err = utils.OpenDB(*conf.BaseConfInfo.DBType, *conf.BaseConfInfo.DBConf)
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
 	
-	// init table
-	switch *conf.BaseConfInfo.DBType {
-	case "sqlite3":
-		err = initializeSqlite3Table(DB, "users")
+	// migrations are applied by -migrate=up if auto migrate is disabled
+	if *conf.BaseConfInfo.Migrate == "" && *conf.BaseConfInfo.AutoMigrate {
+		count, err := RunMigrations()
 		if err != nil {
-			logger.Fatal("create sqlite table fail", "err", err)
-		}
-	case "mysql":
-		// 检查并创建表
-		if err := initializeMysqlTable(DB, "users", mysqlCreateUsersSQL); err != nil {
-			logger.Fatal("create mysql table fail", "err", err)
+			logger.Fatal("migrate db fail", "err", err)
 		}
-		
-		if err := initializeMysqlTable(DB, "bot", mysqlCreateBotSQL); err != nil {
-			logger.Fatal("create mysql table fail", "err", err)
-		}
-		
+		logger.Info("migrate db success", "applied", count)
 	}
 	
 	logger.Info("db initialize successfully")
 }
 
-func initializeMysqlTable(db *sql.DB, tableName string, createSQL string) error {
-	var tb string
-	query := fmt.Sprintf("SHOW TABLES LIKE '%s'", tableName)
-	err := db.QueryRow(query).Scan(&tb)
+// getMigrations get ordered migrations of dialect, new migration is appended with next version,
+// applied migrations must not be changed
+func getMigrations(dialect string) []*utils.Migration {
+	var createTables []string
+	switch dialect {
+	case utils.Sqlite3:
+		createTables = []string{sqlite3CreateTableSQL}
+	case utils.Mysql:
+		createTables = []string{mysqlCreateUsersSQL, mysqlCreateBotSQL}
+	case utils.Postgres:
+		createTables = []string{postgresCreateTableSQL}
+	default:
+		return nil
+	}
 	
-	if errors.Is(err, sql.ErrNoRows) || tb == "" {
-		logger.Info("Table not exist, creating...", "tableName", tableName)
-		_, err := db.Exec(createSQL)
-		if err != nil {
-			return fmt.Errorf("create table failed: %v", err)
-		}
-		logger.Info("Create table success", "tableName", tableName)
-		
-		if tableName == "users" {
-			_, err = db.Exec(MysqlInsertAdmin)
-			if err != nil {
-				logger.Fatal("Create index failed", "err", err)
-			}
-		}
-	} else if err != nil {
-		return fmt.Errorf("search table failed: %v", err)
-	} else {
-		logger.Info("Table exists", "tableName", tableName)
+	// mysql text column can't have default value
+	textType := "TEXT NOT NULL DEFAULT ''"
+	if dialect == utils.Mysql {
+		textType = "TEXT NOT NULL"
 	}
 	
-	return nil
+	return []*utils.Migration{
+		{
+			Version: 1,
+			Name:    "create_tables",
+			Up:      utils.ExecSQL(createTables...),
+		},
+		{
+			Version: 2,
+			Name:    "insert_admin_user",
+			Up:      insertAdminUser,
+		},
+		{
+			// bot table of mysql was created with secret_file instead of key_file and ca_file
+			Version: 3,
+			Name:    "fix_bot_cert_columns",
+			Up: utils.Migrations(
+				utils.AddColumn(dialect, "bot", "key_file", textType),
+				utils.AddColumn(dialect, "bot", "ca_file", textType),
+				copyBotSecretFile(dialect),
+				utils.DropColumn(dialect, "bot", "secret_file"),
+			),
+		},
+	}
 }
 
-// initializeSqlite3Table check table exist or not.
-func initializeSqlite3Table(db *sql.DB, tableName string) error {
-	// check table exist or not
-	query := `SELECT name FROM sqlite_master WHERE type='table' AND name=?;`
-	var name string
-	err := db.QueryRow(query, tableName).Scan(&name)
+// insertAdminUser insert default admin user into empty users table
+func insertAdminUser(db *sql.DB) error {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
 	
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Info("table not exist，creating...", "tableName", tableName)
-			_, err := db.Exec(sqlite3CreateTableSQL)
-			if err != nil {
-				return fmt.Errorf("create table fail: %v", err)
-			}
-			logger.Info("create table success")
-		} else {
-			return fmt.Errorf("search table fail: %v", err)
+	now := time.Now().Unix()
+	_, err = db.Exec(`INSERT INTO users (username, password, create_time, update_time) VALUES (?, ?, ?, ?)`,
+		adminUsername, adminPassword, now, now)
+	return err
+}
+
+// copyBotSecretFile keep key saved in secret_file before it is dropped
+func copyBotSecretFile(dialect string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		exist, err := utils.ColumnExist(db, dialect, "bot", "secret_file")
+		if err != nil || !exist {
+			return err
 		}
-	} else {
-		logger.Info("table exist", "tableName", tableName)
+		
+		_, err = db.Exec(`UPDATE bot SET key_file = secret_file WHERE key_file = ''`)
+		return err
+	}
+}
+
+// RunMigrations apply pending migrations of admin db
+func RunMigrations() (int, error) {
+	migrations := getMigrations(*conf.BaseConfInfo.DBType)
+	if migrations == nil {
+		return 0, fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
+	}
+	return utils.RunMigrations(DB, migrations)
+}
+
+// Migrate print migration status, pending migrations are applied first if action is up
+func Migrate(w io.Writer, action string) error {
+	migrations := getMigrations(*conf.BaseConfInfo.DBType)
+	if migrations == nil {
+		return fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
 	}
 	
-	return nil
+	return utils.Migrate(w, DB, migrations, action)
 }
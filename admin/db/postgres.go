@@ -0,0 +1,7 @@
+//go:build postgres
+
+package db
+
+import (
+	_ "github.com/lib/pq"
+)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	
 	"github.com/yincongcyincong/MuseBot/admin/checkpoint"
 	"github.com/yincongcyincong/MuseBot/admin/conf"
@@ -17,6 +18,9 @@ func main() {
 	conf.InitConfig()
 	controller.InitSessionStore()
 	db.InitTable()
+	if *conf.BaseConfInfo.Migrate != "" {
+		runMigrate()
+	}
 	checkpoint.InitStatusCheck()
 	
 	http.Handle("/", View())
@@ -42,6 +46,8 @@ func main() {
 	http.HandleFunc("/bot/user/list", controller.RequireLogin(controller.GetBotUser))
 	http.HandleFunc("/bot/user/mode/update", controller.RequireLogin(controller.UpdateUserMode))
 	http.HandleFunc("/bot/add/token", controller.RequireLogin(controller.AddUserToken))
+	http.HandleFunc("/bot/user/forget", controller.RequireLogin(controller.ForgetBotUser))
+	http.HandleFunc("/bot/user/forget/receipts", controller.RequireLogin(controller.GetBotDeletionReceipts))
 	http.HandleFunc("/bot/online", controller.RequireLogin(controller.GetAllOnlineBot))
 	http.HandleFunc("/bot/mcp/get", controller.RequireLogin(controller.GetBotMCPConf))
 	http.HandleFunc("/bot/mcp/update", controller.RequireLogin(controller.UpdateBotMCPConf))
@@ -49,8 +55,16 @@ func main() {
 	http.HandleFunc("/bot/mcp/disable", controller.RequireLogin(controller.DisableBotMCPConf))
 	http.HandleFunc("/bot/mcp/prepare", controller.RequireLogin(controller.GetPrepareMCPServer))
 	http.HandleFunc("/bot/mcp/sync", controller.RequireLogin(controller.SyncMCPServer))
+	http.HandleFunc("/bot/agent/get", controller.RequireLogin(controller.GetBotAgentConf))
+	http.HandleFunc("/bot/agent/update", controller.RequireLogin(controller.UpdateBotAgentConf))
+	http.HandleFunc("/bot/agent/delete", controller.RequireLogin(controller.DeleteBotAgentConf))
 	http.HandleFunc("/bot/communicate", controller.RequireLogin(controller.Communicate))
 	http.HandleFunc("/bot/admin/chat", controller.RequireLogin(controller.GetBotAdminRecord))
+	http.HandleFunc("/bot/rag/file/list", controller.RequireLogin(controller.GetBotRagFiles))
+	http.HandleFunc("/bot/rag/file/upload", controller.RequireLogin(controller.UploadBotRagFile))
+	http.HandleFunc("/bot/rag/file/delete", controller.RequireLogin(controller.DeleteBotRagFile))
+	http.HandleFunc("/bot/rag/file/reindex", controller.RequireLogin(controller.ReindexBotRagFile))
+	http.HandleFunc("/bot/rag/query", controller.RequireLogin(controller.QueryBotRag))
 	
 	http.HandleFunc("/user/login", controller.UserLogin)
 	http.HandleFunc("/user/me", controller.RequireLogin(controller.GetCurrentUserHandler))
@@ -61,3 +75,13 @@ func main() {
 		panic(err)
 	}
 }
+
+// runMigrate print or apply db migrations and exit
+func runMigrate() {
+	err := db.Migrate(os.Stdout, *conf.BaseConfInfo.Migrate)
+	if err != nil {
+		logger.Error("migrate db fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,379 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	archiveVersion = 1
+	
+	manifestEntry   = "manifest.json"
+	dbPrefix        = "db/"
+	mcpEntry        = "conf/mcp.json"
+	agentEntry      = "conf/agent.json"
+	i18nPrefix      = "conf/i18n/"
+	knowledgePrefix = "knowledge/"
+)
+
+var (
+	ManifestMissingErr = errors.New("manifest.json must be the first entry of backup archive")
+	
+	// I18nPath directory of i18n files, messages can be overridden by editing them
+	I18nPath = "./conf/i18n"
+)
+
+// Manifest summary of backup archive
+type Manifest struct {
+	Version    int            `json:"version"`
+	CreateTime int64          `json:"create_time"`
+	DBType     string         `json:"db_type"`
+	Tables     map[string]int `json:"tables"`
+	Files      []string       `json:"files"`
+}
+
+// archiveFile file on disk which is written into archive
+type archiveFile struct {
+	entry string
+	path  string
+}
+
+// Backup write consistent snapshot of bot db, mcp conf, agent conf, i18n files and knowledge files into tar.gz archive
+func Backup(archivePath string, w io.Writer) (*Manifest, error) {
+	manifest := &Manifest{
+		Version:    archiveVersion,
+		CreateTime: time.Now().Unix(),
+		DBType:     *conf.BaseConfInfo.DBType,
+		Tables:     make(map[string]int),
+	}
+	
+	tmpDir, err := os.MkdirTemp("", "musebot_backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	
+	dbFiles, err := dumpTables(tmpDir, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("dump db fail: %v", err)
+	}
+	
+	files, err := listBackupFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.entry)
+	}
+	
+	// archive is renamed after it is complete, broken archive never replaces old one
+	tmpPath := archivePath + ".tmp"
+	err = writeArchive(tmpPath, manifest, append(dbFiles, files...))
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err = os.Rename(tmpPath, archivePath); err != nil {
+		return nil, err
+	}
+	
+	for _, t := range tables {
+		fmt.Fprintf(w, "%-12s %d rows\n", t.Name, manifest.Tables[t.Name])
+	}
+	fmt.Fprintf(w, "%d files\nbackup written to %s\n", len(manifest.Files), archivePath)
+	return manifest, nil
+}
+
+// dumpTables dump all tables in one read only transaction so that they are consistent with each other
+func dumpTables(dir string, manifest *Manifest) ([]*archiveFile, error) {
+	opts := &sql.TxOptions{ReadOnly: true}
+	if *conf.BaseConfInfo.DBType != utils.Sqlite3 {
+		opts.Isolation = sql.LevelRepeatableRead
+	}
+	tx, err := db.DB.BeginTx(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	files := make([]*archiveFile, 0, len(tables))
+	for _, t := range tables {
+		filePath := filepath.Join(dir, t.Name+".jsonl")
+		f, err := os.Create(filePath)
+		if err != nil {
+			return nil, err
+		}
+		count, err := t.dump(tx, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("dump %s fail: %v", t.Name, err)
+		}
+		
+		manifest.Tables[t.Name] = count
+		files = append(files, &archiveFile{entry: dbPrefix + t.Name + ".jsonl", path: filePath})
+	}
+	return files, nil
+}
+
+// listBackupFiles list mcp conf, agent conf, i18n files and knowledge files which exist
+func listBackupFiles() ([]*archiveFile, error) {
+	var files []*archiveFile
+	if fileExist(*conf.McpConfPath) {
+		files = append(files, &archiveFile{entry: mcpEntry, path: *conf.McpConfPath})
+	}
+	if fileExist(*conf.AgentConfPath) {
+		files = append(files, &archiveFile{entry: agentEntry, path: *conf.AgentConfPath})
+	}
+	
+	i18nFiles, err := filepath.Glob(filepath.Join(I18nPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, filePath := range i18nFiles {
+		files = append(files, &archiveFile{entry: i18nPrefix + filepath.Base(filePath), path: filePath})
+	}
+	
+	knowledgePath := *conf.RagConfInfo.KnowledgePath
+	if !fileExist(knowledgePath) {
+		return files, nil
+	}
+	err = filepath.WalkDir(knowledgePath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(knowledgePath, filePath)
+		if err != nil {
+			return err
+		}
+		files = append(files, &archiveFile{entry: knowledgePrefix + filepath.ToSlash(rel), path: filePath})
+		return nil
+	})
+	return files, err
+}
+
+func writeArchive(archivePath string, manifest *Manifest, files []*archiveFile) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: manifestEntry, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return err
+	}
+	
+	for _, file := range files {
+		if err = addArchiveFile(tw, file); err != nil {
+			return fmt.Errorf("add %s fail: %v", file.path, err)
+		}
+	}
+	
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func addArchiveFile(tw *tar.Writer, file *archiveFile) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: file.entry, Mode: 0644, Size: info.Size(), ModTime: info.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Restore restore archive into empty bot db and write files to configured paths. rag_vectors are skipped and
+// vector ids of rag_files are cleared if reembed is true, so knowledge files are embedded into current vector store
+// by next reconcile and keep their owners
+func Restore(archivePath string, reembed bool, w io.Writer) (*Manifest, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	byName := make(map[string]*table, len(tables))
+	for _, t := range tables {
+		if reembed && t.Vector {
+			continue
+		}
+		empty, err := t.isEmpty(tx)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, fmt.Errorf("table %s is not empty, backup can only be restored into empty db", t.Name)
+		}
+		byName[t.Name] = t
+	}
+	
+	files := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		
+		if strings.HasPrefix(header.Name, dbPrefix) {
+			t, ok := byName[strings.TrimSuffix(strings.TrimPrefix(header.Name, dbPrefix), ".jsonl")]
+			if !ok {
+				continue
+			}
+			count, err := t.restore(tx, *conf.BaseConfInfo.DBType, reembed, tr)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(w, "%-12s %d rows\n", t.Name, count)
+			continue
+		}
+		
+		filePath, err := restorePath(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if filePath == "" {
+			logger.Warn("unknown backup entry", "entry", header.Name)
+			continue
+		}
+		if err = writeFile(filePath, tr); err != nil {
+			return nil, fmt.Errorf("restore %s fail: %v", header.Name, err)
+		}
+		files++
+	}
+	
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "%d files\nbackup restored from %s\n", files, archivePath)
+	return manifest, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("read backup archive fail: %v", err)
+	}
+	if header.Name != manifestEntry {
+		return nil, ManifestMissingErr
+	}
+	
+	manifest := new(Manifest)
+	if err = json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest fail: %v", err)
+	}
+	if manifest.Version > archiveVersion {
+		return nil, fmt.Errorf("backup version %d is newer than supported version %d", manifest.Version, archiveVersion)
+	}
+	return manifest, nil
+}
+
+// restorePath get path of archive entry on disk, empty path is returned for unknown entry
+func restorePath(entry string) (string, error) {
+	switch {
+	case entry == mcpEntry:
+		return *conf.McpConfPath, nil
+	case entry == agentEntry:
+		return *conf.AgentConfPath, nil
+	case strings.HasPrefix(entry, i18nPrefix):
+		return safeJoin(I18nPath, strings.TrimPrefix(entry, i18nPrefix))
+	case strings.HasPrefix(entry, knowledgePrefix):
+		return safeJoin(*conf.RagConfInfo.KnowledgePath, strings.TrimPrefix(entry, knowledgePrefix))
+	default:
+		return "", nil
+	}
+}
+
+// safeJoin join relative slash path to dir, path can't escape from dir
+func safeJoin(dir, rel string) (string, error) {
+	cleaned := path.Clean("/" + rel)
+	if rel == "" || cleaned != "/"+rel {
+		return "", fmt.Errorf("invalid backup entry: %s", rel)
+	}
+	return filepath.Join(dir, filepath.FromSlash(rel)), nil
+}
+
+func writeFile(filePath string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func fileExist(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestMain(m *testing.M) {
+	conf.InitConf()
+	os.Exit(m.Run())
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	var err error
+	db.DB, err = utils.OpenDB(utils.Sqlite3, ":memory:")
+	assert.Nil(t, err)
+	db.DB.SetMaxOpenConns(1)
+	_, err = db.RunMigrations()
+	assert.Nil(t, err)
+	return db.DB
+}
+
+func TestBackupRestore(t *testing.T) {
+	oldDB, oldMcp, oldAgent, oldKnowledge, oldI18n := db.DB, *conf.McpConfPath, *conf.AgentConfPath, *conf.RagConfInfo.KnowledgePath, I18nPath
+	defer func() {
+		db.DB, *conf.McpConfPath, *conf.AgentConfPath, *conf.RagConfInfo.KnowledgePath, I18nPath = oldDB, oldMcp, oldAgent, oldKnowledge, oldI18n
+	}()
+	
+	dir := t.TempDir()
+	*conf.McpConfPath = filepath.Join(dir, "src", "mcp.json")
+	*conf.AgentConfPath = filepath.Join(dir, "src", "agent.json")
+	*conf.RagConfInfo.KnowledgePath = filepath.Join(dir, "src", "knowledge")
+	I18nPath = filepath.Join(dir, "src", "i18n")
+	assert.Nil(t, writeFile(*conf.McpConfPath, bytes.NewBufferString(`{"mcpServers":{}}`)))
+	assert.Nil(t, writeFile(*conf.AgentConfPath, bytes.NewBufferString(`{"agents":{}}`)))
+	assert.Nil(t, writeFile(filepath.Join(I18nPath, "i18n.en.json"), bytes.NewBufferString(`{"hello":"hi"}`)))
+	assert.Nil(t, writeFile(filepath.Join(*conf.RagConfInfo.KnowledgePath, "sub", "doc.txt"), bytes.NewBufferString("knowledge")))
+	
+	src := openTestDB(t)
+	defer src.Close()
+	_, err := src.Exec(`INSERT INTO users (user_id, platform, mode, update_time, token, avail_token, create_time) VALUES ('1', 'telegram', 'chat', 1, 10, 100, 1)`)
+	assert.Nil(t, err)
+	_, err = src.Exec(`INSERT INTO records (user_id, platform, question, answer, content, create_time, update_time, is_deleted, token, mode, record_type, key_id, data_key)
+		VALUES ('1', 'telegram', 'q', 'a', '', 1, 1, 0, 10, 'chat', 0, '', '')`)
+	assert.Nil(t, err)
+	_, err = src.Exec(`INSERT INTO rag_vectors (vector_id, content, metadata, embedding, create_time) VALUES ('v1', 'c', '{}', ?, 1)`, []byte{0, 1, 2})
+	assert.Nil(t, err)
+	_, err = src.Exec(`INSERT INTO rag_files (file_name, file_md5, vector_id, owner, create_time, update_time, is_deleted)
+		VALUES ('sub/doc.txt', 'md5', 'v1', 'telegram:1', 1, 1, 0)`)
+	assert.Nil(t, err)
+	
+	archivePath := filepath.Join(dir, "backup.tar.gz")
+	manifest, err := Backup(archivePath, new(bytes.Buffer))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, manifest.Tables["users"])
+	assert.Equal(t, 1, manifest.Tables["rag_vectors"])
+	assert.Equal(t, 4, len(manifest.Files))
+	
+	*conf.McpConfPath = filepath.Join(dir, "dst", "mcp.json")
+	*conf.AgentConfPath = filepath.Join(dir, "dst", "agent.json")
+	*conf.RagConfInfo.KnowledgePath = filepath.Join(dir, "dst", "knowledge")
+	I18nPath = filepath.Join(dir, "dst", "i18n")
+	dst := openTestDB(t)
+	defer dst.Close()
+	_, err = Restore(archivePath, false, new(bytes.Buffer))
+	assert.Nil(t, err)
+	
+	var question string
+	var token int
+	assert.Nil(t, dst.QueryRow(`SELECT question, token FROM records WHERE user_id = '1'`).Scan(&question, &token))
+	assert.Equal(t, "q", question)
+	assert.Equal(t, 10, token)
+	var embedding []byte
+	assert.Nil(t, dst.QueryRow(`SELECT embedding FROM rag_vectors WHERE vector_id = 'v1'`).Scan(&embedding))
+	assert.Equal(t, []byte{0, 1, 2}, embedding)
+	
+	data, err := os.ReadFile(filepath.Join(*conf.RagConfInfo.KnowledgePath, "sub", "doc.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "knowledge", string(data))
+	_, err = os.Stat(filepath.Join(I18nPath, "i18n.en.json"))
+	assert.Nil(t, err)
+	_, err = os.Stat(*conf.McpConfPath)
+	assert.Nil(t, err)
+	data, err = os.ReadFile(*conf.AgentConfPath)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"agents":{}}`, string(data))
+	
+	// restore into db which already has data is rejected
+	_, err = Restore(archivePath, false, new(bytes.Buffer))
+	assert.NotNil(t, err)
+	
+	// vectors are skipped when knowledge files are embedded again, rag files are kept with owners
+	// and without vector ids so that they are embedded by next reconcile
+	reembed := openTestDB(t)
+	defer reembed.Close()
+	_, err = Restore(archivePath, true, new(bytes.Buffer))
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, reembed.QueryRow(`SELECT COUNT(*) FROM rag_vectors`).Scan(&count))
+	assert.Equal(t, 0, count)
+	assert.Nil(t, reembed.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count))
+	assert.Equal(t, 1, count)
+	var vectorId, owner string
+	assert.Nil(t, reembed.QueryRow(`SELECT vector_id, owner FROM rag_files WHERE file_name = 'sub/doc.txt'`).Scan(&vectorId, &owner))
+	assert.Equal(t, "", vectorId)
+	assert.Equal(t, "telegram:1", owner)
+}
+
+func TestSafeJoin(t *testing.T) {
+	p, err := safeJoin("/data", "sub/doc.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/data", "sub", "doc.txt"), p)
+	
+	for _, rel := range []string{"", "../etc/passwd", "sub/../../x", "/abs", "sub//x"} {
+		_, err = safeJoin("/data", rel)
+		assert.NotNil(t, err, rel)
+	}
+}
@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	intColumn  = "int"
+	textColumn = "text"
+	blobColumn = "blob"
+	
+	restoreBatchSize = 100
+)
+
+type column struct {
+	Name string
+	Kind string
+}
+
+// table table of bot db in backup, rows are dumped as jsonl so that archive can be restored into another db type
+type table struct {
+	Name    string
+	Columns []column
+	// table has serial id whose sequence is reset after restore of postgres
+	Serial bool
+	// rows depend on vector store, they are skipped if knowledge files are embedded again
+	Vector bool
+	// column which refers to vector store, it is cleared if knowledge files are embedded again,
+	// so that rows are kept with their owners and files are embedded by next reconcile
+	VectorColumn string
+}
+
+// tables dumped in backup, link codes are temporary so they are not dumped
+var tables = []*table{
+	{
+		Name:   "users",
+		Serial: true,
+		Columns: []column{
+			{"id", intColumn}, {"user_id", textColumn}, {"platform", textColumn}, {"mode", textColumn},
+			{"update_time", intColumn}, {"token", intColumn}, {"avail_token", intColumn}, {"create_time", intColumn},
+		},
+	},
+	{
+		Name:   "records",
+		Serial: true,
+		Columns: []column{
+			{"id", intColumn}, {"user_id", textColumn}, {"platform", textColumn}, {"question", textColumn},
+			{"answer", textColumn}, {"content", textColumn}, {"create_time", intColumn}, {"update_time", intColumn},
+			{"is_deleted", intColumn}, {"token", intColumn}, {"mode", textColumn}, {"record_type", intColumn},
+			{"key_id", textColumn}, {"data_key", textColumn},
+		},
+	},
+	{
+		Name: "user_links",
+		Columns: []column{
+			{"identity", textColumn}, {"user_id", textColumn}, {"create_time", intColumn},
+		},
+	},
+	{
+		Name:         "rag_files",
+		Serial:       true,
+		VectorColumn: "vector_id",
+		Columns: []column{
+			{"id", intColumn}, {"file_name", textColumn}, {"file_md5", textColumn}, {"vector_id", textColumn},
+			{"owner", textColumn}, {"create_time", intColumn}, {"update_time", intColumn}, {"is_deleted", intColumn},
+		},
+	},
+	{
+		Name:   "rag_vectors",
+		Serial: true,
+		Vector: true,
+		Columns: []column{
+			{"id", intColumn}, {"vector_id", textColumn}, {"content", textColumn}, {"metadata", textColumn},
+			{"embedding", blobColumn}, {"create_time", intColumn},
+		},
+	},
+	{
+		Name:   "deletion_receipts",
+		Serial: true,
+		Columns: []column{
+			{"id", intColumn}, {"user_hash", textColumn}, {"detail", textColumn}, {"create_time", intColumn},
+			{"prev_hash", textColumn}, {"hash", textColumn},
+		},
+	},
+}
+
+func (t *table) columnNames() string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// dump write rows of table as jsonl, return number of rows
+func (t *table) dump(tx *sql.Tx, w io.Writer) (int, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", t.columnNames(), t.Name, t.Columns[0].Name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	
+	encoder := json.NewEncoder(w)
+	count := 0
+	for rows.Next() {
+		values := make([]interface{}, len(t.Columns))
+		for i, c := range t.Columns {
+			switch c.Kind {
+			case intColumn:
+				values[i] = new(int64)
+			case blobColumn:
+				values[i] = new([]byte)
+			default:
+				values[i] = new(string)
+			}
+		}
+		if err = rows.Scan(values...); err != nil {
+			return count, err
+		}
+		
+		row := make(map[string]interface{}, len(t.Columns))
+		for i, c := range t.Columns {
+			row[c.Name] = values[i]
+		}
+		if err = encoder.Encode(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// restore insert jsonl rows into table in batches, return number of rows
+func (t *table) restore(tx *sql.Tx, dbType string, reembed bool, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	
+	count := 0
+	batch := make([][]interface{}, 0, restoreBatchSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		
+		values, err := t.decodeRow(line)
+		if err != nil {
+			return count, fmt.Errorf("decode row %d of %s fail: %v", count+1, t.Name, err)
+		}
+		if reembed {
+			t.clearVector(values)
+		}
+		batch = append(batch, values)
+		if len(batch) >= restoreBatchSize {
+			if err = t.insert(tx, batch); err != nil {
+				return count, err
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	if err := t.insert(tx, batch); err != nil {
+		return count, err
+	}
+	count += len(batch)
+	
+	// postgres sequence isn't moved by rows inserted with id
+	if t.Serial && dbType == utils.Postgres && count > 0 {
+		_, err := tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT MAX(id) FROM %s))", t.Name, t.Name))
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+func (t *table) decodeRow(line []byte) ([]interface{}, error) {
+	row := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(line, &row); err != nil {
+		return nil, err
+	}
+	
+	values := make([]interface{}, len(t.Columns))
+	for i, c := range t.Columns {
+		raw, ok := row[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("column %s is missing", c.Name)
+		}
+		
+		var err error
+		switch c.Kind {
+		case intColumn:
+			var v int64
+			err = json.Unmarshal(raw, &v)
+			values[i] = v
+		case blobColumn:
+			var v []byte
+			err = json.Unmarshal(raw, &v)
+			values[i] = v
+		default:
+			var v string
+			err = json.Unmarshal(raw, &v)
+			values[i] = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", c.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// clearVector clear column which refers to vector store of decoded row
+func (t *table) clearVector(values []interface{}) {
+	for i, c := range t.Columns {
+		if c.Name == t.VectorColumn {
+			values[i] = ""
+		}
+	}
+}
+
+func (t *table) insert(tx *sql.Tx, batch [][]interface{}) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	
+	placeholder := "(?" + strings.Repeat(", ?", len(t.Columns)-1) + ")"
+	placeholders := make([]string, len(batch))
+	args := make([]interface{}, 0, len(batch)*len(t.Columns))
+	for i, values := range batch {
+		placeholders[i] = placeholder
+		args = append(args, values...)
+	}
+	
+	_, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", t.Name, t.columnNames(), strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		return fmt.Errorf("insert into %s fail: %v", t.Name, err)
+	}
+	return nil
+}
+
+// isEmpty check whether table has no row, archive is only restored into empty tables
+func (t *table) isEmpty(tx *sql.Tx) (bool, error) {
+	var count int
+	err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", t.Name)).Scan(&count)
+	return count == 0, err
+}
@@ -0,0 +1,131 @@
+package conf
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/mcp-client-go/clients"
+	"github.com/yincongcyincong/mcp-client-go/utils"
+)
+
+type AgentConfig struct {
+	Agents map[string]*AgentInfo `json:"agents"`
+}
+
+// InitAgents load user defined agents from agent conf file
+func InitAgents() {
+	config, err := GetAgentConf()
+	if err != nil {
+		logger.Error("get agent conf fail", "err", err)
+		return
+	}
+	
+	for name, agent := range config.Agents {
+		// agent without llm client can't be executed
+		if agent.Type != "" && !param.LLMTypes[agent.Type] {
+			logger.Error("agent type is invalid", "agent", name, "type", agent.Type)
+			continue
+		}
+		InsertAgent(name, agent)
+	}
+}
+
+// InsertAgent pick allowed tools from all mcp servers and store agent into task tools
+func InsertAgent(name string, agent *AgentInfo) {
+	agent.Name = name
+	tools := make([]mcp.Tool, 0, len(agent.Tools))
+	for _, toolName := range agent.Tools {
+		c, err := clients.GetMCPClientByToolName(toolName)
+		if err != nil {
+			logger.Warn("agent tool not found", "agent", name, "tool", toolName, "err", err)
+			continue
+		}
+		
+		for _, tool := range c.Tools {
+			if tool.Name == toolName {
+				tools = append(tools, tool)
+				break
+			}
+		}
+	}
+	
+	agent.DeepseekTool = utils.TransToolsToDPFunctionCall(tools)
+	agent.VolTool = utils.TransToolsToVolFunctionCall(tools)
+	agent.OpenAITools = utils.TransToolsToChatGPTFunctionCall(tools)
+	agent.GeminiTools = utils.TransToolsToGeminiFunctionCall(tools)
+	agent.OpenRouterTools = utils.TransToolsToOpenRouterFunctionCall(tools)
+	
+	TaskTools.Store(name, agent)
+	logger.Info("insert agent", "agent", name, "tools", len(tools))
+}
+
+// GetAgent get user defined agent by name
+func GetAgent(name string) (*AgentInfo, error) {
+	agentInter, ok := TaskTools.Load(name)
+	if !ok {
+		return nil, errors.New("agent not found")
+	}
+	
+	agent := agentInter.(*AgentInfo)
+	if !agent.IsUserDefined() {
+		return nil, errors.New("agent is not user defined")
+	}
+	
+	return agent, nil
+}
+
+// GetAgentConf read agent conf file, return empty conf when file not exist
+func GetAgentConf() (*AgentConfig, error) {
+	config := &AgentConfig{
+		Agents: make(map[string]*AgentInfo),
+	}
+	
+	data, err := os.ReadFile(*AgentConfPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		logger.Error("read agent conf error", "err", err)
+		return nil, err
+	}
+	
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		logger.Error("unmarshal agent conf error", "err", err)
+		return nil, err
+	}
+	
+	if config.Agents == nil {
+		config.Agents = make(map[string]*AgentInfo)
+	}
+	
+	return config, nil
+}
+
+// UpdateAgentConfFile write agent conf file
+func UpdateAgentConfFile(config *AgentConfig) error {
+	err := os.MkdirAll(filepath.Dir(*AgentConfPath), 0755)
+	if err != nil {
+		logger.Error("create agent conf dir error", "err", err)
+		return err
+	}
+	
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		logger.Error("encode agent conf error", "err", err)
+		return err
+	}
+	
+	err = os.WriteFile(*AgentConfPath, data, 0644)
+	if err != nil {
+		logger.Error("write agent conf error", "err", err)
+		return err
+	}
+	
+	return nil
+}
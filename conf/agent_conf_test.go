@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentConf(t *testing.T) {
+	oldPath := AgentConfPath
+	defer func() {
+		AgentConfPath = oldPath
+	}()
+	
+	path := filepath.Join(t.TempDir(), "agent", "agent.json")
+	AgentConfPath = &path
+	
+	config, err := GetAgentConf()
+	assert.Nil(t, err)
+	assert.Len(t, config.Agents, 0)
+	
+	temperature := 0.2
+	config.Agents["translator"] = &AgentInfo{
+		Description: "translate text",
+		Prompt:      "You are a translator.",
+		Model:       "gpt-4o",
+		Temperature: &temperature,
+	}
+	config.Agents["unknown_type"] = &AgentInfo{Prompt: "You are nobody.", Type: "unknown"}
+	err = UpdateAgentConfFile(config)
+	assert.Nil(t, err)
+	
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "DeepseekTool")
+	
+	TaskTools.Store("mcp_server", &AgentInfo{Description: "mcp server"})
+	defer TaskTools.Delete("mcp_server")
+	defer TaskTools.Delete("translator")
+	
+	InitAgents()
+	agent, err := GetAgent("translator")
+	assert.Nil(t, err)
+	assert.Equal(t, "translator", agent.Name)
+	assert.Equal(t, "gpt-4o", agent.Model)
+	assert.Equal(t, 0.2, *agent.Temperature)
+	
+	// agent of unknown llm type isn't loaded
+	_, err = GetAgent("unknown_type")
+	assert.NotNil(t, err)
+	
+	_, err = GetAgent("mcp_server")
+	assert.NotNil(t, err)
+	
+	_, err = GetAgent("not_exist")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,46 @@
+package conf
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+type BackupConf struct {
+	// write db snapshot, mcp conf, i18n files and knowledge files into archive and exit
+	Backup *string `json:"backup"`
+	
+	// restore archive into empty db and exit
+	Restore *string `json:"restore"`
+	
+	// knowledge files are embedded into current vector store instead of restoring rag_files and rag_vectors
+	RestoreReembed *bool `json:"restore_reembed"`
+}
+
+var (
+	BackupConfInfo = new(BackupConf)
+)
+
+func InitBackupConf() {
+	BackupConfInfo.Backup = flag.String("backup", "", "write backup archive to path and exit")
+	BackupConfInfo.Restore = flag.String("restore", "", "restore backup archive of path and exit")
+	BackupConfInfo.RestoreReembed = flag.Bool("restore_reembed", false, "embed knowledge files into current vector store when restore")
+}
+
+func EnvBackupConf() {
+	if os.Getenv("BACKUP") != "" {
+		*BackupConfInfo.Backup = os.Getenv("BACKUP")
+	}
+	if os.Getenv("RESTORE") != "" {
+		*BackupConfInfo.Restore = os.Getenv("RESTORE")
+	}
+	if os.Getenv("RESTORE_REEMBED") != "" {
+		*BackupConfInfo.RestoreReembed, _ = strconv.ParseBool(os.Getenv("RESTORE_REEMBED"))
+	}
+	
+	logger.Info("BACKUP_CONF", "Backup", *BackupConfInfo.Backup)
+	logger.Info("BACKUP_CONF", "Restore", *BackupConfInfo.Restore)
+	logger.Info("BACKUP_CONF", "RestoreReembed", *BackupConfInfo.RestoreReembed)
+}
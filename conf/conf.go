@@ -28,13 +28,18 @@ type BaseConf struct {
 	ErnieAK         *string `json:"ernie_ak"`
 	ErnieSK         *string `json:"ernie_sk"`
 	
-	Type         *string `json:"type"`
-	MediaType    *string `json:"media_type"`
-	CustomUrl    *string `json:"custom_url"`
-	VolcAK       *string `json:"volc_ak"`
-	VolcSK       *string `json:"volc_sk"`
-	DBType       *string `json:"db_type"`
-	DBConf       *string `json:"db_conf"`
+	Type        *string `json:"type"`
+	MediaType   *string `json:"media_type"`
+	CustomUrl   *string `json:"custom_url"`
+	VolcAK      *string `json:"volc_ak"`
+	VolcSK      *string `json:"volc_sk"`
+	DBType      *string `json:"db_type"`
+	DBConf      *string `json:"db_conf"`
+	Migrate     *string `json:"migrate"`
+	AutoMigrate *bool   `json:"auto_migrate"`
+	
+	LegacyPlatform *string `json:"legacy_platform"`
+	
 	LLMProxy     *string `json:"llm_proxy"`
 	RobotProxy   *string `json:"robot_proxy"`
 	Lang         *string `json:"lang"`
@@ -81,6 +86,9 @@ func InitConf() {
 	BaseConfInfo.MediaType = flag.String("media_type", "vol", "media type: vol gemini openai openrouter")
 	BaseConfInfo.DBType = flag.String("db_type", "sqlite3", "db type")
 	BaseConfInfo.DBConf = flag.String("db_conf", "./data/telegram_bot.db", "db conf")
+	BaseConfInfo.Migrate = flag.String("migrate", "", "print db migrations and exit: status, up applies pending migrations first")
+	BaseConfInfo.AutoMigrate = flag.Bool("auto_migrate", true, "apply pending db migrations when start")
+	BaseConfInfo.LegacyPlatform = flag.String("legacy_platform", "", "platform of users created before user ids are qualified: telegram discord slack lark web")
 	BaseConfInfo.LLMProxy = flag.String("llm_proxy", "", "llm proxy: http://127.0.0.1:7890")
 	BaseConfInfo.RobotProxy = flag.String("robot_proxy", "", "robot proxy: http://127.0.0.1:7890")
 	BaseConfInfo.Lang = flag.String("lang", "en", "lang")
@@ -108,6 +116,10 @@ func InitConf() {
 	InitAudioConf()
 	InitToolsConf()
 	InitRagConf()
+	InitRetentionConf()
+	InitStateConf()
+	InitRecordConf()
+	InitBackupConf()
 	flag.Parse()
 	
 	if os.Getenv("TELEGRAM_BOT_TOKEN") != "" {
@@ -162,6 +174,18 @@ func InitConf() {
 		*BaseConfInfo.DBConf = os.Getenv("DB_CONF")
 	}
 	
+	if os.Getenv("MIGRATE") != "" {
+		*BaseConfInfo.Migrate = os.Getenv("MIGRATE")
+	}
+	
+	if os.Getenv("AUTO_MIGRATE") != "" {
+		*BaseConfInfo.AutoMigrate, _ = strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
+	}
+	
+	if os.Getenv("LEGACY_PLATFORM") != "" {
+		*BaseConfInfo.LegacyPlatform = os.Getenv("LEGACY_PLATFORM")
+	}
+	
 	if os.Getenv("ALLOWED_USER_IDS") != "" {
 		*allowedUserIds = os.Getenv("ALLOWED_USER_IDS")
 	}
@@ -277,6 +301,9 @@ func InitConf() {
 	logger.Info("CONF", "VolcSK", *BaseConfInfo.VolcSK)
 	logger.Info("CONF", "DBType", *BaseConfInfo.DBType)
 	logger.Info("CONF", "DBConf", *BaseConfInfo.DBConf)
+	logger.Info("CONF", "Migrate", *BaseConfInfo.Migrate)
+	logger.Info("CONF", "AutoMigrate", *BaseConfInfo.AutoMigrate)
+	logger.Info("CONF", "LegacyPlatform", *BaseConfInfo.LegacyPlatform)
 	logger.Info("CONF", "AllowedTelegramUserIds", *allowedUserIds)
 	logger.Info("CONF", "AllowedTelegramGroupIds", *allowedGroupIds)
 	logger.Info("CONF", "LLMProxy", *BaseConfInfo.LLMProxy)
@@ -304,5 +331,9 @@ func InitConf() {
 	EnvPhotoConf()
 	EnvToolsConf()
 	EnvVideoConf()
+	EnvRetentionConf()
+	EnvStateConf()
+	EnvRecordConf()
+	EnvBackupConf()
 	
 }
@@ -15,6 +15,8 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	os.Setenv("VOLC_SK", "volc-sk")
 	os.Setenv("DB_TYPE", "mysql")
 	os.Setenv("DB_CONF", "user:pass@tcp(127.0.0.1:3306)/dbname")
+	os.Setenv("MIGRATE", "status")
+	os.Setenv("AUTO_MIGRATE", "false")
 	os.Setenv("ALLOWED_USER_IDS", "1001,1002")
 	os.Setenv("ALLOWED_GROUP_IDS", "-2001,-2002")
 	os.Setenv("LLM_PROXY", "http://proxy.deepseek")
@@ -69,12 +71,47 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	os.Setenv("TELEGRAM_BOT_TOKEN", "test_bot_token")
 	os.Setenv("DEEPSEEK_TOKEN", "test_deepseek_token")
 	os.Setenv("EMBEDDING_TYPE", "openai")
+	os.Setenv("EMBEDDING_BASE_URL", "http://localhost:11434")
+	os.Setenv("EMBEDDING_MODEL", "bge-m3")
+	os.Setenv("EMBEDDING_DIMENSION", "1024")
+	os.Setenv("EMBEDDING_BATCH_SIZE", "16")
 	os.Setenv("KNOWLEDGE_PATH", "/data/knowledge")
 	os.Setenv("VECTOR_DB_TYPE", "milvus")
 	os.Setenv("CHROMA_URL", "http://localhost:8000")
 	os.Setenv("SPACE", "test-space")
 	os.Setenv("CHUNK_SIZE", "500")
 	os.Setenv("CHUNK_OVERLAP", "50")
+	os.Setenv("EXT_CHUNK_SIZE", "md:1000:100,.GO:1500")
+	os.Setenv("NAMESPACE_MAP", "-1001:team_a, -1002:team_a")
+	os.Setenv("CRAWL_MAX_DEPTH", "3")
+	os.Setenv("CRAWL_MAX_PAGES", "50")
+	os.Setenv("RAG_TOP_K", "5")
+	os.Setenv("RAG_SCORE_THRESHOLD", "0.3")
+	os.Setenv("HYBRID_SEARCH", "false")
+	os.Setenv("RERANK_TYPE", "cross_encoder")
+	os.Setenv("RERANK_URL", "http://localhost:8080/v1/rerank")
+	os.Setenv("RAG_EVAL_FILE", "./golden.jsonl")
+	os.Setenv("RAG_EVAL_K", "10")
+	os.Setenv("RAG_EVAL_GENERATE", "true")
+	
+	os.Setenv("PURGE_DELETED_DAYS", "30")
+	os.Setenv("ARCHIVE_DAYS", "180")
+	os.Setenv("ARCHIVE_DIR", "./archive")
+	os.Setenv("RETENTION_INTERVAL", "10")
+	
+	os.Setenv("STATE_STORE", "redis")
+	os.Setenv("REDIS_ADDR", "redis:6379")
+	os.Setenv("REDIS_DB", "2")
+	os.Setenv("STATE_PREFIX", "bot_a:")
+	os.Setenv("CONTEXT_TTL", "3600")
+	
+	os.Setenv("RECORD_QUEUE_SIZE", "500")
+	os.Setenv("RECORD_BATCH_SIZE", "50")
+	os.Setenv("RECORD_FLUSH_INTERVAL", "100")
+	os.Setenv("RECORD_ENCRYPT_KEYS", "k1:MTIzNDU2Nzg5MDEyMzQ1Ng==")
+	os.Setenv("RECEIPT_SECRET", "receipt")
+	
+	os.Setenv("RESTORE_REEMBED", "true")
 	
 	os.Setenv("MCP_CONF_PATH", "./conf/mcp/mcp.json")
 	
@@ -99,6 +136,8 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	assertEqual(t, *BaseConfInfo.VolcSK, "volc-sk", "VolcSK")
 	assertEqual(t, *BaseConfInfo.DBType, "mysql", "DBType")
 	assertEqual(t, *BaseConfInfo.DBConf, "user:pass@tcp(127.0.0.1:3306)/dbname", "DBConf")
+	assertEqual(t, *BaseConfInfo.Migrate, "status", "Migrate")
+	assertBool(t, *BaseConfInfo.AutoMigrate, false, "AutoMigrate")
 	assertEqual(t, *BaseConfInfo.LLMProxy, "http://proxy.deepseek", "LLMProxy")
 	assertEqual(t, *BaseConfInfo.RobotProxy, "http://proxy.telegram", "RobotProxy")
 	assertEqual(t, *BaseConfInfo.Lang, "zh-CN", "Lang")
@@ -112,6 +151,25 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	assertEqual(t, *BaseConfInfo.ErnieAK, "ernie-ak", "ErnieAK")
 	assertEqual(t, *BaseConfInfo.ErnieSK, "ernie-sk", "ErnieSK")
 	
+	assertInt(t, *RetentionConfInfo.PurgeDeletedDays, 30, "PurgeDeletedDays")
+	assertInt(t, *RetentionConfInfo.ArchiveDays, 180, "ArchiveDays")
+	assertEqual(t, *RetentionConfInfo.ArchiveDir, "./archive", "ArchiveDir")
+	assertInt(t, *RetentionConfInfo.RetentionInterval, 10, "RetentionInterval")
+	
+	assertEqual(t, *StateConfInfo.StateStore, "redis", "StateStore")
+	assertEqual(t, *StateConfInfo.RedisAddr, "redis:6379", "RedisAddr")
+	assertInt(t, *StateConfInfo.RedisDB, 2, "RedisDB")
+	assertEqual(t, *StateConfInfo.StatePrefix, "bot_a:", "StatePrefix")
+	assertInt(t, *StateConfInfo.ContextTTL, 3600, "ContextTTL")
+	
+	assertInt(t, *RecordConfInfo.RecordQueueSize, 500, "RecordQueueSize")
+	assertInt(t, *RecordConfInfo.RecordBatchSize, 50, "RecordBatchSize")
+	assertInt(t, *RecordConfInfo.RecordFlushInterval, 100, "RecordFlushInterval")
+	assertEqual(t, *RecordConfInfo.RecordEncryptKeys, "k1:MTIzNDU2Nzg5MDEyMzQ1Ng==", "RecordEncryptKeys")
+	assertEqual(t, *RecordConfInfo.ReceiptSecret, "receipt", "ReceiptSecret")
+	
+	assertBool(t, *BackupConfInfo.RestoreReembed, true, "RestoreReembed")
+	
 	assertEqual(t, *AudioConfInfo.AudioAppID, "test-audio-app-id", "AudioAppID")
 	assertEqual(t, *AudioConfInfo.AudioToken, "test-audio-token", "AudioToken")
 	assertEqual(t, *AudioConfInfo.AudioCluster, "test-cluster", "AudioCluster")
@@ -142,12 +200,39 @@ func TestInitConf_AllEnvVars(t *testing.T) {
 	assertEqual(t, *PhotoConfInfo.LogoTextContent, "Test Logo", "LogoTextContent")
 	
 	assertEqual(t, *RagConfInfo.EmbeddingType, "openai", "EmbeddingType")
+	assertEqual(t, *RagConfInfo.EmbeddingBaseURL, "http://localhost:11434", "EmbeddingBaseURL")
+	assertEqual(t, *RagConfInfo.EmbeddingModel, "bge-m3", "EmbeddingModel")
+	assertInt(t, *RagConfInfo.EmbeddingDimension, 1024, "EmbeddingDimension")
+	assertInt(t, *RagConfInfo.EmbeddingBatchSize, 16, "EmbeddingBatchSize")
 	assertEqual(t, *RagConfInfo.KnowledgePath, "/data/knowledge", "KnowledgePath")
 	assertEqual(t, *RagConfInfo.VectorDBType, "milvus", "VectorDBType")
 	assertEqual(t, *RagConfInfo.ChromaURL, "http://localhost:8000", "ChromaURL")
 	assertEqual(t, *RagConfInfo.Space, "test-space", "ChromaSpace")
 	assertInt(t, *RagConfInfo.ChunkSize, 500, "ChunkSize")
 	assertInt(t, *RagConfInfo.ChunkOverlap, 50, "ChunkOverlap")
+	assertEqual(t, *RagConfInfo.ExtChunkSize, "md:1000:100,.GO:1500", "ExtChunkSize")
+	chunkSize, chunkOverlap := RagConfInfo.GetChunkSize(".md")
+	assertInt(t, chunkSize, 1000, "md ChunkSize")
+	assertInt(t, chunkOverlap, 100, "md ChunkOverlap")
+	chunkSize, chunkOverlap = RagConfInfo.GetChunkSize(".go")
+	assertInt(t, chunkSize, 1500, "go ChunkSize")
+	assertInt(t, chunkOverlap, 50, "go ChunkOverlap")
+	chunkSize, chunkOverlap = RagConfInfo.GetChunkSize(".txt")
+	assertInt(t, chunkSize, 500, "txt ChunkSize")
+	assertInt(t, chunkOverlap, 50, "txt ChunkOverlap")
+	assertEqual(t, RagConfInfo.GetNamespace("-1001"), "team_a", "NamespaceMap")
+	assertEqual(t, RagConfInfo.GetNamespace("-1002"), "team_a", "NamespaceMap")
+	assertEqual(t, RagConfInfo.GetNamespace("123"), "123", "NamespaceMap")
+	assertInt(t, *RagConfInfo.CrawlMaxDepth, 3, "CrawlMaxDepth")
+	assertInt(t, *RagConfInfo.CrawlMaxPages, 50, "CrawlMaxPages")
+	assertInt(t, *RagConfInfo.RagTopK, 5, "RagTopK")
+	assertFloatEqual(t, *RagConfInfo.RagScoreThreshold, 0.3, "RagScoreThreshold")
+	assertBool(t, *RagConfInfo.HybridSearch, false, "HybridSearch")
+	assertEqual(t, *RagConfInfo.RerankType, "cross_encoder", "RerankType")
+	assertEqual(t, *RagConfInfo.RerankURL, "http://localhost:8080/v1/rerank", "RerankURL")
+	assertEqual(t, *RagConfInfo.RagEvalFile, "./golden.jsonl", "RagEvalFile")
+	assertInt(t, *RagConfInfo.RagEvalK, 10, "RagEvalK")
+	assertBool(t, *RagConfInfo.RagEvalGenerate, true, "RagEvalGenerate")
 	
 	assertEqual(t, *McpConfPath, "./conf/mcp/mcp.json", "MCP_CONF_PATH")
 	
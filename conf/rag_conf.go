@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"strings"
 	
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
@@ -14,6 +15,14 @@ import (
 
 type RagConf struct {
 	EmbeddingType *string `json:"embedding_type"`
+	
+	EmbeddingBaseURL   *string `json:"embedding_base_url"`
+	EmbeddingToken     *string `json:"embedding_token"`
+	EmbeddingModel     *string `json:"embedding_model"`
+	EmbeddingDimension *int    `json:"embedding_dimension"`
+	EmbeddingBatchSize *int    `json:"embedding_batch_size"`
+	EmbeddingRetry     *int    `json:"embedding_retry"`
+	
 	KnowledgePath *string `json:"knowledge_path"`
 	VectorDBType  *string `json:"vector_db_type"`
 	
@@ -27,6 +36,32 @@ type RagConf struct {
 	ChunkSize    *int `json:"chunk_size"`
 	ChunkOverlap *int `json:"chunk_overlap"`
 	
+	// ext chunk size, e.g. md:1000:100,go:1500 means .md use chunk size 1000 and overlap 100
+	ExtChunkSize *string `json:"ext_chunk_size"`
+	
+	// namespace map, e.g. -1001:team_a,-1002:team_a means both groups share knowledge base namespace team_a
+	NamespaceMap *string `json:"namespace_map"`
+	
+	// crawl limit of /learn <url>, user can set depth but not exceed crawl_max_depth
+	CrawlMaxDepth *int `json:"crawl_max_depth"`
+	CrawlMaxPages *int `json:"crawl_max_pages"`
+	
+	// retrieval of rag answer, vector documents whose score is lower than rag_score_threshold are dropped,
+	// hybrid search fuses vector and bm25 keyword results, rerank type: llm cross_encoder
+	RagTopK           *int     `json:"rag_top_k"`
+	RagScoreThreshold *float64 `json:"rag_score_threshold"`
+	HybridSearch      *bool    `json:"hybrid_search"`
+	RerankType        *string  `json:"rerank_type"`
+	RerankURL         *string  `json:"rerank_url"`
+	RerankToken       *string  `json:"rerank_token"`
+	RerankModel       *string  `json:"rerank_model"`
+	
+	// offline evaluation, golden set is jsonl of question, namespace, sources and answer,
+	// report is printed and program exits when rag_eval_file is set
+	RagEvalFile     *string `json:"rag_eval_file"`
+	RagEvalK        *int    `json:"rag_eval_k"`
+	RagEvalGenerate *bool   `json:"rag_eval_generate"`
+	
 	Store          vectorstores.VectorStore `json:"-"`
 	Embedder       embeddings.Embedder      `json:"-"`
 	MilvusClient   client.Client            `json:"-"`
@@ -40,9 +75,15 @@ var (
 )
 
 func InitRagConf() {
-	RagConfInfo.EmbeddingType = flag.String("embedding_type", "", "embedding split api: openai gemini ernie")
+	RagConfInfo.EmbeddingType = flag.String("embedding_type", "", "embedding split api: openai gemini ernie ollama openai_compatible")
+	RagConfInfo.EmbeddingBaseURL = flag.String("embedding_base_url", "", "embedding base url of ollama or openai compatible api")
+	RagConfInfo.EmbeddingToken = flag.String("embedding_token", "", "embedding token of openai compatible api")
+	RagConfInfo.EmbeddingModel = flag.String("embedding_model", "", "embedding model of ollama or openai compatible api")
+	RagConfInfo.EmbeddingDimension = flag.Int("embedding_dimension", 0, "embedding dimension, 0 means model default")
+	RagConfInfo.EmbeddingBatchSize = flag.Int("embedding_batch_size", 32, "number of texts in one embedding request")
+	RagConfInfo.EmbeddingRetry = flag.Int("embedding_retry", 3, "retry times when embedding request fail")
 	RagConfInfo.KnowledgePath = flag.String("knowledge_path", "./data/knowledge", "knowledge")
-	RagConfInfo.VectorDBType = flag.String("vector_db_type", "milvus", "vector db type: chroma weaviate milvus")
+	RagConfInfo.VectorDBType = flag.String("vector_db_type", "milvus", "vector db type: local weaviate milvus")
 	
 	RagConfInfo.ChromaURL = flag.String("chroma_url", "http://localhost:8000", "chroma url")
 	RagConfInfo.MilvusURL = flag.String("milvus_url", "http://localhost:19530", "milvus url")
@@ -52,6 +93,20 @@ func InitRagConf() {
 	
 	RagConfInfo.ChunkSize = flag.Int("chunk_size", 500, "rag file chunk size")
 	RagConfInfo.ChunkOverlap = flag.Int("chunk_overlap", 50, "rag file chunk overlap")
+	RagConfInfo.NamespaceMap = flag.String("namespace_map", "", "map chat to shared knowledge base namespace, e.g. -1001:team_a,-1002:team_a")
+	RagConfInfo.ExtChunkSize = flag.String("ext_chunk_size", "", "chunk size and overlap of file extension, e.g. md:1000:100,go:1500")
+	RagConfInfo.CrawlMaxDepth = flag.Int("crawl_max_depth", 2, "max depth of same domain links followed by /learn <url>")
+	RagConfInfo.CrawlMaxPages = flag.Int("crawl_max_pages", 20, "max pages crawled by one /learn <url>")
+	RagConfInfo.RagTopK = flag.Int("rag_top_k", 3, "number of knowledge chunks put into rag prompt")
+	RagConfInfo.RagScoreThreshold = flag.Float64("rag_score_threshold", 0, "min vector similarity score of knowledge chunk, 0 means no threshold")
+	RagConfInfo.HybridSearch = flag.Bool("hybrid_search", true, "search bm25 keyword index with vector db and fuse results")
+	RagConfInfo.RerankType = flag.String("rerank_type", "", "rerank knowledge chunks: llm cross_encoder, empty means no rerank")
+	RagConfInfo.RerankURL = flag.String("rerank_url", "", "rerank api url of cross encoder, e.g. http://localhost:8080/v1/rerank")
+	RagConfInfo.RerankToken = flag.String("rerank_token", "", "rerank api token of cross encoder")
+	RagConfInfo.RerankModel = flag.String("rerank_model", "", "rerank model of cross encoder")
+	RagConfInfo.RagEvalFile = flag.String("rag_eval_file", "", "jsonl golden set, evaluate retrieval, print report and exit")
+	RagConfInfo.RagEvalK = flag.Int("rag_eval_k", 5, "k of recall@k in rag evaluation")
+	RagConfInfo.RagEvalGenerate = flag.Bool("rag_eval_generate", false, "generate answers and compare them with expected answers in rag evaluation")
 	
 }
 
@@ -60,6 +115,30 @@ func EnvRagConf() {
 		*RagConfInfo.EmbeddingType = os.Getenv("EMBEDDING_TYPE")
 	}
 	
+	if os.Getenv("EMBEDDING_BASE_URL") != "" {
+		*RagConfInfo.EmbeddingBaseURL = os.Getenv("EMBEDDING_BASE_URL")
+	}
+	
+	if os.Getenv("EMBEDDING_TOKEN") != "" {
+		*RagConfInfo.EmbeddingToken = os.Getenv("EMBEDDING_TOKEN")
+	}
+	
+	if os.Getenv("EMBEDDING_MODEL") != "" {
+		*RagConfInfo.EmbeddingModel = os.Getenv("EMBEDDING_MODEL")
+	}
+	
+	if os.Getenv("EMBEDDING_DIMENSION") != "" {
+		*RagConfInfo.EmbeddingDimension, _ = strconv.Atoi(os.Getenv("EMBEDDING_DIMENSION"))
+	}
+	
+	if os.Getenv("EMBEDDING_BATCH_SIZE") != "" {
+		*RagConfInfo.EmbeddingBatchSize, _ = strconv.Atoi(os.Getenv("EMBEDDING_BATCH_SIZE"))
+	}
+	
+	if os.Getenv("EMBEDDING_RETRY") != "" {
+		*RagConfInfo.EmbeddingRetry, _ = strconv.Atoi(os.Getenv("EMBEDDING_RETRY"))
+	}
+	
 	if os.Getenv("KNOWLEDGE_PATH") != "" {
 		*RagConfInfo.KnowledgePath = os.Getenv("KNOWLEDGE_PATH")
 	}
@@ -96,7 +175,68 @@ func EnvRagConf() {
 		*RagConfInfo.ChunkOverlap, _ = strconv.Atoi(os.Getenv("CHUNK_OVERLAP"))
 	}
 	
+	if os.Getenv("EXT_CHUNK_SIZE") != "" {
+		*RagConfInfo.ExtChunkSize = os.Getenv("EXT_CHUNK_SIZE")
+	}
+	
+	if os.Getenv("NAMESPACE_MAP") != "" {
+		*RagConfInfo.NamespaceMap = os.Getenv("NAMESPACE_MAP")
+	}
+	
+	if os.Getenv("CRAWL_MAX_DEPTH") != "" {
+		*RagConfInfo.CrawlMaxDepth, _ = strconv.Atoi(os.Getenv("CRAWL_MAX_DEPTH"))
+	}
+	
+	if os.Getenv("CRAWL_MAX_PAGES") != "" {
+		*RagConfInfo.CrawlMaxPages, _ = strconv.Atoi(os.Getenv("CRAWL_MAX_PAGES"))
+	}
+	
+	if os.Getenv("RAG_TOP_K") != "" {
+		*RagConfInfo.RagTopK, _ = strconv.Atoi(os.Getenv("RAG_TOP_K"))
+	}
+	
+	if os.Getenv("RAG_SCORE_THRESHOLD") != "" {
+		*RagConfInfo.RagScoreThreshold, _ = strconv.ParseFloat(os.Getenv("RAG_SCORE_THRESHOLD"), 64)
+	}
+	
+	if os.Getenv("HYBRID_SEARCH") != "" {
+		*RagConfInfo.HybridSearch, _ = strconv.ParseBool(os.Getenv("HYBRID_SEARCH"))
+	}
+	
+	if os.Getenv("RERANK_TYPE") != "" {
+		*RagConfInfo.RerankType = os.Getenv("RERANK_TYPE")
+	}
+	
+	if os.Getenv("RERANK_URL") != "" {
+		*RagConfInfo.RerankURL = os.Getenv("RERANK_URL")
+	}
+	
+	if os.Getenv("RERANK_TOKEN") != "" {
+		*RagConfInfo.RerankToken = os.Getenv("RERANK_TOKEN")
+	}
+	
+	if os.Getenv("RERANK_MODEL") != "" {
+		*RagConfInfo.RerankModel = os.Getenv("RERANK_MODEL")
+	}
+	
+	if os.Getenv("RAG_EVAL_FILE") != "" {
+		*RagConfInfo.RagEvalFile = os.Getenv("RAG_EVAL_FILE")
+	}
+	
+	if os.Getenv("RAG_EVAL_K") != "" {
+		*RagConfInfo.RagEvalK, _ = strconv.Atoi(os.Getenv("RAG_EVAL_K"))
+	}
+	
+	if os.Getenv("RAG_EVAL_GENERATE") != "" {
+		*RagConfInfo.RagEvalGenerate, _ = strconv.ParseBool(os.Getenv("RAG_EVAL_GENERATE"))
+	}
+	
 	logger.Info("RAG_CONF", "EmbeddingType", *RagConfInfo.EmbeddingType)
+	logger.Info("RAG_CONF", "EmbeddingBaseURL", *RagConfInfo.EmbeddingBaseURL)
+	logger.Info("RAG_CONF", "EmbeddingModel", *RagConfInfo.EmbeddingModel)
+	logger.Info("RAG_CONF", "EmbeddingDimension", *RagConfInfo.EmbeddingDimension)
+	logger.Info("RAG_CONF", "EmbeddingBatchSize", *RagConfInfo.EmbeddingBatchSize)
+	logger.Info("RAG_CONF", "EmbeddingRetry", *RagConfInfo.EmbeddingRetry)
 	logger.Info("RAG_CONF", "KnowledgePath", *RagConfInfo.KnowledgePath)
 	logger.Info("RAG_CONF", "VectorDBType", *RagConfInfo.VectorDBType)
 	logger.Info("RAG_CONF", "ChromaURL", *RagConfInfo.ChromaURL)
@@ -104,4 +244,69 @@ func EnvRagConf() {
 	logger.Info("RAG_CONF", "MilvusURL", *RagConfInfo.MilvusURL)
 	logger.Info("RAG_CONF", "WeaviateURL", *RagConfInfo.WeaviateURL)
 	logger.Info("RAG_CONF", "WeaviateScheme", *RagConfInfo.WeaviateScheme)
+	logger.Info("RAG_CONF", "ChunkSize", *RagConfInfo.ChunkSize)
+	logger.Info("RAG_CONF", "ChunkOverlap", *RagConfInfo.ChunkOverlap)
+	logger.Info("RAG_CONF", "ExtChunkSize", *RagConfInfo.ExtChunkSize)
+	logger.Info("RAG_CONF", "NamespaceMap", *RagConfInfo.NamespaceMap)
+	logger.Info("RAG_CONF", "CrawlMaxDepth", *RagConfInfo.CrawlMaxDepth)
+	logger.Info("RAG_CONF", "CrawlMaxPages", *RagConfInfo.CrawlMaxPages)
+	logger.Info("RAG_CONF", "RagTopK", *RagConfInfo.RagTopK)
+	logger.Info("RAG_CONF", "RagScoreThreshold", *RagConfInfo.RagScoreThreshold)
+	logger.Info("RAG_CONF", "HybridSearch", *RagConfInfo.HybridSearch)
+	logger.Info("RAG_CONF", "RerankType", *RagConfInfo.RerankType)
+	logger.Info("RAG_CONF", "RerankURL", *RagConfInfo.RerankURL)
+	logger.Info("RAG_CONF", "RerankModel", *RagConfInfo.RerankModel)
+	logger.Info("RAG_CONF", "RagEvalFile", *RagConfInfo.RagEvalFile)
+	logger.Info("RAG_CONF", "RagEvalK", *RagConfInfo.RagEvalK)
+	logger.Info("RAG_CONF", "RagEvalGenerate", *RagConfInfo.RagEvalGenerate)
+}
+
+// GetChunkSize get chunk size and overlap of file extension, use chunk_size and chunk_overlap if not set
+func (r *RagConf) GetChunkSize(ext string) (int, int) {
+	chunkSize, chunkOverlap := *r.ChunkSize, *r.ChunkOverlap
+	if r.ExtChunkSize == nil || *r.ExtChunkSize == "" {
+		return chunkSize, chunkOverlap
+	}
+	
+	ext = strings.TrimPrefix(strings.ToLower(ext), ".")
+	for _, item := range strings.Split(*r.ExtChunkSize, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 || strings.TrimPrefix(strings.ToLower(parts[0]), ".") != ext {
+			continue
+		}
+		
+		if size, err := strconv.Atoi(parts[1]); err == nil && size > 0 {
+			chunkSize = size
+		}
+		if len(parts) > 2 {
+			if overlap, err := strconv.Atoi(parts[2]); err == nil && overlap >= 0 {
+				chunkOverlap = overlap
+			}
+		}
+		if chunkOverlap >= chunkSize {
+			chunkOverlap = chunkSize / 10
+		}
+		break
+	}
+	
+	return chunkSize, chunkOverlap
+}
+
+// GetNamespace get knowledge base namespace of chat, chat use its own namespace if not mapped
+func (r *RagConf) GetNamespace(chatId string) string {
+	if r.NamespaceMap == nil || *r.NamespaceMap == "" {
+		return chatId
+	}
+	
+	for _, item := range strings.Split(*r.NamespaceMap, ",") {
+		idx := strings.LastIndex(item, ":")
+		if idx <= 0 {
+			continue
+		}
+		if strings.TrimSpace(item[:idx]) == chatId {
+			return strings.TrimSpace(item[idx+1:])
+		}
+	}
+	
+	return chatId
 }
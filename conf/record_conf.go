@@ -0,0 +1,72 @@
+package conf
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+type RecordConf struct {
+	// records are written by background writer through a queue, 0 means writing synchronously
+	RecordQueueSize *int `json:"record_queue_size"`
+	
+	// records in one transaction
+	RecordBatchSize *int `json:"record_batch_size"`
+	
+	// milliseconds between two flushes if batch isn't full
+	RecordFlushInterval *int `json:"record_flush_interval"`
+	
+	// question, answer and content of records are encrypted if keys are set, format is id:base64key,
+	// first key encrypts new records and others decrypt old records
+	RecordEncryptKeys *string `json:"-"`
+	
+	// re-encrypt records by first key and exit
+	RotateRecordKey *bool `json:"rotate_record_key"`
+	
+	// secret of hmac which hashes user ids in deletion receipts, it is generated into ./data if empty,
+	// replicas must use the same secret
+	ReceiptSecret *string `json:"-"`
+}
+
+var (
+	RecordConfInfo = new(RecordConf)
+)
+
+func InitRecordConf() {
+	RecordConfInfo.RecordQueueSize = flag.Int("record_queue_size", 1000, "size of record write queue, 0 means writing synchronously")
+	RecordConfInfo.RecordBatchSize = flag.Int("record_batch_size", 100, "records written in one transaction")
+	RecordConfInfo.RecordFlushInterval = flag.Int("record_flush_interval", 200, "milliseconds between record flushes")
+	RecordConfInfo.RecordEncryptKeys = flag.String("record_encrypt_keys", "", "record encryption keys: key2:base64key,key1:base64key")
+	RecordConfInfo.RotateRecordKey = flag.Bool("rotate_record_key", false, "re-encrypt records by first key and exit")
+	RecordConfInfo.ReceiptSecret = flag.String("receipt_secret", "", "secret of hmac which hashes user ids in deletion receipts")
+}
+
+func EnvRecordConf() {
+	if os.Getenv("RECORD_QUEUE_SIZE") != "" {
+		*RecordConfInfo.RecordQueueSize, _ = strconv.Atoi(os.Getenv("RECORD_QUEUE_SIZE"))
+	}
+	if os.Getenv("RECORD_BATCH_SIZE") != "" {
+		*RecordConfInfo.RecordBatchSize, _ = strconv.Atoi(os.Getenv("RECORD_BATCH_SIZE"))
+	}
+	if os.Getenv("RECORD_FLUSH_INTERVAL") != "" {
+		*RecordConfInfo.RecordFlushInterval, _ = strconv.Atoi(os.Getenv("RECORD_FLUSH_INTERVAL"))
+	}
+	if os.Getenv("RECORD_ENCRYPT_KEYS") != "" {
+		*RecordConfInfo.RecordEncryptKeys = os.Getenv("RECORD_ENCRYPT_KEYS")
+	}
+	if os.Getenv("ROTATE_RECORD_KEY") != "" {
+		*RecordConfInfo.RotateRecordKey, _ = strconv.ParseBool(os.Getenv("ROTATE_RECORD_KEY"))
+	}
+	if os.Getenv("RECEIPT_SECRET") != "" {
+		*RecordConfInfo.ReceiptSecret = os.Getenv("RECEIPT_SECRET")
+	}
+	
+	logger.Info("RECORD_CONF", "RecordQueueSize", *RecordConfInfo.RecordQueueSize)
+	logger.Info("RECORD_CONF", "RecordBatchSize", *RecordConfInfo.RecordBatchSize)
+	logger.Info("RECORD_CONF", "RecordFlushInterval", *RecordConfInfo.RecordFlushInterval)
+	logger.Info("RECORD_CONF", "RecordEncrypt", *RecordConfInfo.RecordEncryptKeys != "")
+	logger.Info("RECORD_CONF", "RotateRecordKey", *RecordConfInfo.RotateRecordKey)
+	logger.Info("RECORD_CONF", "ReceiptSecret", *RecordConfInfo.ReceiptSecret != "")
+}
@@ -0,0 +1,52 @@
+package conf
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+type RetentionConf struct {
+	// soft deleted records are removed from db after purge_deleted_days, 0 means never
+	PurgeDeletedDays *int `json:"purge_deleted_days"`
+	
+	// records older than archive_days are moved to gzip jsonl files in archive_dir, 0 means never
+	ArchiveDays *int    `json:"archive_days"`
+	ArchiveDir  *string `json:"archive_dir"`
+	
+	// minutes between two retention jobs
+	RetentionInterval *int `json:"retention_interval"`
+}
+
+var (
+	RetentionConfInfo = new(RetentionConf)
+)
+
+func InitRetentionConf() {
+	RetentionConfInfo.PurgeDeletedDays = flag.Int("purge_deleted_days", 0, "hard delete soft deleted records after days, 0 means never")
+	RetentionConfInfo.ArchiveDays = flag.Int("archive_days", 0, "archive records older than days, 0 means never")
+	RetentionConfInfo.ArchiveDir = flag.String("archive_dir", "./data/archive", "directory of archived records")
+	RetentionConfInfo.RetentionInterval = flag.Int("retention_interval", 60, "minutes between retention jobs")
+}
+
+func EnvRetentionConf() {
+	if os.Getenv("PURGE_DELETED_DAYS") != "" {
+		*RetentionConfInfo.PurgeDeletedDays, _ = strconv.Atoi(os.Getenv("PURGE_DELETED_DAYS"))
+	}
+	if os.Getenv("ARCHIVE_DAYS") != "" {
+		*RetentionConfInfo.ArchiveDays, _ = strconv.Atoi(os.Getenv("ARCHIVE_DAYS"))
+	}
+	if os.Getenv("ARCHIVE_DIR") != "" {
+		*RetentionConfInfo.ArchiveDir = os.Getenv("ARCHIVE_DIR")
+	}
+	if os.Getenv("RETENTION_INTERVAL") != "" {
+		*RetentionConfInfo.RetentionInterval, _ = strconv.Atoi(os.Getenv("RETENTION_INTERVAL"))
+	}
+	
+	logger.Info("RETENTION_CONF", "PurgeDeletedDays", *RetentionConfInfo.PurgeDeletedDays)
+	logger.Info("RETENTION_CONF", "ArchiveDays", *RetentionConfInfo.ArchiveDays)
+	logger.Info("RETENTION_CONF", "ArchiveDir", *RetentionConfInfo.ArchiveDir)
+	logger.Info("RETENTION_CONF", "RetentionInterval", *RetentionConfInfo.RetentionInterval)
+}
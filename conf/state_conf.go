@@ -0,0 +1,64 @@
+package conf
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+type StateConf struct {
+	// memory keeps state in process, redis shares state between replicas
+	StateStore *string `json:"state_store"`
+	
+	RedisAddr     *string `json:"redis_addr"`
+	RedisPassword *string `json:"redis_password"`
+	RedisDB       *int    `json:"redis_db"`
+	
+	// prefix of keys, replicas of one bot must use same prefix
+	StatePrefix *string `json:"state_prefix"`
+	
+	// seconds conversation context is kept after last message
+	ContextTTL *int `json:"context_ttl"`
+}
+
+var (
+	StateConfInfo = new(StateConf)
+)
+
+func InitStateConf() {
+	StateConfInfo.StateStore = flag.String("state_store", "memory", "shared state store: memory redis")
+	StateConfInfo.RedisAddr = flag.String("redis_addr", "127.0.0.1:6379", "redis address")
+	StateConfInfo.RedisPassword = flag.String("redis_password", "", "redis password")
+	StateConfInfo.RedisDB = flag.Int("redis_db", 0, "redis db")
+	StateConfInfo.StatePrefix = flag.String("state_prefix", "musebot:", "prefix of state keys")
+	StateConfInfo.ContextTTL = flag.Int("context_ttl", 86400, "seconds conversation context is kept after last message")
+}
+
+func EnvStateConf() {
+	if os.Getenv("STATE_STORE") != "" {
+		*StateConfInfo.StateStore = os.Getenv("STATE_STORE")
+	}
+	if os.Getenv("REDIS_ADDR") != "" {
+		*StateConfInfo.RedisAddr = os.Getenv("REDIS_ADDR")
+	}
+	if os.Getenv("REDIS_PASSWORD") != "" {
+		*StateConfInfo.RedisPassword = os.Getenv("REDIS_PASSWORD")
+	}
+	if os.Getenv("REDIS_DB") != "" {
+		*StateConfInfo.RedisDB, _ = strconv.Atoi(os.Getenv("REDIS_DB"))
+	}
+	if os.Getenv("STATE_PREFIX") != "" {
+		*StateConfInfo.StatePrefix = os.Getenv("STATE_PREFIX")
+	}
+	if os.Getenv("CONTEXT_TTL") != "" {
+		*StateConfInfo.ContextTTL, _ = strconv.Atoi(os.Getenv("CONTEXT_TTL"))
+	}
+	
+	logger.Info("STATE_CONF", "StateStore", *StateConfInfo.StateStore)
+	logger.Info("STATE_CONF", "RedisAddr", *StateConfInfo.RedisAddr)
+	logger.Info("STATE_CONF", "RedisDB", *StateConfInfo.RedisDB)
+	logger.Info("STATE_CONF", "StatePrefix", *StateConfInfo.StatePrefix)
+	logger.Info("STATE_CONF", "ContextTTL", *StateConfInfo.ContextTTL)
+}
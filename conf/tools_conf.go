@@ -11,15 +11,23 @@ import (
 	"github.com/revrost/go-openrouter"
 	"github.com/sashabaranov/go-openai"
 	"github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/mcp-client-go/clients"
 	"github.com/yincongcyincong/mcp-client-go/utils"
-	"github.com/yincongcyincong/MuseBot/logger"
 	"google.golang.org/genai"
 )
 
 type AgentInfo struct {
+	Name        string `json:"-"`
 	Description string `json:"description"`
 	
+	// user defined agent conf
+	Prompt      string   `json:"prompt,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+	
 	DeepseekTool    []deepseek.Tool   `json:"-"`
 	VolTool         []*model.Tool     `json:"-"`
 	OpenAITools     []openai.Tool     `json:"-"`
@@ -28,7 +36,8 @@ type AgentInfo struct {
 }
 
 var (
-	McpConfPath *string
+	McpConfPath   *string
+	AgentConfPath *string
 	
 	DeepseekTools   = make([]deepseek.Tool, 0)
 	VolTools        = make([]*model.Tool, 0)
@@ -41,6 +50,7 @@ var (
 
 func InitToolsConf() {
 	McpConfPath = flag.String("mcp_conf_path", "./conf/mcp/mcp.json", "mcp conf path")
+	AgentConfPath = flag.String("agent_conf_path", "./conf/agent/agent.json", "user defined agent conf path")
 }
 
 func EnvToolsConf() {
@@ -48,7 +58,12 @@ func EnvToolsConf() {
 		*McpConfPath = os.Getenv("MCP_CONF_PATH")
 	}
 	
+	if os.Getenv("AGENT_CONF_PATH") != "" {
+		*AgentConfPath = os.Getenv("AGENT_CONF_PATH")
+	}
+	
 	logger.Info("TOOLS_CONF", "McpConfPath", *McpConfPath)
+	logger.Info("TOOLS_CONF", "AgentConfPath", *AgentConfPath)
 }
 
 func InitTools() {
@@ -68,6 +83,8 @@ func InitTools() {
 		for _, key := range keysToDelete {
 			TaskTools.Delete(key)
 		}
+		
+		InitAgents()
 	}()
 	
 	mcpParams, err := clients.InitByConfFile(*McpConfPath)
@@ -108,6 +125,7 @@ func InsertTools(clientName string) {
 		
 		if c.Conf.Description != "" {
 			TaskTools.Store(clientName, &AgentInfo{
+				Name:            clientName,
 				Description:     c.Conf.Description,
 				DeepseekTool:    dpTools,
 				VolTool:         volTools,
@@ -118,3 +136,77 @@ func InsertTools(clientName string) {
 		}
 	}
 }
+
+// MergeAgentInfo merge tools of several agents into one agent, tools with same name only keep the first one.
+// agents are ranked by relevance, the first agent decides prompt, type, model and temperature,
+// those of other agents are dropped with a warning.
+func MergeAgentInfo(agents ...*AgentInfo) *AgentInfo {
+	merged := new(AgentInfo)
+	dpNames, volNames, oaNames, gmNames, orNames := map[string]bool{}, map[string]bool{}, map[string]bool{},
+		map[string]bool{}, map[string]bool{}
+	for _, agent := range agents {
+		if agent == nil {
+			continue
+		}
+		
+		// the most relevant agent decides prompt and model
+		if merged.Name == "" {
+			merged.Name = agent.Name
+			merged.Description = agent.Description
+			merged.Prompt = agent.Prompt
+			merged.Type = agent.Type
+			merged.Model = agent.Model
+			merged.Temperature = agent.Temperature
+		} else if agent.Prompt != merged.Prompt || agent.Type != merged.Type || agent.Model != merged.Model {
+			logger.Warn("merged agent only use prompt and model of first agent", "agent", agent.Name, "first", merged.Name)
+		}
+		
+		for _, tool := range agent.DeepseekTool {
+			if !dpNames[tool.Function.Name] {
+				dpNames[tool.Function.Name] = true
+				merged.DeepseekTool = append(merged.DeepseekTool, tool)
+			}
+		}
+		
+		for _, tool := range agent.VolTool {
+			if tool.Function != nil && !volNames[tool.Function.Name] {
+				volNames[tool.Function.Name] = true
+				merged.VolTool = append(merged.VolTool, tool)
+			}
+		}
+		
+		for _, tool := range agent.OpenAITools {
+			if tool.Function != nil && !oaNames[tool.Function.Name] {
+				oaNames[tool.Function.Name] = true
+				merged.OpenAITools = append(merged.OpenAITools, tool)
+			}
+		}
+		
+		for _, tool := range agent.OpenRouterTools {
+			if tool.Function != nil && !orNames[tool.Function.Name] {
+				orNames[tool.Function.Name] = true
+				merged.OpenRouterTools = append(merged.OpenRouterTools, tool)
+			}
+		}
+		
+		for _, tool := range agent.GeminiTools {
+			gmTool := &genai.Tool{}
+			for _, function := range tool.FunctionDeclarations {
+				if !gmNames[function.Name] {
+					gmNames[function.Name] = true
+					gmTool.FunctionDeclarations = append(gmTool.FunctionDeclarations, function)
+				}
+			}
+			if len(gmTool.FunctionDeclarations) > 0 {
+				merged.GeminiTools = append(merged.GeminiTools, gmTool)
+			}
+		}
+	}
+	
+	return merged
+}
+
+// IsUserDefined whether agent comes from agent conf file
+func (a *AgentInfo) IsUserDefined() bool {
+	return a.Prompt != "" || a.Type != "" || a.Model != "" || a.Temperature != nil || len(a.Tools) > 0
+}
@@ -4,6 +4,10 @@ import (
 	"flag"
 	"os"
 	"testing"
+	
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
 )
 
 func TestInitConf_InitTools(t *testing.T) {
@@ -24,3 +28,37 @@ func TestInitConf_InitTools(t *testing.T) {
 func getPointBool(b bool) *bool {
 	return &b
 }
+
+func TestMergeAgentInfo(t *testing.T) {
+	github := &AgentInfo{
+		DeepseekTool: []deepseek.Tool{{Function: deepseek.Function{Name: "get_issue"}}},
+		OpenAITools:  []openai.Tool{{Function: &openai.FunctionDefinition{Name: "get_issue"}}},
+		GeminiTools:  []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "get_issue"}}}},
+	}
+	filesystem := &AgentInfo{
+		DeepseekTool: []deepseek.Tool{{Function: deepseek.Function{Name: "write_file"}}, {Function: deepseek.Function{Name: "get_issue"}}},
+		OpenAITools:  []openai.Tool{{Function: &openai.FunctionDefinition{Name: "write_file"}}},
+		GeminiTools:  []*genai.Tool{{FunctionDeclarations: []*genai.FunctionDeclaration{{Name: "get_issue"}}}},
+	}
+	
+	merged := MergeAgentInfo(github, nil, filesystem)
+	if len(merged.DeepseekTool) != 2 {
+		t.Errorf("%s expected %d, got %d", "deepseek tools number", 2, len(merged.DeepseekTool))
+	}
+	if len(merged.OpenAITools) != 2 {
+		t.Errorf("%s expected %d, got %d", "openai tools number", 2, len(merged.OpenAITools))
+	}
+	if len(merged.GeminiTools) != 1 {
+		t.Errorf("%s expected %d, got %d", "gemini tools number", 1, len(merged.GeminiTools))
+	}
+}
+
+func TestMergeAgentInfoFirstAgentWins(t *testing.T) {
+	translator := &AgentInfo{Name: "translator", Prompt: "translate", Type: "openai", Model: "gpt-4o"}
+	reader := &AgentInfo{Name: "reader", Prompt: "read", Type: "gemini", Model: "gemini-2.5-pro"}
+	
+	merged := MergeAgentInfo(translator, reader)
+	if merged.Name != "translator" || merged.Prompt != "translate" || merged.Type != "openai" || merged.Model != "gpt-4o" {
+		t.Errorf("%s expected %s, got %+v", "merged agent", "translator", merged)
+	}
+}
@@ -3,8 +3,12 @@ package db
 import (
 	"database/sql"
 	"testing"
-
+	
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
 func TestInitializeSqlite3Table(t *testing.T) {
@@ -14,20 +18,64 @@ func TestInitializeSqlite3Table(t *testing.T) {
 		t.Fatalf("Failed to open sqlite memory DB: %v", err)
 	}
 	defer db.Close()
-
-	// 执行初始化
-	err = initializeSqlite3Table(db, "users")
+	
+	// 执行迁移
+	count, err := utils.RunMigrations(db, getMigrations(utils.Sqlite3))
 	if err != nil {
-		t.Errorf("initializeSqlite3Table failed: %v", err)
+		t.Errorf("RunMigrations failed: %v", err)
 	}
+	assert.Equal(t, len(getMigrations(utils.Sqlite3)), count)
+	
+	// 验证表是否存在
+	for _, table := range []string{"users", "records", "rag_files", "rag_vectors"} {
+		var name string
+		err = db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name=?;`, table).Scan(&name)
+		if err != nil {
+			t.Fatalf("Table '%s' not found: %v", table, err)
+		}
+	}
+	
+	// 已执行的迁移不会重复执行
+	count, err = utils.RunMigrations(db, getMigrations(utils.Sqlite3))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
 
-	// 验证 users 表是否存在
-	var name string
-	err = db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name='users';`).Scan(&name)
+func TestMigrateOldSqlite3Table(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
-		t.Fatalf("Table 'users' not found: %v", err)
+		t.Fatalf("Failed to open sqlite memory DB: %v", err)
 	}
-	if name != "users" {
-		t.Errorf("Expected table name 'users', got '%s'", name)
+	defer db.Close()
+	
+	// table created by old version
+	_, err = db.Exec(`CREATE TABLE users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id varchar(100) NOT NULL DEFAULT '0',
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				update_time int(10) NOT NULL DEFAULT '0'
+			);
+			INSERT INTO users (user_id, mode, update_time) VALUES ('1', 'gpt', 1);`)
+	assert.Nil(t, err)
+	
+	oldPlatform := *conf.BaseConfInfo.LegacyPlatform
+	*conf.BaseConfInfo.LegacyPlatform = param.TelegramPlatform
+	defer func() {
+		*conf.BaseConfInfo.LegacyPlatform = oldPlatform
+	}()
+	_, err = utils.RunMigrations(db, getMigrations(utils.Sqlite3))
+	assert.Nil(t, err)
+	
+	for _, column := range []string{"token", "avail_token", "create_time"} {
+		exist, err := utils.ColumnExist(db, utils.Sqlite3, "users", column)
+		assert.Nil(t, err)
+		assert.True(t, exist, column)
 	}
+	
+	var mode string
+	var availToken int
+	err = db.QueryRow(`SELECT mode, avail_token FROM users WHERE user_id = 'telegram:1'`).Scan(&mode, &availToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "gpt", mode)
+	assert.Equal(t, 0, availToken)
 }
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const rotateBatchSize = 200
+
+var (
+	RecordKeyMissingErr = errors.New("record is encrypted but record_encrypt_keys is not set")
+	
+	// recordKeyring encrypt question, answer and content of records, nil means records are stored in plaintext
+	recordKeyring *utils.Keyring
+)
+
+// InitRecordKeyring load record encryption keys of conf
+func InitRecordKeyring() error {
+	keyring, err := utils.ParseKeyring(*conf.RecordConfInfo.RecordEncryptKeys)
+	if err != nil {
+		return err
+	}
+	recordKeyring = keyring
+	return nil
+}
+
+// encryptRecord return stored question, answer and content of record, they are encrypted if keys are set
+func encryptRecord(record *Record) (string, string, string, string, string, error) {
+	if recordKeyring == nil {
+		return record.Question, record.Answer, record.Content, "", "", nil
+	}
+	
+	ciphertexts, keyId, dataKey, err := recordKeyring.Seal(record.Question, record.Answer, record.Content)
+	if err != nil {
+		return "", "", "", "", "", err
+	}
+	return ciphertexts[0], ciphertexts[1], ciphertexts[2], keyId, dataKey, nil
+}
+
+// decryptRecord decrypt scanned record in place, plaintext record is kept
+func decryptRecord(record *Record) error {
+	if record.KeyId == "" {
+		return nil
+	}
+	if recordKeyring == nil {
+		return RecordKeyMissingErr
+	}
+	
+	plaintexts, err := recordKeyring.Open(record.KeyId, record.DataKey, record.Question, record.Answer, record.Content)
+	if err != nil {
+		return fmt.Errorf("decrypt record %d fail: %w", record.ID, err)
+	}
+	record.Question, record.Answer, record.Content = plaintexts[0], plaintexts[1], plaintexts[2]
+	record.KeyId, record.DataKey = "", ""
+	return nil
+}
+
+// RotateRecordKeys re-encrypt records which aren't encrypted by current key in batches, plaintext records
+// are encrypted too. return number of rotated records
+func RotateRecordKeys(w io.Writer) (int, error) {
+	if recordKeyring == nil {
+		return 0, RecordKeyMissingErr
+	}
+	
+	total, lastId := 0, 0
+	for {
+		count, id, err := rotateRecordBatch(lastId)
+		if err != nil {
+			return total, err
+		}
+		if count == 0 {
+			fmt.Fprintf(w, "rotated %d records to key %s\n", total, recordKeyring.CurrentId)
+			return total, nil
+		}
+		
+		total += count
+		lastId = id
+		fmt.Fprintf(w, "rotated %d records, last id %d\n", total, lastId)
+	}
+}
+
+// rotateRecordBatch re-encrypt a batch of records whose id is greater than last id in one transaction
+func rotateRecordBatch(lastId int) (int, int, error) {
+	rows, err := DB.Query(`SELECT id, question, answer, content, key_id, data_key FROM records
+		WHERE id > ? AND key_id <> ? ORDER BY id LIMIT ?`, lastId, recordKeyring.CurrentId, rotateBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	
+	var records []*Record
+	for rows.Next() {
+		record := new(Record)
+		err = rows.Scan(&record.ID, &record.Question, &record.Answer, &record.Content, &record.KeyId, &record.DataKey)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		records = append(records, record)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(records) == 0 {
+		return 0, 0, err
+	}
+	
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	
+	for _, record := range records {
+		if err = decryptRecord(record); err != nil {
+			return 0, 0, err
+		}
+		question, answer, content, keyId, dataKey, err := encryptRecord(record)
+		if err != nil {
+			return 0, 0, err
+		}
+		_, err = tx.Exec(`UPDATE records SET question = ?, answer = ?, content = ?, key_id = ?, data_key = ? WHERE id = ?`,
+			question, answer, content, keyId, dataKey, record.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	
+	if err = tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	logger.Info("rotate record keys success", "count", len(records), "lastId", records[len(records)-1].ID)
+	return len(records), records[len(records)-1].ID, nil
+}
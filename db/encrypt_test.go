@@ -0,0 +1,90 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestRecordEncryption(t *testing.T) {
+	oldDB := DB
+	defer func() {
+		DB.Close()
+		DB, recordKeyring = oldDB, nil
+	}()
+	
+	var err error
+	DB, err = utils.OpenDB(utils.Sqlite3, ":memory:")
+	assert.Nil(t, err)
+	DB.SetMaxOpenConns(1)
+	_, err = utils.RunMigrations(DB, getMigrations(utils.Sqlite3))
+	assert.Nil(t, err)
+	
+	key1 := "k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	key2 := "k2:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	
+	// plaintext record written before encryption is enabled
+	userId := "encrypt_user"
+	InsertRecordInfo(&Record{UserId: userId, Question: "plain question", Answer: "plain answer", RecordType: param.TextRecordType})
+	
+	recordKeyring, err = utils.ParseKeyring(key1)
+	assert.Nil(t, err)
+	InsertRecordInfo(&Record{UserId: userId, Question: "secret question", Answer: "secret answer", Content: "tool output",
+		RecordType: param.TextRecordType})
+		
+	var question, keyId string
+	assert.Nil(t, DB.QueryRow(`SELECT question, key_id FROM records WHERE id = 2`).Scan(&question, &keyId))
+	assert.NotContains(t, question, "secret")
+	assert.Equal(t, "k1", keyId)
+	
+	records, err := getRecordsByUserId(userId)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	for _, record := range records {
+		assert.Equal(t, "", record.KeyId)
+		if record.ID == 2 {
+			assert.Equal(t, "secret question", record.Question)
+			assert.Equal(t, "tool output", record.Content)
+		} else {
+			assert.Equal(t, "plain question", record.Question)
+		}
+	}
+	
+	list, err := GetRecordList(userId, 1, 10, 0, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret answer", list[0].Answer)
+	
+	searchRecords, err := SearchRecords(userId, "secret answer", 10)
+	assert.Nil(t, err)
+	assert.Len(t, searchRecords, 1)
+	assert.Equal(t, 2, searchRecords[0].ID)
+	
+	// records of old key and plaintext records are re-encrypted by new key
+	recordKeyring, err = utils.ParseKeyring(key2 + "," + key1)
+	assert.Nil(t, err)
+	buf := new(bytes.Buffer)
+	count, err := RotateRecordKeys(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Contains(t, buf.String(), "rotated 2 records to key k2")
+	
+	var keyCount int
+	assert.Nil(t, DB.QueryRow(`SELECT COUNT(*) FROM records WHERE key_id = 'k2'`).Scan(&keyCount))
+	assert.Equal(t, 2, keyCount)
+	
+	// old key isn't needed after rotation
+	recordKeyring, err = utils.ParseKeyring(key2)
+	assert.Nil(t, err)
+	record, err := GetRecordById(userId, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "plain answer", record.Answer)
+	
+	recordKeyring = nil
+	_, err = GetRecordById(userId, 1)
+	assert.ErrorIs(t, err, RecordKeyMissingErr)
+}
@@ -0,0 +1,353 @@
+package db
+
+import (
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/state"
+)
+
+const (
+	forgetCodeLen       = 6
+	forgetCodeExpire    = 5 * time.Minute
+	forgetCodeKeyPrefix = "forget_code:"
+	
+	// forgetting waits for running retention job, so that records aren't archived while they are deleted
+	forgetLockWait = time.Minute
+	
+	receiptSecretLen = 32
+)
+
+var (
+	ForgetCodeInvalidErr       = errors.New("confirmation code is invalid or expired")
+	DeletionReceiptTamperedErr = errors.New("deletion receipt is tampered")
+	
+	// ReceiptSecretPath file of generated receipt secret when receipt_secret isn't set
+	ReceiptSecretPath = "./data/receipt_secret"
+	
+	// receiptSecret key of hmac which hashes user ids, user ids can't be guessed from leaked receipts without it
+	receiptSecret []byte
+)
+
+// ForgetStats number of deleted data of user
+type ForgetStats struct {
+	Records         int64 `json:"records"`
+	ArchivedRecords int64 `json:"archived_records"`
+	Users           int64 `json:"users"`
+	Links           int64 `json:"links"`
+	RagFiles        int64 `json:"rag_files"`
+	Vectors         int64 `json:"vectors"`
+}
+
+// DeletionReceipt proof of user data deletion. user id is kept as hash, every receipt contains hash of previous one,
+// so that modifying or removing a receipt breaks the chain
+type DeletionReceipt struct {
+	ID         int64  `json:"id"`
+	UserHash   string `json:"user_hash"`
+	Detail     string `json:"detail"`
+	CreateTime int64  `json:"create_time"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// CreateForgetCode create code which user sends back to confirm deletion of all data
+func CreateForgetCode(userId string) (string, error) {
+	code := make([]byte, forgetCodeLen)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(linkCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = linkCodeAlphabet[n.Int64()]
+	}
+	
+	err := state.Default.Set(forgetCodeKeyPrefix+userId, code, forgetCodeExpire)
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+// CheckForgetCode check confirmation code of user, code can be used only once
+func CheckForgetCode(userId, code string) error {
+	key := forgetCodeKeyPrefix + userId
+	data, err := state.Default.Get(key)
+	if err != nil {
+		return err
+	}
+	if data == nil || string(data) != strings.ToUpper(strings.TrimSpace(code)) {
+		return ForgetCodeInvalidErr
+	}
+	return state.Default.Delete(key)
+}
+
+// ForgetUser hard delete records, archived records, quota, links and rag files of user, then write deletion receipt.
+// vectors and knowledge files must be deleted before, their numbers are passed by stats.
+func ForgetUser(userId string, stats *ForgetStats) (*DeletionReceipt, error) {
+	if err := FlushRecordWriter(RecordWriterStopTimeout); err != nil {
+		logger.Warn("flush record writer fail", "err", err)
+	}
+	
+	// retention lock also keeps receipts in order when replicas forget users at the same time
+	unlock, err := state.Lock(state.Default, retentionLockKey, retentionLockTTL, forgetLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	
+	stats.ArchivedRecords, err = forgetArchivedRecords(*conf.RetentionConfInfo.ArchiveDir, userId)
+	if err != nil {
+		return nil, fmt.Errorf("delete archived records fail: %v", err)
+	}
+	
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	deletes := []struct {
+		count *int64
+		query string
+		args  []interface{}
+	}{
+		{&stats.Records, `DELETE FROM records WHERE user_id = ?`, []interface{}{userId}},
+		{&stats.Users, `DELETE FROM users WHERE user_id = ?`, []interface{}{userId}},
+		{&stats.Links, `DELETE FROM user_links WHERE user_id = ? OR identity = ?`, []interface{}{userId, userId}},
+		{&stats.RagFiles, `DELETE FROM rag_files WHERE owner = ?`, []interface{}{userId}},
+		{nil, `DELETE FROM link_codes WHERE user_id = ?`, []interface{}{userId}},
+	}
+	for _, d := range deletes {
+		res, err := tx.Exec(d.query, d.args...)
+		if err != nil {
+			return nil, err
+		}
+		if d.count != nil {
+			*d.count, _ = res.RowsAffected()
+		}
+	}
+	
+	receipt, err := insertDeletionReceipt(tx, userId, stats)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	
+	deleteMsgRecordCache(userId)
+	state.Default.Delete(forgetCodeKeyPrefix + userId)
+	userIdCache.Clear()
+	logger.Info("forget user success", "receipt", receipt.ID, "userHash", receipt.UserHash, "hash", receipt.Hash)
+	return receipt, nil
+}
+
+// forgetArchivedRecords rewrite archive files which contain records of user without them
+func forgetArchivedRecords(dir, userId string) (int64, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "records_*.jsonl.gz"))
+	if err != nil {
+		return 0, err
+	}
+	
+	var total int64
+	for _, fileName := range fileNames {
+		count, err := rewriteArchive(fileName, userId)
+		if err != nil {
+			return total, fmt.Errorf("rewrite %s fail: %v", fileName, err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// rewriteArchive remove records of user from archive file, file is replaced only if it contains records of user
+func rewriteArchive(fileName, userId string) (int64, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	
+	tmpName := fileName + ".tmp"
+	out, err := os.Create(tmpName)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpName)
+	defer out.Close()
+	
+	zw := gzip.NewWriter(out)
+	dec := json.NewDecoder(zr)
+	enc := json.NewEncoder(zw)
+	var count int64
+	for {
+		record := new(Record)
+		err = dec.Decode(record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		
+		if record.UserId == userId {
+			count++
+			continue
+		}
+		if err = enc.Encode(record); err != nil {
+			return 0, err
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	
+	if err = zw.Close(); err != nil {
+		return 0, err
+	}
+	if err = out.Sync(); err != nil {
+		return 0, err
+	}
+	return count, os.Rename(tmpName, fileName)
+}
+
+// insertDeletionReceipt append receipt to the chain of receipts
+func insertDeletionReceipt(tx *sql.Tx, userId string, stats *ForgetStats) (*DeletionReceipt, error) {
+	detail, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+	
+	receipt := &DeletionReceipt{
+		UserHash:   HashUserId(userId),
+		Detail:     string(detail),
+		CreateTime: time.Now().Unix(),
+	}
+	err = tx.QueryRow(`SELECT hash FROM deletion_receipts ORDER BY id DESC LIMIT 1`).Scan(&receipt.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	receipt.Hash = receipt.computeHash()
+	
+	insertSQL := `INSERT INTO deletion_receipts (user_hash, detail, create_time, prev_hash, hash) VALUES (?, ?, ?, ?, ?)`
+	args := []interface{}{receipt.UserHash, receipt.Detail, receipt.CreateTime, receipt.PrevHash, receipt.Hash}
+	if isPostgres() {
+		err = tx.QueryRow(insertSQL+" RETURNING id", args...).Scan(&receipt.ID)
+		return receipt, err
+	}
+	
+	res, err := tx.Exec(insertSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	receipt.ID, err = res.LastInsertId()
+	return receipt, err
+}
+
+func (r *DeletionReceipt) computeHash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s\n%d", r.PrevHash, r.UserHash, r.Detail, r.CreateTime)))
+	return hex.EncodeToString(sum[:])
+}
+
+// InitReceiptSecret load receipt secret of conf, secret is generated and kept in file if it isn't set
+func InitReceiptSecret() error {
+	if *conf.RecordConfInfo.ReceiptSecret != "" {
+		receiptSecret = []byte(*conf.RecordConfInfo.ReceiptSecret)
+		return nil
+	}
+	
+	secret, err := os.ReadFile(ReceiptSecretPath)
+	if err == nil && len(strings.TrimSpace(string(secret))) > 0 {
+		receiptSecret = []byte(strings.TrimSpace(string(secret)))
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	
+	secret = make([]byte, receiptSecretLen)
+	if _, err = rand.Read(secret); err != nil {
+		return err
+	}
+	secret = []byte(hex.EncodeToString(secret))
+	if err = os.MkdirAll(filepath.Dir(ReceiptSecretPath), 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(ReceiptSecretPath, secret, 0600); err != nil {
+		return err
+	}
+	receiptSecret = secret
+	logger.Info("receipt secret generated", "path", ReceiptSecretPath)
+	return nil
+}
+
+// HashUserId get hmac of user id kept in deletion receipt, user can prove deletion with own id
+func HashUserId(userId string) string {
+	mac := hmac.New(sha256.New, receiptSecret)
+	mac.Write([]byte(userId))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetDeletionReceipts get receipts of user hash, all receipts are returned if user hash is empty
+func GetDeletionReceipts(userHash string) ([]*DeletionReceipt, error) {
+	querySQL := `SELECT id, user_hash, detail, create_time, prev_hash, hash FROM deletion_receipts`
+	args := make([]interface{}, 0)
+	if userHash != "" {
+		querySQL += ` WHERE user_hash = ?`
+		args = append(args, userHash)
+	}
+	
+	rows, err := DB.Query(querySQL+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	receipts := make([]*DeletionReceipt, 0)
+	for rows.Next() {
+		r := new(DeletionReceipt)
+		if err = rows.Scan(&r.ID, &r.UserHash, &r.Detail, &r.CreateTime, &r.PrevHash, &r.Hash); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+// VerifyDeletionReceipts check hash chain of all receipts, return number of receipts
+func VerifyDeletionReceipts() (int, error) {
+	receipts, err := GetDeletionReceipts("")
+	if err != nil {
+		return 0, err
+	}
+	
+	prevHash := ""
+	for _, r := range receipts {
+		if r.PrevHash != prevHash || r.Hash != r.computeHash() {
+			return 0, fmt.Errorf("%w: id %d", DeletionReceiptTamperedErr, r.ID)
+		}
+		prevHash = r.Hash
+	}
+	return len(receipts), nil
+}
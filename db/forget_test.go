@@ -0,0 +1,124 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestForgetCode(t *testing.T) {
+	userId := "telegram:forget_code"
+	code, err := CreateForgetCode(userId)
+	assert.Nil(t, err)
+	
+	assert.ErrorIs(t, CheckForgetCode("telegram:other", code), ForgetCodeInvalidErr)
+	assert.Nil(t, CheckForgetCode(userId, code))
+	// code can be used only once
+	assert.ErrorIs(t, CheckForgetCode(userId, code), ForgetCodeInvalidErr)
+}
+
+func TestForgetUser(t *testing.T) {
+	oldArchiveDir := *conf.RetentionConfInfo.ArchiveDir
+	defer func() {
+		*conf.RetentionConfInfo.ArchiveDir = oldArchiveDir
+	}()
+	*conf.RetentionConfInfo.ArchiveDir = t.TempDir()
+	
+	userId, otherId := "telegram:forget_user", "telegram:forget_other"
+	old := time.Now().AddDate(0, 0, -200).Unix()
+	_, err := DB.Exec(`INSERT INTO records (user_id, question, answer, content, create_time, is_deleted) VALUES
+		(?, 'q', 'a', '', ?, 0), (?, 'q', 'a', '', ?, 0)`, userId, old, otherId, old)
+	assert.Nil(t, err)
+	_, _, err = ArchiveRecords(*conf.RetentionConfInfo.ArchiveDir, time.Now().AddDate(0, 0, -180).Unix())
+	assert.Nil(t, err)
+	
+	InsertMsgRecord(userId, &AQ{Question: "q", Answer: "a"}, true)
+	InsertMsgRecord(otherId, &AQ{Question: "q", Answer: "a"}, true)
+	_, err = DB.Exec(`INSERT INTO user_links (identity, user_id, create_time) VALUES (?, ?, ?)`, "discord:forget_user", userId, 1)
+	assert.Nil(t, err)
+	_, err = DB.Exec(`INSERT INTO rag_files (file_name, file_md5, vector_id, owner, create_time, update_time) VALUES (?, ?, ?, ?, ?, ?)`,
+		"forget.txt", "md5", "v1,v2", userId, 1, 1)
+	assert.Nil(t, err)
+	
+	receipt, err := ForgetUser(userId, &ForgetStats{Vectors: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, HashUserId(userId), receipt.UserHash)
+	assert.JSONEq(t, `{"records":1,"archived_records":1,"users":1,"links":1,"rag_files":1,"vectors":2}`, receipt.Detail)
+	
+	count, err := GetRecordCount(userId, -1, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	user, err := GetUserByID(userId)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+	assert.Nil(t, GetMsgRecord(userId))
+	
+	// data of other users is kept
+	assert.NotNil(t, GetMsgRecord(otherId))
+	count, err = GetRecordCount(otherId, -1, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	archived, err := forgetArchivedRecords(*conf.RetentionConfInfo.ArchiveDir, otherId)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), archived)
+	
+	// receipts are chained
+	next, err := ForgetUser(otherId, new(ForgetStats))
+	assert.Nil(t, err)
+	assert.Equal(t, receipt.Hash, next.PrevHash)
+	_, err = VerifyDeletionReceipts()
+	assert.Nil(t, err)
+	
+	_, err = DB.Exec(`UPDATE deletion_receipts SET detail = '{}' WHERE id = ?`, receipt.ID)
+	assert.Nil(t, err)
+	_, err = VerifyDeletionReceipts()
+	assert.ErrorIs(t, err, DeletionReceiptTamperedErr)
+	_, err = DB.Exec(`DELETE FROM deletion_receipts`)
+	assert.Nil(t, err)
+}
+
+func TestReceiptSecret(t *testing.T) {
+	oldSecret, oldPath, oldConf := receiptSecret, ReceiptSecretPath, *conf.RecordConfInfo.ReceiptSecret
+	defer func() {
+		receiptSecret, ReceiptSecretPath, *conf.RecordConfInfo.ReceiptSecret = oldSecret, oldPath, oldConf
+	}()
+	
+	// secret is generated once and kept in file
+	ReceiptSecretPath = filepath.Join(t.TempDir(), "data", "receipt_secret")
+	*conf.RecordConfInfo.ReceiptSecret = ""
+	assert.Nil(t, InitReceiptSecret())
+	hash := HashUserId("telegram:1")
+	assert.Nil(t, InitReceiptSecret())
+	assert.Equal(t, hash, HashUserId("telegram:1"))
+	data, err := os.ReadFile(ReceiptSecretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, data, receiptSecret)
+	
+	// hash can't be computed from user id alone
+	sum := sha256.Sum256([]byte("telegram:1"))
+	assert.NotEqual(t, hex.EncodeToString(sum[:]), hash)
+	
+	*conf.RecordConfInfo.ReceiptSecret = "secret"
+	assert.Nil(t, InitReceiptSecret())
+	assert.NotEqual(t, hash, HashUserId("telegram:1"))
+}
+
+func TestRecordWriterFlush(t *testing.T) {
+	userId := "writer_flush_user"
+	writer := NewRecordWriter(10, 100, time.Hour)
+	defer writer.Close(time.Second)
+	
+	assert.Nil(t, writer.Add(&Record{UserId: userId, Question: "q", Answer: "a", RecordType: param.TextRecordType}))
+	assert.Nil(t, writer.Flush(time.Second))
+	
+	count, err := GetRecordCount(userId, 0, "0")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,367 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	linkCodeLen      = 8
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	linkCodeExpire   = 10 * time.Minute
+	
+	// resolved user id is cached for a while, links created by other replicas are seen after it expires
+	userIdCacheExpire = time.Minute
+)
+
+var (
+	LinkCodeInvalidErr = errors.New("link code is invalid or expired")
+	LinkSelfErr        = errors.New("account is already linked")
+	LegacyPlatformErr  = errors.New("users created by old version exist, set legacy_platform to platform they belong to")
+	
+	platforms = map[string]bool{
+		param.TelegramPlatform: true,
+		param.DiscordPlatform:  true,
+		param.SlackPlatform:    true,
+		param.LarkPlatform:     true,
+		param.WebPlatform:      true,
+	}
+	
+	// userIdCache identity to user id, it is cleared when links change
+	userIdCache sync.Map
+)
+
+type userIdCacheItem struct {
+	userId     string
+	expireTime time.Time
+}
+
+// QualifyUserId get user id qualified by platform, e.g. telegram:123, ids of platforms never collide
+func QualifyUserId(platform, platformUserId string) string {
+	return platform + ":" + platformUserId
+}
+
+// ParseUserId split qualified user id into platform and platform user id, platform is empty for legacy user id
+func ParseUserId(userId string) (string, string) {
+	platform, platformUserId, ok := strings.Cut(userId, ":")
+	if !ok || !platforms[platform] {
+		return "", userId
+	}
+	return platform, platformUserId
+}
+
+// ResolveUserId get user id of account which platform user belongs to. it is the qualified id unless the user is
+// linked to another account. user id which is already qualified is kept.
+func ResolveUserId(platform, platformUserId string) string {
+	if platformUserId == "" {
+		return ""
+	}
+	if p, id := ParseUserId(platformUserId); p != "" {
+		platform, platformUserId = p, id
+	}
+	
+	identity := QualifyUserId(platform, platformUserId)
+	if item, ok := userIdCache.Load(identity); ok && time.Now().Before(item.(*userIdCacheItem).expireTime) {
+		return item.(*userIdCacheItem).userId
+	}
+	
+	userId, err := getLinkedUserId(identity)
+	if err != nil {
+		logger.Warn("get linked user fail", "identity", identity, "err", err)
+		return identity
+	}
+	
+	if userId == "" {
+		userId = identity
+	}
+	
+	userIdCache.Store(identity, &userIdCacheItem{userId: userId, expireTime: time.Now().Add(userIdCacheExpire)})
+	return userId
+}
+
+// getLinkedUserId get user id which identity is linked to, empty if it isn't linked
+func getLinkedUserId(identity string) (string, error) {
+	var userId string
+	err := DB.QueryRow(`SELECT user_id FROM user_links WHERE identity = ?`, identity).Scan(&userId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return userId, err
+}
+
+// CreateLinkCode create one time code of user, another platform account enters it to link with this user
+func CreateLinkCode(userId string) (string, error) {
+	code := make([]byte, linkCodeLen)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(linkCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = linkCodeAlphabet[n.Int64()]
+	}
+	
+	now := time.Now()
+	_, err := DB.Exec(`DELETE FROM link_codes WHERE user_id = ? OR expire_time < ?`, userId, now.Unix())
+	if err != nil {
+		return "", err
+	}
+	
+	_, err = DB.Exec(`INSERT INTO link_codes (code, user_id, expire_time) VALUES (?, ?, ?)`,
+		string(code), userId, now.Add(linkCodeExpire).Unix())
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+// LinkUser merge quota, history and settings of user into account which created code, code can be used only once.
+// it returns user id of the merged account.
+func LinkUser(code, userId string) (string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	
+	code = strings.ToUpper(strings.TrimSpace(code))
+	var targetId string
+	err = tx.QueryRow(`SELECT user_id FROM link_codes WHERE code = ? AND expire_time >= ?`,
+		code, time.Now().Unix()).Scan(&targetId)
+	if err == sql.ErrNoRows {
+		return "", LinkCodeInvalidErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if targetId == userId {
+		return "", LinkSelfErr
+	}
+	
+	_, err = tx.Exec(`DELETE FROM link_codes WHERE code = ?`, code)
+	if err != nil {
+		return "", err
+	}
+	
+	err = mergeUser(tx, userId, targetId)
+	if err != nil {
+		return "", err
+	}
+	
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+	
+	// conversation context is moved to merged account
+	moveMsgRecord(userId, targetId, false)
+	userIdCache.Clear()
+	logger.Info("link user success", "userId", userId, "targetId", targetId)
+	return targetId, nil
+}
+
+// mergeUser move records and quota of user to target, mode of target is kept if it is set
+func mergeUser(tx *sql.Tx, userId, targetId string) error {
+	var mode string
+	var token, availToken int
+	err := tx.QueryRow(`SELECT mode, token, avail_token FROM users WHERE user_id = ?`, userId).Scan(&mode, &token, &availToken)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	userExist := err == nil
+	
+	var targetCount int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM users WHERE user_id = ?`, targetId).Scan(&targetCount)
+	if err != nil {
+		return err
+	}
+	
+	if userExist && targetCount == 0 {
+		targetPlatform, _ := ParseUserId(targetId)
+		_, err = tx.Exec(`UPDATE users SET user_id = ?, platform = ? WHERE user_id = ?`, targetId, targetPlatform, userId)
+		if err != nil {
+			return err
+		}
+	} else if userExist {
+		_, err = tx.Exec(`UPDATE users SET token = token + ?, avail_token = avail_token + ?, update_time = ? WHERE user_id = ?`,
+			token, availToken, time.Now().Unix(), targetId)
+		if err != nil {
+			return err
+		}
+		
+		_, err = tx.Exec(`UPDATE users SET mode = ? WHERE user_id = ? AND mode = ''`, mode, targetId)
+		if err != nil {
+			return err
+		}
+		
+		_, err = tx.Exec(`DELETE FROM users WHERE user_id = ?`, userId)
+		if err != nil {
+			return err
+		}
+	}
+	
+	_, err = tx.Exec(`UPDATE records SET user_id = ? WHERE user_id = ?`, targetId, userId)
+	if err != nil {
+		return err
+	}
+	
+	// identities linked to user are linked to target too
+	_, err = tx.Exec(`UPDATE user_links SET user_id = ? WHERE user_id = ?`, targetId, userId)
+	if err != nil {
+		return err
+	}
+	
+	_, err = tx.Exec(`INSERT INTO user_links (identity, user_id, create_time) VALUES (?, ?, ?)`,
+		userId, targetId, time.Now().Unix())
+	return err
+}
+
+// getLegacyPlatform get platform which users of old version belong to, it is legacy_platform,
+// or the only bot platform which is configured
+func getLegacyPlatform() (string, error) {
+	if *conf.BaseConfInfo.LegacyPlatform != "" {
+		if !platforms[*conf.BaseConfInfo.LegacyPlatform] {
+			return "", fmt.Errorf("unknown legacy_platform: %s", *conf.BaseConfInfo.LegacyPlatform)
+		}
+		return *conf.BaseConfInfo.LegacyPlatform, nil
+	}
+	
+	configured := make([]string, 0)
+	if *conf.BaseConfInfo.TelegramBotToken != "" {
+		configured = append(configured, param.TelegramPlatform)
+	}
+	if *conf.BaseConfInfo.DiscordBotToken != "" {
+		configured = append(configured, param.DiscordPlatform)
+	}
+	if *conf.BaseConfInfo.SlackBotToken != "" {
+		configured = append(configured, param.SlackPlatform)
+	}
+	if *conf.BaseConfInfo.LarkAPPID != "" {
+		configured = append(configured, param.LarkPlatform)
+	}
+	if len(configured) != 1 {
+		return "", LegacyPlatformErr
+	}
+	return configured[0], nil
+}
+
+// qualifyLegacyUsers migration which qualifies user ids of users, records and rag file owners saved without platform
+// by legacy platform, so that same id of another platform never gets their quota and history
+func qualifyLegacyUsers(dialect string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		var legacyCount int
+		err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM users WHERE platform = '') + (SELECT COUNT(*) FROM records WHERE platform = '')`).
+			Scan(&legacyCount)
+		if err != nil || legacyCount == 0 {
+			return err
+		}
+		
+		platform, err := getLegacyPlatform()
+		if err != nil {
+			return err
+		}
+		
+		qualify := func(column string) string {
+			if dialect == utils.Mysql {
+				return "CONCAT(?, " + column + ")"
+			}
+			return "? || " + column
+		}
+		prefix := QualifyUserId(platform, "")
+		_, err = db.Exec(`UPDATE users SET user_id = `+qualify("user_id")+`, platform = ? WHERE platform = ''`, prefix, platform)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`UPDATE records SET user_id = `+qualify("user_id")+`, platform = ? WHERE platform = ''`, prefix, platform)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`UPDATE rag_files SET owner = `+qualify("owner")+` WHERE owner != '' AND owner NOT LIKE '%:%'`, prefix)
+		if err != nil {
+			return err
+		}
+		
+		logger.Info("qualify legacy users success", "platform", platform)
+		return nil
+	}
+}
+
+type duplicateUser struct {
+	platform string
+	userId   string
+}
+
+// mergeDuplicateUsers migration which merges rows of same user before unique index is created,
+// first row is kept with sum of token and available token
+func mergeDuplicateUsers(db *sql.DB) error {
+	rows, err := db.Query(`SELECT platform, user_id FROM users GROUP BY platform, user_id HAVING COUNT(*) > 1`)
+	if err != nil {
+		return err
+	}
+	duplicates := make([]*duplicateUser, 0)
+	for rows.Next() {
+		d := new(duplicateUser)
+		if err = rows.Scan(&d.platform, &d.userId); err != nil {
+			rows.Close()
+			return err
+		}
+		duplicates = append(duplicates, d)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	
+	for _, d := range duplicates {
+		err = mergeDuplicateUser(db, d)
+		if err != nil {
+			return err
+		}
+		logger.Info("merge duplicate user success", "platform", d.platform, "userId", d.userId)
+	}
+	return nil
+}
+
+func mergeDuplicateUser(db *sql.DB, d *duplicateUser) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	var keepId int64
+	var token, availToken, updateTime, createTime int64
+	var mode string
+	err = tx.QueryRow(`SELECT MIN(id), SUM(token), SUM(avail_token), MAX(update_time), MIN(create_time) FROM users
+		WHERE platform = ? AND user_id = ?`, d.platform, d.userId).Scan(&keepId, &token, &availToken, &updateTime, &createTime)
+	if err != nil {
+		return err
+	}
+	
+	// mode of first row which sets it is kept
+	err = tx.QueryRow(`SELECT mode FROM users WHERE platform = ? AND user_id = ? AND mode != '' ORDER BY id LIMIT 1`,
+		d.platform, d.userId).Scan(&mode)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	
+	_, err = tx.Exec(`UPDATE users SET token = ?, avail_token = ?, update_time = ?, create_time = ?, mode = ? WHERE id = ?`,
+		token, availToken, updateTime, createTime, mode, keepId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM users WHERE platform = ? AND user_id = ? AND id != ?`, d.platform, d.userId, keepId)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestParseUserId(t *testing.T) {
+	platform, userId := ParseUserId(QualifyUserId(param.DiscordPlatform, "123"))
+	assert.Equal(t, param.DiscordPlatform, platform)
+	assert.Equal(t, "123", userId)
+	
+	platform, userId = ParseUserId("123")
+	assert.Equal(t, "", platform)
+	assert.Equal(t, "123", userId)
+	
+	platform, userId = ParseUserId("unknown:123")
+	assert.Equal(t, "", platform)
+	assert.Equal(t, "unknown:123", userId)
+}
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	oldDB, oldPlatform, oldToken := DB, *conf.BaseConfInfo.LegacyPlatform, *conf.BaseConfInfo.TelegramBotToken
+	defer func() {
+		DB.Close()
+		DB, *conf.BaseConfInfo.LegacyPlatform, *conf.BaseConfInfo.TelegramBotToken = oldDB, oldPlatform, oldToken
+		userIdCache.Clear()
+	}()
+	
+	var err error
+	DB, err = utils.OpenDB(utils.Sqlite3, ":memory:")
+	assert.Nil(t, err)
+	DB.SetMaxOpenConns(1)
+	
+	// users, records and rag files saved by version without platform, legacy_777 has two rows
+	migrations := getMigrations(utils.Sqlite3)
+	_, err = utils.RunMigrations(DB, migrations[:4])
+	assert.Nil(t, err)
+	_, err = DB.Exec(`INSERT INTO users (user_id, mode, token, avail_token, update_time, create_time) VALUES
+		('legacy_777', '', 10, 100, 5, 2), ('legacy_777', 'gpt', 20, 50, 9, 1), ('legacy_888', '', 1, 1, 1, 1)`)
+	assert.Nil(t, err)
+	_, err = DB.Exec(`INSERT INTO records (user_id, question, answer, content, create_time) VALUES ('legacy_777', 'q', 'a', '', 1)`)
+	assert.Nil(t, err)
+	_, err = DB.Exec(`INSERT INTO rag_files (file_name, file_md5, owner) VALUES ('a.txt', 'md5', 'legacy_777')`)
+	assert.Nil(t, err)
+	
+	// platform of legacy users can't be guessed
+	*conf.BaseConfInfo.LegacyPlatform, *conf.BaseConfInfo.TelegramBotToken = "", ""
+	_, err = utils.RunMigrations(DB, migrations)
+	assert.ErrorIs(t, err, LegacyPlatformErr)
+	
+	*conf.BaseConfInfo.LegacyPlatform = param.TelegramPlatform
+	count, err := utils.RunMigrations(DB, migrations)
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations)-4, count)
+	
+	userId := QualifyUserId(param.TelegramPlatform, "legacy_777")
+	user, err := GetUserByID(userId)
+	assert.Nil(t, err)
+	if assert.NotNil(t, user) {
+		assert.Equal(t, 30, user.Token)
+		assert.Equal(t, 150, user.AvailToken)
+		assert.Equal(t, "gpt", user.Mode)
+	}
+	user, err = GetUserByID("legacy_777")
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+	
+	var recordUserId, platform string
+	assert.Nil(t, DB.QueryRow(`SELECT user_id, platform FROM records`).Scan(&recordUserId, &platform))
+	assert.Equal(t, userId, recordUserId)
+	assert.Equal(t, param.TelegramPlatform, platform)
+	
+	ragFiles, err := GetRagFileByFileName("a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, userId, ragFiles[0].Owner)
+	
+	// same id of another platform doesn't get legacy user
+	assert.Equal(t, "web:legacy_888", ResolveUserId(param.WebPlatform, "legacy_888"))
+	user, err = GetUserByID(QualifyUserId(param.TelegramPlatform, "legacy_888"))
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	
+	// qualified user id is kept
+	assert.Equal(t, userId, ResolveUserId(param.WebPlatform, userId))
+	assert.Equal(t, "", ResolveUserId(param.WebPlatform, ""))
+}
+
+func TestLinkUser(t *testing.T) {
+	targetId := QualifyUserId(param.TelegramPlatform, "link_1")
+	userId := QualifyUserId(param.DiscordPlatform, "link_2")
+	_, err := InsertUser(targetId, "")
+	assert.Nil(t, err)
+	_, err = InsertUser(userId, "gpt-4o")
+	assert.Nil(t, err)
+	assert.Nil(t, AddToken(userId, 30))
+	InsertRecordInfo(&Record{UserId: userId, Question: "link question", Answer: "link answer", Token: 5})
+	
+	code, err := CreateLinkCode(targetId)
+	assert.Nil(t, err)
+	assert.Len(t, code, linkCodeLen)
+	
+	_, err = LinkUser(code, targetId)
+	assert.ErrorIs(t, err, LinkSelfErr)
+	
+	linkedId, err := LinkUser(code, userId)
+	assert.Nil(t, err)
+	assert.Equal(t, targetId, linkedId)
+	
+	// code can be used only once
+	_, err = LinkUser(code, userId)
+	assert.ErrorIs(t, err, LinkCodeInvalidErr)
+	
+	user, err := GetUserByID(targetId)
+	assert.Nil(t, err)
+	assert.Equal(t, 35, user.Token)
+	assert.Equal(t, "gpt-4o", user.Mode)
+	
+	user, err = GetUserByID(userId)
+	assert.Nil(t, err)
+	assert.Nil(t, user)
+	
+	records, err := SearchRecords(targetId, "link", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	
+	assert.Equal(t, targetId, ResolveUserId(param.DiscordPlatform, "link_2"))
+}
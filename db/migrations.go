@@ -0,0 +1,283 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	sqlite3CreateTableSQL = `
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id varchar(100) NOT NULL DEFAULT '0',
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				update_time int(10) NOT NULL DEFAULT '0',
+				token int(10) NOT NULL DEFAULT '0',
+				avail_token int(10) NOT NULL DEFAULT 0,
+				create_time int(10) NOT NULL DEFAULT '0'
+			);
+			CREATE TABLE IF NOT EXISTS records (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id varchar(100) NOT NULL DEFAULT '0',
+				question TEXT NOT NULL,
+				answer TEXT NOT NULL,
+				content TEXT NOT NULL,
+				create_time int(10) NOT NULL DEFAULT '0',
+				update_time int(10) NOT NULL DEFAULT '0',
+				is_deleted int(10) NOT NULL DEFAULT '0',
+				token int(10) NOT NULL DEFAULT 0,
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				record_type tinyint(1) NOT NULL DEFAULT 0
+			);
+			CREATE TABLE IF NOT EXISTS rag_files (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				file_name VARCHAR(255) NOT NULL DEFAULT '',
+				file_md5 VARCHAR(255) NOT NULL DEFAULT '',
+				vector_id TEXT NOT NULL DEFAULT '',
+				owner varchar(100) NOT NULL DEFAULT '',
+				create_time int(10) NOT NULL DEFAULT '0',
+				update_time int(10) NOT NULL DEFAULT '0',
+				is_deleted int(10) NOT NULL DEFAULT '0'
+			);
+			CREATE TABLE IF NOT EXISTS rag_vectors (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				vector_id VARCHAR(64) NOT NULL DEFAULT '',
+				content TEXT NOT NULL,
+				metadata TEXT NOT NULL,
+				embedding BLOB NOT NULL,
+				create_time int(10) NOT NULL DEFAULT '0'
+			);`
+			
+	mysqlCreateUsersSQL = `
+			CREATE TABLE IF NOT EXISTS users (
+				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				user_id varchar(100) NOT NULL DEFAULT 0,
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				update_time INT(10) NOT NULL DEFAULT 0,
+				token int(10) NOT NULL DEFAULT 0,
+				avail_token int(10) NOT NULL DEFAULT 0,
+				create_time int(10) NOT NULL DEFAULT '0'
+			);`
+			
+	mysqlCreateRecordsSQL = `
+			CREATE TABLE IF NOT EXISTS records (
+				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				user_id varchar(100) NOT NULL DEFAULT 0,
+				question MEDIUMTEXT NOT NULL,
+				answer MEDIUMTEXT NOT NULL,
+				content MEDIUMTEXT NOT NULL,
+				create_time int(10) NOT NULL DEFAULT '0',
+				update_time int(10) NOT NULL DEFAULT '0',
+				is_deleted int(10) NOT NULL DEFAULT '0',
+				token int(10) NOT NULL DEFAULT 0,
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				record_type tinyint(1) NOT NULL DEFAULT 0 COMMENT '0:text, 1:image 2:video 3: web'
+			);`
+			
+	mysqlCreateRagFileSQL = `
+			CREATE TABLE IF NOT EXISTS rag_files (
+				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				file_name VARCHAR(255) NOT NULL DEFAULT '',
+				file_md5 VARCHAR(255) NOT NULL DEFAULT '',
+				vector_id TEXT NOT NULL,
+				owner varchar(100) NOT NULL DEFAULT '',
+				create_time int(10) NOT NULL DEFAULT '0',
+				update_time int(10) NOT NULL DEFAULT '0',
+				is_deleted int(10) NOT NULL DEFAULT '0'
+			);`
+			
+	mysqlCreateRagVectorSQL = `
+			CREATE TABLE IF NOT EXISTS rag_vectors (
+				id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				vector_id VARCHAR(64) NOT NULL DEFAULT '',
+				content MEDIUMTEXT NOT NULL,
+				metadata MEDIUMTEXT NOT NULL,
+				embedding MEDIUMBLOB NOT NULL,
+				create_time int(10) NOT NULL DEFAULT '0'
+			);`
+			
+	postgresCreateTableSQL = `
+			CREATE TABLE IF NOT EXISTS users (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(100) NOT NULL DEFAULT '0',
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				update_time BIGINT NOT NULL DEFAULT 0,
+				token INT NOT NULL DEFAULT 0,
+				avail_token INT NOT NULL DEFAULT 0,
+				create_time BIGINT NOT NULL DEFAULT 0
+			);
+			CREATE TABLE IF NOT EXISTS records (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(100) NOT NULL DEFAULT '0',
+				question TEXT NOT NULL,
+				answer TEXT NOT NULL,
+				content TEXT NOT NULL,
+				create_time BIGINT NOT NULL DEFAULT 0,
+				update_time BIGINT NOT NULL DEFAULT 0,
+				is_deleted INT NOT NULL DEFAULT 0,
+				token INT NOT NULL DEFAULT 0,
+				mode VARCHAR(100) NOT NULL DEFAULT '',
+				record_type SMALLINT NOT NULL DEFAULT 0
+			);
+			CREATE TABLE IF NOT EXISTS rag_files (
+				id BIGSERIAL PRIMARY KEY,
+				file_name VARCHAR(255) NOT NULL DEFAULT '',
+				file_md5 VARCHAR(255) NOT NULL DEFAULT '',
+				vector_id TEXT NOT NULL DEFAULT '',
+				owner VARCHAR(100) NOT NULL DEFAULT '',
+				create_time BIGINT NOT NULL DEFAULT 0,
+				update_time BIGINT NOT NULL DEFAULT 0,
+				is_deleted INT NOT NULL DEFAULT 0
+			);
+			CREATE TABLE IF NOT EXISTS rag_vectors (
+				id BIGSERIAL PRIMARY KEY,
+				vector_id VARCHAR(64) NOT NULL DEFAULT '',
+				content TEXT NOT NULL,
+				metadata TEXT NOT NULL,
+				embedding BYTEA NOT NULL,
+				create_time BIGINT NOT NULL DEFAULT 0
+			);`
+			
+	createUserLinksSQL = `CREATE TABLE IF NOT EXISTS user_links (
+				identity VARCHAR(100) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(100) NOT NULL DEFAULT '',
+				create_time BIGINT NOT NULL DEFAULT 0
+			)`
+	
+	createLinkCodesSQL = `CREATE TABLE IF NOT EXISTS link_codes (
+				code VARCHAR(20) NOT NULL PRIMARY KEY,
+				user_id VARCHAR(100) NOT NULL DEFAULT '',
+				expire_time BIGINT NOT NULL DEFAULT 0
+			)`
+)
+
+// getMigrations get ordered migrations of dialect, new migration is appended with next version,
+// applied migrations must not be changed
+func getMigrations(dialect string) []*utils.Migration {
+	var createTables []string
+	switch dialect {
+	case utils.Sqlite3:
+		createTables = []string{sqlite3CreateTableSQL}
+	case utils.Mysql:
+		createTables = []string{mysqlCreateUsersSQL, mysqlCreateRecordsSQL, mysqlCreateRagFileSQL, mysqlCreateRagVectorSQL}
+	case utils.Postgres:
+		createTables = []string{postgresCreateTableSQL}
+	default:
+		return nil
+	}
+	
+	intType, timeType, tinyintType := "int(10)", "int(10)", "tinyint(1)"
+	if dialect == utils.Postgres {
+		intType, timeType, tinyintType = "INT", "BIGINT", "SMALLINT"
+	}
+	idType := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	switch dialect {
+	case utils.Mysql:
+		idType = "BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY"
+	case utils.Postgres:
+		idType = "BIGSERIAL PRIMARY KEY"
+	}
+	
+	return []*utils.Migration{
+		{
+			Version: 1,
+			Name:    "create_tables",
+			Up:      utils.ExecSQL(createTables...),
+		},
+		{
+			// tables created by old version lack columns added later
+			Version: 2,
+			Name:    "add_missing_columns",
+			Up: utils.Migrations(
+				utils.AddColumn(dialect, "users", "token", intType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "users", "avail_token", intType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "users", "create_time", timeType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "records", "update_time", timeType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "records", "token", intType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "records", "mode", "VARCHAR(100) NOT NULL DEFAULT ''"),
+				utils.AddColumn(dialect, "records", "record_type", tinyintType+" NOT NULL DEFAULT 0"),
+				utils.AddColumn(dialect, "rag_files", "owner", "VARCHAR(100) NOT NULL DEFAULT ''"),
+			),
+		},
+		{
+			Version: 3,
+			Name:    "create_indexes",
+			Up: utils.Migrations(
+				utils.CreateIndex(dialect, "users", "idx_users_user_id", "user_id", false),
+				utils.CreateIndex(dialect, "records", "idx_records_user_id", "user_id", false),
+				utils.CreateIndex(dialect, "records", "idx_records_create_time", "create_time", false),
+				utils.CreateIndex(dialect, "rag_vectors", "uniq_rag_vectors_vector_id", "vector_id", true),
+			),
+		},
+		{
+			Version: 4,
+			Name:    "create_records_fulltext",
+			Up:      createRecordsFulltext(dialect),
+		},
+		{
+			// user id is qualified by platform like telegram:123, legacy users are assigned to legacy platform
+			Version: 5,
+			Name:    "add_platform_identity",
+			Up: utils.Migrations(
+				utils.AddColumn(dialect, "users", "platform", "VARCHAR(20) NOT NULL DEFAULT ''"),
+				utils.AddColumn(dialect, "records", "platform", "VARCHAR(20) NOT NULL DEFAULT ''"),
+				utils.ExecSQL(createUserLinksSQL, createLinkCodesSQL),
+				qualifyLegacyUsers(dialect),
+				mergeDuplicateUsers,
+				utils.CreateIndex(dialect, "users", "uniq_users_platform_user_id", "platform, user_id", true),
+			),
+		},
+		{
+			// question, answer and content are encrypted by data key of row, data key is encrypted by master key of key_id
+			Version: 6,
+			Name:    "add_record_encryption",
+			Up: utils.Migrations(
+				utils.AddColumn(dialect, "records", "key_id", "VARCHAR(64) NOT NULL DEFAULT ''"),
+				utils.AddColumn(dialect, "records", "data_key", "VARCHAR(255) NOT NULL DEFAULT ''"),
+			),
+		},
+		{
+			// receipts of /forgetme, every receipt contains hash of previous one
+			Version: 7,
+			Name:    "create_deletion_receipts",
+			Up: utils.ExecSQL(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS deletion_receipts (
+				id %s,
+				user_hash VARCHAR(64) NOT NULL DEFAULT '',
+				detail TEXT NOT NULL,
+				create_time %s NOT NULL DEFAULT 0,
+				prev_hash VARCHAR(64) NOT NULL DEFAULT '',
+				hash VARCHAR(64) NOT NULL DEFAULT ''
+			)`, idType, timeType)),
+		},
+	}
+}
+
+// RunMigrations apply pending migrations of bot db
+func RunMigrations() (int, error) {
+	migrations := getMigrations(*conf.BaseConfInfo.DBType)
+	if migrations == nil {
+		return 0, fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
+	}
+	count, err := utils.RunMigrations(DB, migrations)
+	if err != nil {
+		return count, err
+	}
+	return count, ensureRecordsFTS()
+}
+
+// Migrate print migration status, pending migrations are applied first if action is up
+func Migrate(w io.Writer, action string) error {
+	migrations := getMigrations(*conf.BaseConfInfo.DBType)
+	if migrations == nil {
+		return fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
+	}
+	
+	err := utils.Migrate(w, DB, migrations, action)
+	if err != nil || action != "up" {
+		return err
+	}
+	return ensureRecordsFTS()
+}
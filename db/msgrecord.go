@@ -2,32 +2,55 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
 	
-	"github.com/cohesion-org/deepseek-go"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/state"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
-const MaxQAPair = 10
+const (
+	MaxQAPair = 10
+	
+	msgRecordKeyPrefix = "msg_record:"
+	msgRecordLockTTL   = 5 * time.Second
+	msgRecordLockWait  = 3 * time.Second
+	
+	defaultMsgRecordTTL = 24 * time.Hour
+)
+
+var (
+	// dirtyUpdateTimes update time of contexts changed by this replica, they are written into db by UpdateDBData
+	dirtyLock        sync.Mutex
+	dirtyUpdateTimes = make(map[string]int64)
+)
 
 type MsgRecordInfo struct {
-	AQs        []*AQ
-	updateTime int64
+	AQs        []*AQ `json:"aqs"`
+	UpdateTime int64 `json:"update_time"`
+}
+
+// sealedMsgRecord context encrypted by record keyring
+type sealedMsgRecord struct {
+	KeyId   string `json:"key_id"`
+	DataKey string `json:"data_key"`
+	Data    string `json:"data"`
 }
 
 type AQ struct {
-	Question string
-	Answer   string
-	Content  string
-	Token    int
-	Mode     string
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Content  string `json:"content"`
+	Token    int    `json:"token"`
+	Mode     string `json:"mode"`
 }
 
 type Record struct {
@@ -42,30 +65,18 @@ type Record struct {
 	RecordType int    `json:"record_type"`
 	Mode       string `json:"mode"`
 	UpdateTime int64  `json:"update_time"`
+	
+	// key id and encrypted data key of encrypted record, they are empty after record is decrypted
+	KeyId   string `json:"key_id,omitempty"`
+	DataKey string `json:"data_key,omitempty"`
 }
 
-var MsgRecord = sync.Map{}
-
+// InsertMsgRecord append aq to conversation context of user, record is written into db by background writer
 func InsertMsgRecord(userId string, aq *AQ, insertDB bool) {
-	var msgRecord *MsgRecordInfo
-	msgRecordInter, ok := MsgRecord.Load(userId)
-	if !ok {
-		msgRecord = &MsgRecordInfo{
-			AQs:        []*AQ{aq},
-			updateTime: time.Now().Unix(),
-		}
-	} else {
-		msgRecord = msgRecordInter.(*MsgRecordInfo)
-		msgRecord.AQs = append(msgRecord.AQs, aq)
-		if len(msgRecord.AQs) > MaxQAPair {
-			msgRecord.AQs = msgRecord.AQs[1:]
-		}
-		msgRecord.updateTime = time.Now().Unix()
-	}
-	MsgRecord.Store(userId, msgRecord)
+	appendMsgRecord(userId, aq)
 	
 	if insertDB {
-		go InsertRecordInfo(&Record{
+		InsertRecordAsync(&Record{
 			UserId:     userId,
 			Question:   aq.Question,
 			Answer:     aq.Answer,
@@ -77,16 +88,135 @@ func InsertMsgRecord(userId string, aq *AQ, insertDB bool) {
 	}
 }
 
+// appendMsgRecord append aq to context in state store, context is locked so that replicas don't overwrite each other
+func appendMsgRecord(userId string, aq *AQ) {
+	unlock, err := state.Lock(state.Default, msgRecordLockKey(userId), msgRecordLockTTL, msgRecordLockWait)
+	if err != nil {
+		logger.Error("lock msg record fail", "userId", userId, "err", err)
+	} else {
+		defer unlock()
+	}
+	
+	msgRecord := GetMsgRecord(userId)
+	if msgRecord == nil {
+		msgRecord = &MsgRecordInfo{
+			AQs: []*AQ{aq},
+		}
+	} else {
+		msgRecord.AQs = append(msgRecord.AQs, aq)
+		if len(msgRecord.AQs) > MaxQAPair {
+			msgRecord.AQs = msgRecord.AQs[len(msgRecord.AQs)-MaxQAPair:]
+		}
+	}
+	msgRecord.UpdateTime = time.Now().Unix()
+	SetMsgRecord(userId, msgRecord)
+	markDirty(userId, msgRecord.UpdateTime)
+}
+
+// GetMsgRecord get conversation context of user, nil if user has no context
 func GetMsgRecord(userId string) *MsgRecordInfo {
-	msgRecord, ok := MsgRecord.Load(userId)
-	if !ok {
+	data, err := state.Default.Get(msgRecordKeyPrefix + userId)
+	if err != nil {
+		logger.Error("get msg record fail", "userId", userId, "err", err)
 		return nil
 	}
-	return msgRecord.(*MsgRecordInfo)
+	if data == nil {
+		return nil
+	}
+	
+	data, err = openMsgRecord(data)
+	if err != nil {
+		logger.Error("decrypt msg record fail", "userId", userId, "err", err)
+		return nil
+	}
+	msgRecord := new(MsgRecordInfo)
+	if err = json.Unmarshal(data, msgRecord); err != nil {
+		logger.Error("unmarshal msg record fail", "userId", userId, "err", err)
+		return nil
+	}
+	return msgRecord
+}
+
+// SetMsgRecord replace conversation context of user, context is encrypted like records and expires after context ttl
+func SetMsgRecord(userId string, msgRecord *MsgRecordInfo) {
+	data, err := json.Marshal(msgRecord)
+	if err != nil {
+		logger.Error("marshal msg record fail", "userId", userId, "err", err)
+		return
+	}
+	data, err = sealMsgRecord(data)
+	if err != nil {
+		logger.Error("encrypt msg record fail", "userId", userId, "err", err)
+		return
+	}
+	if err = state.Default.Set(msgRecordKeyPrefix+userId, data, msgRecordTTL()); err != nil {
+		logger.Error("set msg record fail", "userId", userId, "err", err)
+	}
+}
+
+// msgRecordTTL context ttl of conf, context never lives forever in state store
+func msgRecordTTL() time.Duration {
+	if *conf.StateConfInfo.ContextTTL <= 0 {
+		return defaultMsgRecordTTL
+	}
+	return time.Duration(*conf.StateConfInfo.ContextTTL) * time.Second
+}
+
+// sealMsgRecord encrypt marshaled context if record keys are set
+func sealMsgRecord(data []byte) ([]byte, error) {
+	if recordKeyring == nil {
+		return data, nil
+	}
+	
+	ciphertexts, keyId, dataKey, err := recordKeyring.Seal(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&sealedMsgRecord{KeyId: keyId, DataKey: dataKey, Data: ciphertexts[0]})
+}
+
+// openMsgRecord decrypt stored context, plaintext context is returned as it is
+func openMsgRecord(data []byte) ([]byte, error) {
+	sealed := new(sealedMsgRecord)
+	if err := json.Unmarshal(data, sealed); err != nil || sealed.KeyId == "" {
+		return data, nil
+	}
+	if recordKeyring == nil {
+		return nil, RecordKeyMissingErr
+	}
+	
+	plaintexts, err := recordKeyring.Open(sealed.KeyId, sealed.DataKey, sealed.Data)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plaintexts[0]), nil
+}
+
+// moveMsgRecord move conversation context to another user, context of target is kept if overwrite is false
+func moveMsgRecord(userId, targetId string, overwrite bool) {
+	msgRecord := GetMsgRecord(userId)
+	if msgRecord == nil {
+		return
+	}
+	
+	deleteMsgRecordCache(userId)
+	if overwrite || GetMsgRecord(targetId) == nil {
+		SetMsgRecord(targetId, msgRecord)
+	}
+}
+
+func deleteMsgRecordCache(userId string) {
+	if err := state.Default.Delete(msgRecordKeyPrefix + userId); err != nil {
+		logger.Error("delete msg record fail", "userId", userId, "err", err)
+	}
+}
+
+func msgRecordLockKey(userId string) string {
+	return "lock:" + msgRecordKeyPrefix + userId
 }
 
 func DeleteMsgRecord(userId string) {
-	MsgRecord.Delete(userId)
+	deleteMsgRecordCache(userId)
 	err := DeleteRecord(userId)
 	if err != nil {
 		logger.Error("Error deleting record", "err", err)
@@ -109,28 +239,33 @@ func UpdateUserTime() {
 	}()
 }
 
-func UpdateDBData() {
-	totalNum := 0
-	timeUserPair := make(map[int64][]string)
-	MsgRecord.Range(func(k, v interface{}) bool {
-		msgRecord := v.(*MsgRecordInfo)
-		if _, ok := timeUserPair[msgRecord.updateTime]; !ok {
-			timeUserPair[msgRecord.updateTime] = make([]string, 0)
-		}
-		timeUserPair[msgRecord.updateTime] = append(timeUserPair[msgRecord.updateTime], k.(string))
-		UpdateUserInfo(k.(string), msgRecord.updateTime)
-		totalNum++
-		return true
-	})
+// markDirty remember update time of context so that it is written into db by next UpdateDBData
+func markDirty(userId string, updateTime int64) {
+	dirtyLock.Lock()
+	defer dirtyLock.Unlock()
+	if updateTime > dirtyUpdateTimes[userId] {
+		dirtyUpdateTimes[userId] = updateTime
+	}
 }
 
-func UpdateUserInfo(userId string, updateTime int64) {
-	err := UpdateUserUpdateTime(userId, updateTime)
-	if err != nil {
-		logger.Error("StarCheckUserLen UpdateUserUpdateTime err", "err", err)
+// UpdateDBData write update time of contexts changed by this replica since last call,
+// failed ones are kept for next call
+func UpdateDBData() {
+	dirtyLock.Lock()
+	updateTimes := dirtyUpdateTimes
+	dirtyUpdateTimes = make(map[string]int64)
+	dirtyLock.Unlock()
+	
+	for userId, updateTime := range updateTimes {
+		if err := UpdateUserUpdateTime(userId, updateTime); err != nil {
+			logger.Error("UpdateDBData UpdateUserUpdateTime err", "userId", userId, "err", err)
+			markDirty(userId, updateTime)
+		}
 	}
 }
 
+// InsertRecord load latest records of users into context, context already in shared state store
+// is kept because it may be newer than db
 func InsertRecord() {
 	users, err := GetUsers()
 	if err != nil {
@@ -142,15 +277,24 @@ func InsertRecord() {
 		if err != nil {
 			logger.Error("InsertRecord GetUsers err", "err", err)
 		}
+		metrics.TotalRecords.Add(float64(len(records)))
+		if len(records) == 0 || GetMsgRecord(user.UserId) != nil {
+			continue
+		}
+		
+		msgRecord := &MsgRecordInfo{
+			AQs:        make([]*AQ, 0, len(records)),
+			UpdateTime: time.Now().Unix(),
+		}
 		for i := len(records) - 1; i >= 0; i-- {
 			record := records[i]
-			InsertMsgRecord(user.UserId, &AQ{
+			msgRecord.AQs = append(msgRecord.AQs, &AQ{
 				Question: record.Question,
 				Answer:   record.Answer,
 				Content:  record.Content,
-			}, false)
-			metrics.TotalRecords.Inc()
+			})
 		}
+		SetMsgRecord(user.UserId, msgRecord)
 	}
 	
 	metrics.TotalUsers.Add(float64(len(users)))
@@ -160,7 +304,7 @@ func InsertRecord() {
 // getRecordsByUserId get latest 10 records by user_id
 func getRecordsByUserId(userId string) ([]Record, error) {
 	// construct SQL statements
-	query := fmt.Sprintf("SELECT id, user_id, question, answer, content, mode FROM records WHERE user_id =  ? " +
+	query := fmt.Sprintf("SELECT id, user_id, question, answer, content, mode, key_id, data_key FROM records WHERE user_id =  ? " +
 		"and is_deleted = 0 and record_type = 0 order by create_time desc limit 10")
 	
 	// execute query
@@ -173,40 +317,26 @@ func getRecordsByUserId(userId string) ([]Record, error) {
 	var records []Record
 	for rows.Next() {
 		var record Record
-		err := rows.Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content, &record.Mode)
+		err := rows.Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content, &record.Mode,
+			&record.KeyId, &record.DataKey)
 		if err != nil {
 			return nil, err
 		}
+		if err = decryptRecord(&record); err != nil {
+			return nil, err
+		}
 		records = append(records, record)
 	}
 	
 	return records, nil
 }
 
-// InsertRecordInfo insert record
+// InsertRecordInfo insert record and add token of user in one transaction
 func InsertRecordInfo(record *Record) {
-	query := `INSERT INTO records (user_id, question, answer, content, token, create_time, is_deleted, record_type, mode) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := DB.Exec(query, record.UserId, record.Question, record.Answer, record.Content, record.Token, time.Now().Unix(), record.IsDeleted, record.RecordType, record.Mode)
-	metrics.TotalRecords.Inc()
-	if err != nil {
-		logger.Error("insertRecord err", "err", err)
-	}
-	
-	user, err := GetUserByID(record.UserId)
-	if err != nil {
-		logger.Error("Error get user by userid", "err", err)
-	}
-	
-	if user == nil {
-		_, err = InsertUser(record.UserId, deepseek.DeepSeekChat)
-		if err != nil {
-			logger.Error("Error insert user by userid", "err", err)
-		}
-	}
-	
-	err = AddToken(record.UserId, record.Token)
+	err := insertRecords([]*recordItem{{record: record, enqueueTime: time.Now()}})
 	if err != nil {
-		logger.Error("Error update token by user", "err", err)
+		logger.Error("insertRecord err", "userId", record.UserId, "err", err)
+		metrics.RecordWriteErrors.Inc()
 	}
 }
 
@@ -235,7 +365,7 @@ func GetTokenByUserIdAndTime(userId string, start, end int64) (int, error) {
 }
 
 func GetLastImageRecord(userId string) (*Record, error) {
-	query := fmt.Sprintf("SELECT id, user_id, question, answer, content FROM records WHERE user_id =  ? and record_type = ? and is_deleted = 0 order by id desc")
+	query := fmt.Sprintf("SELECT id, user_id, question, answer, content, key_id, data_key FROM records WHERE user_id =  ? and record_type = ? and is_deleted = 0 order by id desc")
 	
 	// execute query
 	rows, err := DB.Query(query, userId, param.ImageRecordType)
@@ -247,7 +377,7 @@ func GetLastImageRecord(userId string) (*Record, error) {
 	var records []Record
 	for rows.Next() {
 		var record Record
-		err := rows.Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content)
+		err := rows.Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content, &record.KeyId, &record.DataKey)
 		if err != nil {
 			return nil, err
 		}
@@ -258,6 +388,9 @@ func GetLastImageRecord(userId string) (*Record, error) {
 		return nil, nil
 	}
 	
+	if err = decryptRecord(&records[0]); err != nil {
+		return nil, err
+	}
 	return &records[0], nil
 }
 
@@ -309,7 +442,7 @@ func GetRecordList(userId string, page, pageSize, isDeleted int, recordType stri
 	offset := (page - 1) * pageSize
 	
 	query := `
-		SELECT id, user_id, question, answer, content, token, is_deleted, create_time, mode, update_time
+		SELECT id, user_id, question, answer, content, token, is_deleted, create_time, mode, update_time, key_id, data_key
 		FROM records`
 	var args []interface{}
 	var conditions []string
@@ -353,7 +486,10 @@ func GetRecordList(userId string, page, pageSize, isDeleted int, recordType stri
 	var records []Record
 	for rows.Next() {
 		var r Record
-		if err := rows.Scan(&r.ID, &r.UserId, &r.Question, &r.Answer, &r.Content, &r.Token, &r.IsDeleted, &r.CreateTime, &r.Mode, &r.UpdateTime); err != nil {
+		if err := rows.Scan(&r.ID, &r.UserId, &r.Question, &r.Answer, &r.Content, &r.Token, &r.IsDeleted, &r.CreateTime, &r.Mode, &r.UpdateTime, &r.KeyId, &r.DataKey); err != nil {
+			return nil, err
+		}
+		if err := decryptRecord(&r); err != nil {
 			return nil, err
 		}
 		records = append(records, r)
@@ -393,6 +529,16 @@ func GetDailyNewRecords(days int) ([]DailyStat, error) {
 			GROUP BY time_group
 			ORDER BY time_group DESC;
 		`
+	} else if *conf.BaseConfInfo.DBType == utils.Postgres {
+		query = `
+			SELECT
+				(create_time / ?) * ? AS time_group,
+				COUNT(*) AS new_count
+			FROM records
+			WHERE create_time >= EXTRACT(EPOCH FROM NOW() - MAKE_INTERVAL(days => ?))
+			GROUP BY time_group
+			ORDER BY time_group DESC;
+		`
 	} else {
 		return nil, fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
 	}
@@ -1,13 +1,18 @@
 package db
 
 import (
+	"encoding/base64"
 	"os"
 	"strconv"
-	"sync"
+	"strings"
 	"testing"
+	"time"
 	
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/state"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
 func TestMain(m *testing.M) {
@@ -25,7 +30,7 @@ func setup() {
 
 func TestInsertMsgRecord(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{} // 清理数据
+	state.Default = state.NewMemory() // 清理数据
 	
 	aq := &AQ{Question: "What is Go?", Answer: "A programming language."}
 	InsertMsgRecord(userId, aq, false)
@@ -38,7 +43,7 @@ func TestInsertMsgRecord(t *testing.T) {
 
 func TestInsertMsgRecord_ExceedLimit(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{}
+	state.Default = state.NewMemory()
 	
 	for i := 0; i < MaxQAPair+5; i++ {
 		aq := &AQ{Question: "Q" + strconv.Itoa(i), Answer: "A" + strconv.Itoa(i)}
@@ -52,7 +57,7 @@ func TestInsertMsgRecord_ExceedLimit(t *testing.T) {
 
 func TestDeleteMsgRecord(t *testing.T) {
 	userId := "1"
-	MsgRecord = sync.Map{} // 清理数据
+	state.Default = state.NewMemory() // 清理数据
 	
 	aq := &AQ{Question: "Test Q", Answer: "Test A"}
 	InsertMsgRecord(userId, aq, false)
@@ -62,6 +67,59 @@ func TestDeleteMsgRecord(t *testing.T) {
 	assert.Nil(t, record, "Record should be deleted")
 }
 
+func TestMsgRecordEncryptedWithTTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := state.NewRedis(server.Addr(), "", 0, "")
+	oldStore := state.Default
+	state.Default = store
+	defer func() {
+		store.Close()
+		state.Default, recordKeyring = oldStore, nil
+	}()
+	
+	var err error
+	recordKeyring, err = utils.ParseKeyring("k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32))))
+	assert.Nil(t, err)
+	
+	userId := "context_user"
+	InsertMsgRecord(userId, &AQ{Question: "secret question", Answer: "secret answer"}, false)
+	
+	// context is encrypted in redis and expires
+	raw, err := server.Get(msgRecordKeyPrefix + userId)
+	assert.Nil(t, err)
+	assert.NotContains(t, raw, "secret")
+	assert.Equal(t, time.Duration(*conf.StateConfInfo.ContextTTL)*time.Second, server.TTL(msgRecordKeyPrefix+userId))
+	
+	record := GetMsgRecord(userId)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, "secret question", record.AQs[0].Question)
+	}
+	
+	// context can't be read without keys
+	recordKeyring = nil
+	assert.Nil(t, GetMsgRecord(userId))
+}
+
+func TestUpdateDBData(t *testing.T) {
+	userId := "update_time_user"
+	state.Default = state.NewMemory()
+	InsertUser(userId, "default")
+	assert.Nil(t, UpdateUserUpdateTime(userId, 1))
+	
+	InsertMsgRecord(userId, &AQ{Question: "q", Answer: "a"}, false)
+	UpdateDBData()
+	user, err := GetUserByID(userId)
+	assert.Nil(t, err)
+	assert.Equal(t, GetMsgRecord(userId).UpdateTime, user.UpdateTime)
+	
+	// only contexts changed since last call are written
+	assert.Nil(t, UpdateUserUpdateTime(userId, 1))
+	UpdateDBData()
+	user, err = GetUserByID(userId)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), user.UpdateTime)
+}
+
 func TestInsertRecordInfoAndGetRecords(t *testing.T) {
 	
 	userId := "12345"
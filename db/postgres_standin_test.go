@@ -0,0 +1,306 @@
+//go:build postgres
+
+package db
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+var pgParamReg = regexp.MustCompile(`\$(\d+)`)
+
+// pgStatement statement received by stand-in server
+type pgStatement struct {
+	Query string
+	Args  []string
+}
+
+// pgReply rows replied to queries which contain match
+type pgReply struct {
+	match   string
+	columns []string
+	rows    [][]string
+}
+
+// pgStandIn postgres server speaking wire protocol in process, it replies scripted rows and records statements,
+// so that queries are sent by lib/pq just like real postgres without a container.
+type pgStandIn struct {
+	listener   net.Listener
+	replies    []*pgReply
+	lock       sync.Mutex
+	statements []*pgStatement
+}
+
+func newPgStandIn(t *testing.T, replies ...*pgReply) *pgStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	
+	s := &pgStandIn{listener: listener, replies: replies}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	return s
+}
+
+func (s *pgStandIn) dsn() string {
+	return "postgres://musebot@" + s.listener.Addr().String() + "/musebot?sslmode=disable"
+}
+
+func (s *pgStandIn) getStatements() []*pgStatement {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*pgStatement(nil), s.statements...)
+}
+
+func (s *pgStandIn) reply(query string) *pgReply {
+	for _, r := range s.replies {
+		if strings.Contains(query, r.match) {
+			return r
+		}
+	}
+	return &pgReply{}
+}
+
+func (s *pgStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.SSLRequest); ok {
+			if _, err = conn.Write([]byte("N")); err != nil {
+				return
+			}
+			continue
+		}
+		break
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.0"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "TimeZone", Value: "UTC"})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return
+	}
+	
+	txStatus := byte('I')
+	prepared := make(map[string]string)
+	portals := make(map[string]*pgStatement)
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		
+		switch m := msg.(type) {
+		case *pgproto3.Query:
+			st := &pgStatement{Query: m.String}
+			s.record(st)
+			txStatus = nextTxStatus(st.Query, txStatus)
+			s.sendRows(backend, st, true)
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+		case *pgproto3.Parse:
+			prepared[m.Name] = m.Query
+			backend.Send(&pgproto3.ParseComplete{})
+		case *pgproto3.Describe:
+			query := prepared[m.Name]
+			if m.ObjectType == 'P' {
+				query = portals[m.Name].Query
+			} else {
+				backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: make([]uint32, paramCount(query))})
+			}
+			backend.Send(rowDescription(s.reply(query)))
+		case *pgproto3.Bind:
+			st := &pgStatement{Query: prepared[m.PreparedStatement]}
+			for _, p := range m.Parameters {
+				st.Args = append(st.Args, string(p))
+			}
+			portals[m.DestinationPortal] = st
+			backend.Send(&pgproto3.BindComplete{})
+		case *pgproto3.Execute:
+			st := portals[m.Portal]
+			s.record(st)
+			s.sendRows(backend, st, false)
+		case *pgproto3.Close:
+			backend.Send(&pgproto3.CloseComplete{})
+		case *pgproto3.Sync:
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: txStatus})
+		case *pgproto3.Terminate:
+			return
+		}
+		
+		if backend.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *pgStandIn) record(st *pgStatement) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.statements = append(s.statements, st)
+}
+
+// sendRows send rows of statement, row description is sent by describe message in extended protocol
+func (s *pgStandIn) sendRows(backend *pgproto3.Backend, st *pgStatement, simple bool) {
+	r := s.reply(st.Query)
+	if simple && len(r.columns) > 0 {
+		backend.Send(rowDescription(r))
+	}
+	for _, row := range r.rows {
+		values := make([][]byte, len(row))
+		for i := range row {
+			values[i] = []byte(row[i])
+		}
+		backend.Send(&pgproto3.DataRow{Values: values})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(commandTag(st.Query, len(r.rows)))})
+}
+
+func rowDescription(r *pgReply) pgproto3.BackendMessage {
+	if len(r.columns) == 0 {
+		return &pgproto3.NoData{}
+	}
+	fields := make([]pgproto3.FieldDescription, len(r.columns))
+	for i, column := range r.columns {
+		// all columns are text, database/sql converts them into scanned types
+		fields[i] = pgproto3.FieldDescription{Name: []byte(column), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
+
+func paramCount(query string) int {
+	count := 0
+	for _, m := range pgParamReg.FindAllStringSubmatch(query, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+func commandTag(query string, rows int) string {
+	fields := strings.Fields(strings.ToUpper(query))
+	switch fields[0] {
+	case "INSERT":
+		return "INSERT 0 1"
+	case "UPDATE", "DELETE":
+		return fields[0] + " 1"
+	case "SELECT":
+		return fmt.Sprintf("SELECT %d", rows)
+	case "BEGIN", "COMMIT", "ROLLBACK":
+		return fields[0]
+	}
+	if len(fields) > 1 {
+		return fields[0] + " " + fields[1]
+	}
+	return fields[0]
+}
+
+func nextTxStatus(query string, txStatus byte) byte {
+	switch strings.Fields(strings.ToUpper(query))[0] {
+	case "BEGIN":
+		return 'T'
+	case "COMMIT", "ROLLBACK":
+		return 'I'
+	}
+	return txStatus
+}
+
+// TestPostgresStandIn run migrations and queries through lib/pq against stand-in server,
+// statements must be postgres dialect with numbered placeholders
+func TestPostgresStandIn(t *testing.T) {
+	server := newPgStandIn(t,
+		&pgReply{match: "FROM information_schema.columns", columns: []string{"count"}, rows: [][]string{{"0"}}},
+		&pgReply{match: "FROM users WHERE platform = ''", columns: []string{"count"}, rows: [][]string{{"0"}}},
+		&pgReply{match: "INSERT INTO rag_files", columns: []string{"id"}, rows: [][]string{{"5"}}},
+		&pgReply{match: "FROM users WHERE user_id", columns: []string{"id", "user_id", "mode", "token", "avail_token",
+			"update_time", "create_time"}, rows: [][]string{{"7", "pg_user", "default", "3", "100", "1700000000", "1700000000"}}},
+	)
+	
+	oldDB, oldDBType := DB, *conf.BaseConfInfo.DBType
+	defer func() {
+		DB, *conf.BaseConfInfo.DBType = oldDB, oldDBType
+	}()
+	
+	var err error
+	*conf.BaseConfInfo.DBType = utils.Postgres
+	DB, err = utils.OpenDB(utils.Postgres, server.dsn())
+	assert.Nil(t, err)
+	defer DB.Close()
+	
+	migrations := getMigrations(utils.Postgres)
+	count, err := RunMigrations()
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations), count)
+	
+	id, err := InsertRagFile("a.txt", "md5")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), id)
+	
+	user, err := GetUserByID("pg_user")
+	assert.Nil(t, err)
+	assert.Equal(t, "default", user.Mode)
+	assert.Equal(t, 100, user.AvailToken)
+	
+	assert.Nil(t, AddToken("pg_user", 3))
+	assert.Nil(t, InsertRagVectors([]*RagVector{{VectorId: "v1", Content: "c", Metadata: "{}", Embedding: []byte{1, 2}}}))
+	
+	statements := server.getStatements()
+	recorded, commits := 0, 0
+	var insertFile, addToken *pgStatement
+	for _, st := range statements {
+		query := strings.ToUpper(st.Query)
+		assert.NotContains(t, st.Query, "?", st.Query)
+		assert.NotContains(t, query, "AUTOINCREMENT", st.Query)
+		assert.NotContains(t, query, "INSERT OR ", st.Query)
+		assert.NotContains(t, query, "STRFTIME", st.Query)
+		
+		switch {
+		case strings.HasPrefix(st.Query, "INSERT INTO schema_migrations"):
+			recorded++
+		case query == "COMMIT":
+			commits++
+		case strings.HasPrefix(st.Query, "INSERT INTO rag_files"):
+			insertFile = st
+		case strings.HasPrefix(st.Query, "UPDATE users SET token"):
+			addToken = st
+		}
+	}
+	assert.Equal(t, len(migrations), recorded)
+	assert.NotZero(t, commits)
+	
+	if assert.NotNil(t, insertFile) {
+		assert.True(t, strings.HasSuffix(insertFile.Query, "RETURNING id"), insertFile.Query)
+		assert.Equal(t, "a.txt", insertFile.Args[0])
+	}
+	if assert.NotNil(t, addToken) {
+		assert.Contains(t, addToken.Query, "$1")
+		assert.Equal(t, "3", addToken.Args[0])
+		assert.Equal(t, "pg_user", addToken.Args[2])
+	}
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"fmt"
 	"time"
 	
 	"github.com/yincongcyincong/MuseBot/metrics"
@@ -11,6 +12,7 @@ type RagFiles struct {
 	FileName   string `json:"file_name"`
 	FileMd5    string `json:"file_md5"`
 	VectorId   string `json:"vector_id"`
+	Owner      string `json:"owner"`
 	UpdateTime int64  `json:"update_time"`
 	CreateTime int    `json:"create_time"`
 	IsDeleted  int    `json:"is_deleted"`
@@ -19,13 +21,7 @@ type RagFiles struct {
 func InsertRagFile(fileName, fileMd5 string) (int64, error) {
 	// insert data
 	insertSQL := `INSERT INTO rag_files (file_name, file_md5, create_time, update_time, vector_id) VALUES (?, ?, ?, ?, ?)`
-	result, err := DB.Exec(insertSQL, fileName, fileMd5, time.Now().Unix(), time.Now().Unix(), "")
-	if err != nil {
-		return 0, err
-	}
-	
-	// get last insert id
-	id, err := result.LastInsertId()
+	id, err := insertAndGetId(insertSQL, fileName, fileMd5, time.Now().Unix(), time.Now().Unix(), "")
 	if err != nil {
 		return 0, err
 	}
@@ -59,7 +55,7 @@ func GetRagFileByFileMd5(fileMd5 string) ([]*RagFiles, error) {
 }
 
 func GetRagFileByFileName(fileName string) ([]*RagFiles, error) {
-	querySQL := `SELECT id, file_name, file_md5, update_time, create_time, vector_id FROM rag_files WHERE file_name = ? and is_deleted = 0`
+	querySQL := `SELECT id, file_name, file_md5, update_time, create_time, vector_id, owner FROM rag_files WHERE file_name = ? and is_deleted = 0`
 	rows, err := DB.Query(querySQL, fileName)
 	
 	if err != nil {
@@ -70,7 +66,62 @@ func GetRagFileByFileName(fileName string) ([]*RagFiles, error) {
 	var ragFiles []*RagFiles
 	for rows.Next() {
 		var ragFile RagFiles
-		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId); err != nil {
+		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId,
+			&ragFile.Owner); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, &ragFile)
+	}
+	
+	// check error
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ragFiles, nil
+}
+
+// GetRagFiles get all rag files which are not deleted
+func GetRagFiles() ([]*RagFiles, error) {
+	querySQL := `SELECT id, file_name, file_md5, update_time, create_time, vector_id, owner FROM rag_files WHERE is_deleted = 0`
+	rows, err := DB.Query(querySQL)
+	
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var ragFiles []*RagFiles
+	for rows.Next() {
+		var ragFile RagFiles
+		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId,
+			&ragFile.Owner); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, &ragFile)
+	}
+	
+	// check error
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ragFiles, nil
+}
+
+// GetRagFilesByOwner get rag files which are not deleted and uploaded by owner
+func GetRagFilesByOwner(owner string) ([]*RagFiles, error) {
+	querySQL := `SELECT id, file_name, file_md5, update_time, create_time, vector_id, owner FROM rag_files WHERE owner = ? and is_deleted = 0`
+	rows, err := DB.Query(querySQL, owner)
+	
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var ragFiles []*RagFiles
+	for rows.Next() {
+		var ragFile RagFiles
+		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId,
+			&ragFile.Owner); err != nil {
 			return nil, err
 		}
 		ragFiles = append(ragFiles, &ragFile)
@@ -89,6 +140,12 @@ func DeleteRagFileByFileName(fileName string) error {
 	return err
 }
 
+func DeleteRagFileById(id int64) error {
+	query := `UPDATE rag_files set is_deleted = 1 WHERE id = ?`
+	_, err := DB.Exec(query, id)
+	return err
+}
+
 func DeleteRagFileByVectorId(fileName string) error {
 	query := `UPDATE rag_files set is_deleted = 1 WHERE vector_id = ?`
 	_, err := DB.Exec(query, fileName)
@@ -100,3 +157,74 @@ func UpdateVectorIdByFileMd5(fileMd5, vectorId string) error {
 	_, err := DB.Exec(query, vectorId, fileMd5)
 	return err
 }
+
+// UpdateVectorIdByFileName update vector ids of file which is not deleted
+func UpdateVectorIdByFileName(fileName, vectorId string) error {
+	query := `UPDATE rag_files set vector_id = ? WHERE file_name = ? and is_deleted = 0`
+	_, err := DB.Exec(query, vectorId, fileName)
+	return err
+}
+
+// UpdateOwnerByFileName update owner of file which is not deleted, owner is the user who uploads it
+func UpdateOwnerByFileName(fileName, owner string) error {
+	query := `UPDATE rag_files set owner = ? WHERE file_name = ? and is_deleted = 0`
+	_, err := DB.Exec(query, owner, fileName)
+	return err
+}
+
+// GetRagFileByPage get rag files which are not deleted by page, fileName matches part of file name
+func GetRagFileByPage(page, pageSize int, fileName string) ([]*RagFiles, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+	
+	whereSQL := "WHERE is_deleted = 0"
+	args := make([]interface{}, 0)
+	if fileName != "" {
+		whereSQL += " AND file_name LIKE ?"
+		args = append(args, "%"+fileName+"%")
+	}
+	
+	listSQL := fmt.Sprintf(`
+		SELECT id, file_name, file_md5, update_time, create_time, vector_id, owner
+		FROM rag_files %s
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, whereSQL)
+	args = append(args, pageSize, offset)
+	
+	rows, err := DB.Query(listSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	ragFiles := make([]*RagFiles, 0)
+	for rows.Next() {
+		var ragFile RagFiles
+		if err := rows.Scan(&ragFile.ID, &ragFile.FileName, &ragFile.FileMd5, &ragFile.UpdateTime, &ragFile.CreateTime, &ragFile.VectorId,
+			&ragFile.Owner); err != nil {
+			return nil, err
+		}
+		ragFiles = append(ragFiles, &ragFile)
+	}
+	
+	return ragFiles, rows.Err()
+}
+
+// GetRagFileCount get number of rag files which are not deleted, fileName matches part of file name
+func GetRagFileCount(fileName string) (int, error) {
+	countSQL := "SELECT COUNT(*) FROM rag_files WHERE is_deleted = 0"
+	args := make([]interface{}, 0)
+	if fileName != "" {
+		countSQL += " AND file_name LIKE ?"
+		args = append(args, "%"+fileName+"%")
+	}
+	
+	var count int
+	err := DB.QueryRow(countSQL, args...).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+type RagVector struct {
+	ID         int64  `json:"id"`
+	VectorId   string `json:"vector_id"`
+	Content    string `json:"content"`
+	Metadata   string `json:"metadata"`
+	Embedding  []byte `json:"-"`
+	CreateTime int64  `json:"create_time"`
+}
+
+// InsertRagVectors insert vectors of embedded vector store in one transaction
+func InsertRagVectors(vectors []*RagVector) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	
+	insertSQL := `INSERT INTO rag_vectors (vector_id, content, metadata, embedding, create_time) VALUES (?, ?, ?, ?, ?)`
+	stmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	
+	for _, v := range vectors {
+		_, err = stmt.Exec(v.VectorId, v.Content, v.Metadata, v.Embedding, time.Now().Unix())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	return tx.Commit()
+}
+
+// GetRagVectors get all vectors, embedded vector store load them into memory when start
+func GetRagVectors() ([]*RagVector, error) {
+	querySQL := `SELECT id, vector_id, content, metadata, embedding, create_time FROM rag_vectors`
+	rows, err := DB.Query(querySQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var vectors []*RagVector
+	for rows.Next() {
+		var vector RagVector
+		if err := rows.Scan(&vector.ID, &vector.VectorId, &vector.Content, &vector.Metadata, &vector.Embedding, &vector.CreateTime); err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, &vector)
+	}
+	
+	// check error
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// DeleteRagVectorByVectorIds hard delete vectors
+func DeleteRagVectorByVectorIds(vectorIds []string) error {
+	if len(vectorIds) == 0 {
+		return nil
+	}
+	
+	args := make([]interface{}, 0, len(vectorIds))
+	for _, vectorId := range vectorIds {
+		args = append(args, vectorId)
+	}
+	
+	query := `DELETE FROM rag_vectors WHERE vector_id IN (?` + strings.Repeat(", ?", len(vectorIds)-1) + `)`
+	_, err := DB.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,307 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/cohesion-org/deepseek-go"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+)
+
+const (
+	maxRecordBatchSize = 500
+	
+	// records left in queue are flushed before shutdown within timeout
+	RecordWriterStopTimeout = 10 * time.Second
+)
+
+var (
+	RecordWriterClosedErr = errors.New("record writer is closed")
+	RecordWriterStopErr   = errors.New("stop record writer timeout")
+	RecordWriterFlushErr  = errors.New("flush record writer timeout")
+	
+	recordWriter *RecordWriter
+)
+
+type recordItem struct {
+	record      *Record
+	enqueueTime time.Time
+	
+	// flushed is closed after records queued before the item are written, item without record is only a marker
+	flushed chan struct{}
+}
+
+// RecordWriter write records in background, records are batched into one transaction
+// and producers wait if queue is full
+type RecordWriter struct {
+	queue         chan *recordItem
+	batchSize     int
+	flushInterval time.Duration
+	
+	lock   sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewRecordWriter(queueSize, batchSize int, flushInterval time.Duration) *RecordWriter {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if batchSize > maxRecordBatchSize {
+		batchSize = maxRecordBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = 200 * time.Millisecond
+	}
+	
+	w := &RecordWriter{
+		queue:         make(chan *recordItem, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// StartRecordWriter start background writer of conf, records are written synchronously if queue size is 0
+func StartRecordWriter() {
+	if *conf.RecordConfInfo.RecordQueueSize <= 0 {
+		return
+	}
+	recordWriter = NewRecordWriter(*conf.RecordConfInfo.RecordQueueSize, *conf.RecordConfInfo.RecordBatchSize,
+		time.Duration(*conf.RecordConfInfo.RecordFlushInterval)*time.Millisecond)
+}
+
+// StopRecordWriter flush records in queue, it is called when process exits
+func StopRecordWriter() {
+	if recordWriter == nil {
+		return
+	}
+	err := recordWriter.Close(RecordWriterStopTimeout)
+	if err != nil {
+		logger.Error("stop record writer fail", "err", err, "left", len(recordWriter.queue))
+		return
+	}
+	logger.Info("stop record writer success")
+}
+
+// FlushRecordWriter wait until records in queue are written
+func FlushRecordWriter(timeout time.Duration) error {
+	if recordWriter == nil {
+		return nil
+	}
+	return recordWriter.Flush(timeout)
+}
+
+// InsertRecordAsync write record by background writer, record is written synchronously if writer isn't running
+func InsertRecordAsync(record *Record) {
+	if recordWriter != nil && recordWriter.Add(record) == nil {
+		return
+	}
+	InsertRecordInfo(record)
+}
+
+// Add put record into queue, it blocks until queue has space
+func (w *RecordWriter) Add(record *Record) error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		return RecordWriterClosedErr
+	}
+	
+	item := &recordItem{record: record, enqueueTime: time.Now()}
+	select {
+	case w.queue <- item:
+	default:
+		metrics.RecordQueueFull.Inc()
+		w.queue <- item
+	}
+	metrics.RecordQueueDepth.Set(float64(len(w.queue)))
+	return nil
+}
+
+// Flush wait until records added before are written
+func (w *RecordWriter) Flush(timeout time.Duration) error {
+	w.lock.RLock()
+	if w.closed {
+		w.lock.RUnlock()
+		return RecordWriterClosedErr
+	}
+	flushed := make(chan struct{})
+	w.queue <- &recordItem{flushed: flushed}
+	w.lock.RUnlock()
+	
+	select {
+	case <-flushed:
+		return nil
+	case <-time.After(timeout):
+		return RecordWriterFlushErr
+	}
+}
+
+// Close stop accepting records and wait until records in queue are written
+func (w *RecordWriter) Close(timeout time.Duration) error {
+	w.lock.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.lock.Unlock()
+	
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(timeout):
+		return RecordWriterStopErr
+	}
+}
+
+func (w *RecordWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	
+	batch := make([]*recordItem, 0, w.batchSize)
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			if item.flushed != nil {
+				w.flush(batch)
+				batch = batch[:0]
+				close(item.flushed)
+				continue
+			}
+			batch = append(batch, item)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush write batch in one transaction, records are written one by one if batch fails
+// so that one bad record doesn't drop others
+func (w *RecordWriter) flush(batch []*recordItem) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("flush records panic", "err", err, "stack", string(debug.Stack()))
+		}
+	}()
+	
+	metrics.RecordQueueDepth.Set(float64(len(w.queue)))
+	if len(batch) == 0 {
+		return
+	}
+	
+	err := insertRecords(batch)
+	if err != nil && len(batch) > 1 {
+		logger.Warn("write record batch fail, retry one by one", "size", len(batch), "err", err)
+		for _, item := range batch {
+			if err := insertRecords([]*recordItem{item}); err != nil {
+				logger.Error("write record fail", "userId", item.record.UserId, "err", err)
+				metrics.RecordWriteErrors.Inc()
+				continue
+			}
+			metrics.RecordWriteLatency.Observe(time.Since(item.enqueueTime).Seconds())
+		}
+		return
+	}
+	if err != nil {
+		logger.Error("write record fail", "userId", batch[0].record.UserId, "err", err)
+		metrics.RecordWriteErrors.Inc()
+		return
+	}
+	
+	for _, item := range batch {
+		metrics.RecordWriteLatency.Observe(time.Since(item.enqueueTime).Seconds())
+	}
+}
+
+// insertRecords insert records, create missing users and add tokens of users in one transaction
+func insertRecords(items []*recordItem) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	placeholders := make([]string, 0, len(items))
+	args := make([]interface{}, 0, len(items)*12)
+	userTokens := make(map[string]int)
+	userIds := make([]string, 0)
+	for _, item := range items {
+		record := item.record
+		platform, _ := ParseUserId(record.UserId)
+		question, answer, content, keyId, dataKey, err := encryptRecord(record)
+		if err != nil {
+			return err
+		}
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, record.UserId, platform, question, answer, content, record.Token,
+			item.enqueueTime.Unix(), record.IsDeleted, record.RecordType, record.Mode, keyId, dataKey)
+			
+		if _, ok := userTokens[record.UserId]; !ok {
+			userIds = append(userIds, record.UserId)
+		}
+		userTokens[record.UserId] += record.Token
+	}
+	
+	_, err = tx.Exec(`INSERT INTO records (user_id, platform, question, answer, content, token, create_time, is_deleted, record_type, mode, key_id, data_key) VALUES `+
+		strings.Join(placeholders, ", "), args...)
+	if err != nil {
+		return err
+	}
+	
+	newUsers := 0
+	now := time.Now().Unix()
+	for _, userId := range userIds {
+		created, err := ensureUser(tx, userId, now)
+		if err != nil {
+			return err
+		}
+		if created {
+			newUsers++
+		}
+		
+		_, err = tx.Exec(`UPDATE users SET token = token + ?, update_time = ? WHERE user_id = ?`, userTokens[userId], now, userId)
+		if err != nil {
+			return err
+		}
+	}
+	
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	metrics.TotalRecords.Add(float64(len(items)))
+	metrics.TotalUsers.Add(float64(newUsers))
+	return nil
+}
+
+// ensureUser insert user if it doesn't exist
+func ensureUser(tx *sql.Tx, userId string, now int64) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE user_id = ?`, userId).Scan(&count)
+	if err != nil || count > 0 {
+		return false, err
+	}
+	
+	platform, _ := ParseUserId(userId)
+	_, err = tx.Exec(`INSERT INTO users (user_id, platform, mode, update_time, create_time, avail_token) VALUES (?, ?, ?, ?, ?, ?)`,
+		userId, platform, deepseek.DeepSeekChat, now, now, *conf.BaseConfInfo.TokenPerUser)
+	return err == nil, err
+}
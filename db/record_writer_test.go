@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+)
+
+func TestRecordWriter(t *testing.T) {
+	userId := "writer_user"
+	writer := NewRecordWriter(10, 3, time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, writer.Add(&Record{UserId: userId, Question: "writer question", Answer: "a", Token: 2,
+			RecordType: param.TextRecordType}))
+	}
+	
+	// records left in queue are written when writer is closed
+	assert.Nil(t, writer.Close(time.Second))
+	assert.ErrorIs(t, writer.Add(&Record{UserId: userId}), RecordWriterClosedErr)
+	
+	count, err := GetRecordCount(userId, 0, "0")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+	
+	user, err := GetUserByID(userId)
+	assert.Nil(t, err)
+	assert.NotNil(t, user)
+	assert.Equal(t, 10, user.Token)
+}
+
+func TestRecordWriterFlushInterval(t *testing.T) {
+	userId := "writer_interval_user"
+	writer := NewRecordWriter(10, 100, 20*time.Millisecond)
+	defer writer.Close(time.Second)
+	
+	assert.Nil(t, writer.Add(&Record{UserId: userId, Question: "q", Answer: "a", Token: 1}))
+	assert.Eventually(t, func() bool {
+		count, err := GetRecordCount(userId, 0, "0")
+		return err == nil && count == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInsertRecordAsync(t *testing.T) {
+	userId := "writer_async_user"
+	recordWriter = NewRecordWriter(10, 100, time.Hour)
+	InsertRecordAsync(&Record{UserId: userId, Question: "q", Answer: "a"})
+	StopRecordWriter()
+	
+	// writer is closed, record is written synchronously
+	InsertRecordAsync(&Record{UserId: userId, Question: "q", Answer: "a"})
+	recordWriter = nil
+	
+	count, err := GetRecordCount(userId, 0, "0")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
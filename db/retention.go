@@ -0,0 +1,188 @@
+package db
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/MuseBot/state"
+)
+
+const (
+	archiveBatchSize = 500
+	
+	// only one replica runs retention job at a time
+	retentionLockKey = "lock:retention"
+	retentionLockTTL = 30 * time.Minute
+)
+
+// StartRetention purge and archive records periodically
+func StartRetention() {
+	if *conf.RetentionConfInfo.PurgeDeletedDays <= 0 && *conf.RetentionConfInfo.ArchiveDays <= 0 {
+		return
+	}
+	
+	interval := *conf.RetentionConfInfo.RetentionInterval
+	if interval <= 0 {
+		interval = 60
+	}
+	
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error("StartRetention panic err", "err", err, "stack", string(debug.Stack()))
+			}
+		}()
+		
+		RunRetention()
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		for range ticker.C {
+			RunRetention()
+		}
+	}()
+}
+
+// RunRetention hard delete expired soft deleted records and archive old records
+func RunRetention() {
+	token, ok, err := state.Default.TryLock(retentionLockKey, retentionLockTTL)
+	if err != nil {
+		logger.Error("lock retention fail", "err", err)
+		metrics.RetentionErrors.Inc()
+		return
+	}
+	if !ok {
+		logger.Info("retention job is running by another replica")
+		return
+	}
+	defer state.Default.Unlock(retentionLockKey, token)
+	
+	now := time.Now()
+	if days := *conf.RetentionConfInfo.PurgeDeletedDays; days > 0 {
+		count, err := PurgeDeletedRecords(now.AddDate(0, 0, -days).Unix())
+		if err != nil {
+			logger.Error("purge deleted records fail", "err", err)
+			metrics.RetentionErrors.Inc()
+		} else {
+			metrics.PurgedRecords.Add(float64(count))
+			logger.Info("purge deleted records success", "count", count)
+		}
+	}
+	
+	if days := *conf.RetentionConfInfo.ArchiveDays; days > 0 {
+		count, fileName, err := ArchiveRecords(*conf.RetentionConfInfo.ArchiveDir, now.AddDate(0, 0, -days).Unix())
+		if err != nil {
+			logger.Error("archive records fail", "err", err)
+			metrics.RetentionErrors.Inc()
+		} else {
+			metrics.ArchivedRecords.Add(float64(count))
+			logger.Info("archive records success", "count", count, "file", fileName)
+		}
+	}
+}
+
+// PurgeDeletedRecords hard delete records which are soft deleted before time
+func PurgeDeletedRecords(before int64) (int64, error) {
+	res, err := DB.Exec(`DELETE FROM records WHERE is_deleted = 1 AND update_time < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ArchiveRecords move records created before time into a gzip jsonl file of dir,
+// records are deleted only after file is written. it returns number of archived records and file name.
+func ArchiveRecords(dir string, before int64) (int, string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, "", err
+	}
+	
+	fileName := filepath.Join(dir, fmt.Sprintf("records_%s.jsonl.gz", time.Now().Format("20060102150405")))
+	tmpName := fileName + ".tmp"
+	count, lastId, err := writeArchive(tmpName, before)
+	if err != nil || count == 0 {
+		os.Remove(tmpName)
+		return 0, "", err
+	}
+	
+	err = os.Rename(tmpName, fileName)
+	if err != nil {
+		os.Remove(tmpName)
+		return 0, "", err
+	}
+	
+	_, err = DB.Exec(`DELETE FROM records WHERE id <= ? AND create_time < ? AND is_deleted = 0`, lastId, before)
+	if err != nil {
+		return 0, fileName, err
+	}
+	return count, fileName, nil
+}
+
+// writeArchive write records created before time into gzip jsonl file, return count and max id of records
+func writeArchive(fileName string, before int64) (int, int, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	count, lastId := 0, 0
+	for {
+		records, err := getArchiveRecords(lastId, before)
+		if err != nil {
+			return 0, 0, err
+		}
+		
+		for _, record := range records {
+			if err = enc.Encode(record); err != nil {
+				return 0, 0, err
+			}
+			lastId = record.ID
+		}
+		count += len(records)
+		
+		if len(records) < archiveBatchSize {
+			break
+		}
+	}
+	
+	if err = zw.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err = f.Sync(); err != nil {
+		return 0, 0, err
+	}
+	return count, lastId, nil
+}
+
+// getArchiveRecords get a batch of records created before time whose id is greater than last id,
+// encrypted records are archived as they are stored
+func getArchiveRecords(lastId int, before int64) ([]*Record, error) {
+	rows, err := DB.Query(`SELECT id, user_id, question, answer, content, token, is_deleted, create_time, record_type, mode, update_time,
+		key_id, data_key FROM records WHERE id > ? AND create_time < ? AND is_deleted = 0 ORDER BY id LIMIT ?`, lastId, before, archiveBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var records []*Record
+	for rows.Next() {
+		record := new(Record)
+		err = rows.Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content, &record.Token,
+			&record.IsDeleted, &record.CreateTime, &record.RecordType, &record.Mode, &record.UpdateTime, &record.KeyId, &record.DataKey)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
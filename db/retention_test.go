@@ -0,0 +1,73 @@
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeDeletedRecords(t *testing.T) {
+	userId := "retention_purge"
+	old := time.Now().AddDate(0, 0, -40).Unix()
+	_, err := DB.Exec(`INSERT INTO records (user_id, question, answer, content, create_time, update_time, is_deleted) VALUES
+		(?, 'q1', 'a1', '', ?, ?, 1), (?, 'q2', 'a2', '', ?, ?, 1), (?, 'q3', 'a3', '', ?, ?, 0)`,
+		userId, old, old, userId, old, time.Now().Unix(), userId, old, old)
+	assert.Nil(t, err)
+	
+	count, err := PurgeDeletedRecords(time.Now().AddDate(0, 0, -30).Unix())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	
+	var left int
+	assert.Nil(t, DB.QueryRow(`SELECT COUNT(*) FROM records WHERE user_id = ?`, userId).Scan(&left))
+	assert.Equal(t, 2, left)
+}
+
+func TestArchiveRecords(t *testing.T) {
+	userId := "retention_archive"
+	old := time.Now().AddDate(0, 0, -200).Unix()
+	for i := 0; i < archiveBatchSize+1; i++ {
+		_, err := DB.Exec(`INSERT INTO records (user_id, question, answer, content, create_time, is_deleted) VALUES (?, ?, 'a', '', ?, 0)`,
+			userId, "old", old)
+		assert.Nil(t, err)
+	}
+	_, err := DB.Exec(`INSERT INTO records (user_id, question, answer, content, create_time, is_deleted) VALUES (?, 'new', 'a', '', ?, 0)`,
+		userId, time.Now().Unix())
+	assert.Nil(t, err)
+	
+	dir := t.TempDir()
+	count, fileName, err := ArchiveRecords(dir, time.Now().AddDate(0, 0, -180).Unix())
+	assert.Nil(t, err)
+	assert.Equal(t, archiveBatchSize+1, count)
+	
+	f, err := os.Open(fileName)
+	assert.Nil(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	
+	lines := 0
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		record := new(Record)
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), record))
+		assert.Equal(t, "old", record.Question)
+		lines++
+	}
+	assert.Equal(t, archiveBatchSize+1, lines)
+	
+	var question string
+	assert.Nil(t, DB.QueryRow(`SELECT question FROM records WHERE user_id = ?`, userId).Scan(&question))
+	assert.Equal(t, "new", question)
+	
+	// nothing to archive
+	count, fileName, err = ArchiveRecords(dir, time.Now().AddDate(0, 0, -180).Unix())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, "", fileName)
+}
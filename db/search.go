@@ -0,0 +1,331 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	// external content fts5 table of records, it is kept in sync by triggers
+	sqlite3CreateRecordsFTSSQL = `CREATE VIRTUAL TABLE records_fts USING fts5(question, answer, content='records', content_rowid='id')`
+	
+	sqlite3CreateRecordsFTSTriggerSQL = `
+			CREATE TRIGGER IF NOT EXISTS records_fts_insert AFTER INSERT ON records BEGIN
+				INSERT INTO records_fts(rowid, question, answer) VALUES (new.id, new.question, new.answer);
+			END;
+			CREATE TRIGGER IF NOT EXISTS records_fts_delete AFTER DELETE ON records BEGIN
+				INSERT INTO records_fts(records_fts, rowid, question, answer) VALUES ('delete', old.id, old.question, old.answer);
+			END;
+			CREATE TRIGGER IF NOT EXISTS records_fts_update AFTER UPDATE OF question, answer ON records BEGIN
+				INSERT INTO records_fts(records_fts, rowid, question, answer) VALUES ('delete', old.id, old.question, old.answer);
+				INSERT INTO records_fts(rowid, question, answer) VALUES (new.id, new.question, new.answer);
+			END;`
+			
+	// ngram parser splits chinese text which has no space between words
+	mysqlCreateRecordsFulltextSQL = `CREATE FULLTEXT INDEX ft_records_question_answer ON records(question, answer) WITH PARSER ngram`
+	
+	postgresCreateRecordsFulltextSQL = `CREATE INDEX IF NOT EXISTS idx_records_fulltext ON records
+			USING GIN (to_tsvector('simple', question || ' ' || answer))`
+			
+	searchSnippetLen = 80
+	
+	// latest records of user scanned by search if records are encrypted
+	searchScanLimit = 1000
+)
+
+type SearchRecord struct {
+	ID         int    `json:"id"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+	Snippet    string `json:"snippet"`
+	CreateTime int64  `json:"create_time"`
+}
+
+// createRecordsFulltext migration which creates full text index of records question and answer,
+// fts5 table of sqlite3 is created by ensureRecordsFTS after migrations because it depends on build tag
+func createRecordsFulltext(dialect string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		switch dialect {
+		case utils.Mysql:
+			var count int
+			err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'records' AND index_name = 'ft_records_question_answer'`).Scan(&count)
+			if err != nil || count > 0 {
+				return err
+			}
+			_, err = db.Exec(mysqlCreateRecordsFulltextSQL)
+			return err
+		case utils.Postgres:
+			_, err := db.Exec(postgresCreateRecordsFulltextSQL)
+			return err
+		}
+		return nil
+	}
+}
+
+// ensureRecordsFTS create fts5 table of sqlite3 if it doesn't exist, it runs after every migration instead of
+// being a versioned migration, so table is created once binary is built with fts5 later
+func ensureRecordsFTS() error {
+	if *conf.BaseConfInfo.DBType != utils.Sqlite3 {
+		return nil
+	}
+	
+	err := createSqlite3RecordsFTS(DB)
+	if err != nil {
+		return fmt.Errorf("create records fts fail: %v", err)
+	}
+	return nil
+}
+
+// createSqlite3RecordsFTS create fts5 table of records and index existing records.
+// fts5 is compiled in only with build tag sqlite_fts5, search falls back to LIKE without it.
+func createSqlite3RecordsFTS(db *sql.DB) error {
+	exist, err := sqlite3RecordsFTSExist(db)
+	if err != nil || exist {
+		return err
+	}
+	
+	_, err = db.Exec(sqlite3CreateRecordsFTSSQL)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			logger.Warn("sqlite3 fts5 is not compiled in, build with -tags sqlite_fts5 to enable full text search")
+			return nil
+		}
+		return err
+	}
+	
+	_, err = db.Exec(sqlite3CreateRecordsFTSTriggerSQL)
+	if err != nil {
+		return err
+	}
+	
+	_, err = db.Exec(`INSERT INTO records_fts(records_fts) VALUES ('rebuild')`)
+	return err
+}
+
+// sqlite3RecordsFTSExist check whether fts5 table of records exists
+func sqlite3RecordsFTSExist(db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'records_fts'`).Scan(&count)
+	return count > 0, err
+}
+
+// SearchRecords full text search text records of user, deleted records are not searched
+func SearchRecords(userId, query string, limit int) ([]*SearchRecord, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	
+	// index can't search encrypted records, latest records of user are decrypted and matched instead
+	if recordKeyring != nil {
+		return searchEncryptedRecords(userId, terms, limit)
+	}
+	
+	var querySQL string
+	var args []interface{}
+	switch *conf.BaseConfInfo.DBType {
+	case utils.Mysql:
+		booleanQuery := make([]string, 0, len(terms))
+		for _, term := range terms {
+			booleanQuery = append(booleanQuery, `+"`+strings.ReplaceAll(term, `"`, ``)+`"`)
+		}
+		querySQL = `SELECT id, question, answer, create_time FROM records
+			WHERE user_id = ? AND is_deleted = 0 AND key_id = '' AND record_type IN (?, ?) AND MATCH(question, answer) AGAINST(? IN BOOLEAN MODE)
+			ORDER BY create_time DESC LIMIT ?`
+		args = []interface{}{userId, param.TextRecordType, param.WEBRecordType, strings.Join(booleanQuery, " "), limit}
+	case utils.Postgres:
+		querySQL = `SELECT id, question, answer, create_time FROM records
+			WHERE user_id = ? AND is_deleted = 0 AND key_id = '' AND record_type IN (?, ?)
+			AND to_tsvector('simple', question || ' ' || answer) @@ plainto_tsquery('simple', ?)
+			ORDER BY create_time DESC LIMIT ?`
+		args = []interface{}{userId, param.TextRecordType, param.WEBRecordType, query, limit}
+	default:
+		exist, err := sqlite3RecordsFTSExist(DB)
+		if err != nil {
+			return nil, err
+		}
+		
+		if exist {
+			ftsQuery := make([]string, 0, len(terms))
+			for _, term := range terms {
+				ftsQuery = append(ftsQuery, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			}
+			querySQL = `SELECT r.id, r.question, r.answer, r.create_time FROM records_fts f JOIN records r ON r.id = f.rowid
+				WHERE records_fts MATCH ? AND r.user_id = ? AND r.is_deleted = 0 AND r.key_id = '' AND r.record_type IN (?, ?)
+				ORDER BY f.rank LIMIT ?`
+			args = []interface{}{strings.Join(ftsQuery, " "), userId, param.TextRecordType, param.WEBRecordType, limit}
+		} else {
+			conditions := make([]string, 0, len(terms))
+			args = []interface{}{userId, param.TextRecordType, param.WEBRecordType}
+			for _, term := range terms {
+				conditions = append(conditions, `(question LIKE ? ESCAPE '\' OR answer LIKE ? ESCAPE '\')`)
+				like := "%" + escapeLike(term) + "%"
+				args = append(args, like, like)
+			}
+			querySQL = fmt.Sprintf(`SELECT id, question, answer, create_time FROM records
+				WHERE user_id = ? AND is_deleted = 0 AND key_id = '' AND record_type IN (?, ?) AND %s
+				ORDER BY create_time DESC LIMIT ?`, strings.Join(conditions, " AND "))
+			args = append(args, limit)
+		}
+	}
+	
+	rows, err := DB.Query(querySQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var records []*SearchRecord
+	for rows.Next() {
+		record := new(SearchRecord)
+		err = rows.Scan(&record.ID, &record.Question, &record.Answer, &record.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+		setSnippet(record, terms)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// searchEncryptedRecords decrypt latest records of user and match all terms
+func searchEncryptedRecords(userId string, terms []string, limit int) ([]*SearchRecord, error) {
+	rows, err := DB.Query(`SELECT id, question, answer, content, create_time, key_id, data_key FROM records
+		WHERE user_id = ? AND is_deleted = 0 AND record_type IN (?, ?) ORDER BY id DESC LIMIT ?`,
+		userId, param.TextRecordType, param.WEBRecordType, searchScanLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var records []*SearchRecord
+	for rows.Next() && len(records) < limit {
+		record := new(Record)
+		err = rows.Scan(&record.ID, &record.Question, &record.Answer, &record.Content, &record.CreateTime, &record.KeyId, &record.DataKey)
+		if err != nil {
+			return nil, err
+		}
+		if err = decryptRecord(record); err != nil {
+			return nil, err
+		}
+		
+		matched := true
+		for _, term := range terms {
+			if !containsTerm(record.Question+"\n"+record.Answer, []string{term}) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		
+		searchRecord := &SearchRecord{
+			ID:         record.ID,
+			Question:   record.Question,
+			Answer:     record.Answer,
+			CreateTime: record.CreateTime,
+		}
+		setSnippet(searchRecord, terms)
+		records = append(records, searchRecord)
+	}
+	return records, rows.Err()
+}
+
+// setSnippet set snippet of answer, snippet of question is used if only question matches
+func setSnippet(record *SearchRecord, terms []string) {
+	record.Snippet = GetSnippet(record.Answer, terms, searchSnippetLen)
+	if !containsTerm(record.Answer, terms) && containsTerm(record.Question, terms) {
+		record.Snippet = GetSnippet(record.Question, terms, searchSnippetLen)
+	}
+}
+
+// GetRecordById get undeleted record of user by id
+func GetRecordById(userId string, id int) (*Record, error) {
+	record := new(Record)
+	err := DB.QueryRow(`SELECT id, user_id, question, answer, content, create_time, mode, key_id, data_key FROM records WHERE id = ? AND user_id = ? AND is_deleted = 0`,
+		id, userId).Scan(&record.ID, &record.UserId, &record.Question, &record.Answer, &record.Content, &record.CreateTime, &record.Mode,
+		&record.KeyId, &record.DataKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = decryptRecord(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetSnippet cut text around first term, text is returned from start if no term is found
+func GetSnippet(text string, terms []string, length int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	
+	pos := -1
+	for _, term := range terms {
+		idx := runeIndex(lower, []rune(strings.ToLower(term)))
+		if idx >= 0 && (pos < 0 || idx < pos) {
+			pos = idx
+		}
+	}
+	
+	start := 0
+	if pos > length/4 {
+		start = pos - length/4
+	}
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+		start = max(0, end-length)
+	}
+	
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+	if end < len(runes) {
+		snippet += "..."
+	}
+	return snippet
+}
+
+// containsTerm check whether text contains any term ignoring case
+func containsTerm(text string, terms []string) bool {
+	text = strings.ToLower(text)
+	for _, term := range terms {
+		if strings.Contains(text, strings.ToLower(term)) {
+			return true
+		}
+	}
+	return false
+}
+
+// runeIndex get rune index of sub in s
+func runeIndex(s, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if string(s[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeLike escape wildcards of LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestSearchRecords(t *testing.T) {
+	userId := "search_user"
+	InsertRecordInfo(&Record{UserId: userId, Question: "how to configure nginx", Answer: "edit nginx.conf and reload nginx", RecordType: param.TextRecordType})
+	InsertRecordInfo(&Record{UserId: userId, Question: "what is redis", Answer: "redis is a key value store", RecordType: param.TextRecordType})
+	InsertRecordInfo(&Record{UserId: userId, Question: "nginx image", Answer: "https://example.com/a.png", RecordType: param.ImageRecordType})
+	InsertRecordInfo(&Record{UserId: "search_other", Question: "nginx proxy", Answer: "use proxy_pass", RecordType: param.TextRecordType})
+	InsertRecordInfo(&Record{UserId: userId, Question: "nginx deleted", Answer: "deleted answer", IsDeleted: 1, RecordType: param.TextRecordType})
+	
+	records, err := SearchRecords(userId, "nginx", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "how to configure nginx", records[0].Question)
+	assert.Contains(t, records[0].Snippet, "nginx")
+	
+	records, err = SearchRecords(userId, "key store", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "what is redis", records[0].Question)
+	
+	records, err = SearchRecords(userId, "redis nginx", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+	
+	records, err = SearchRecords(userId, "  ", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+	
+	// wildcards are searched literally
+	records, err = SearchRecords(userId, "%", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+}
+
+func TestMigrateRecordsFTS(t *testing.T) {
+	oldDB := DB
+	defer func() {
+		DB.Close()
+		DB = oldDB
+	}()
+	
+	var err error
+	DB, err = utils.OpenDB(utils.Sqlite3, ":memory:")
+	assert.Nil(t, err)
+	DB.SetMaxOpenConns(1)
+	
+	// fts5 table exists after -migrate=up only if fts5 is compiled in
+	_, err = DB.Exec(`CREATE VIRTUAL TABLE fts_check USING fts5(a)`)
+	fts5 := err == nil || !strings.Contains(err.Error(), "no such module")
+	_, err = DB.Exec(`DROP TABLE IF EXISTS fts_check`)
+	assert.Nil(t, err)
+	
+	var w bytes.Buffer
+	assert.Nil(t, Migrate(&w, "status"))
+	exist, err := sqlite3RecordsFTSExist(DB)
+	assert.Nil(t, err)
+	assert.False(t, exist)
+	
+	assert.Nil(t, Migrate(&w, "up"))
+	exist, err = sqlite3RecordsFTSExist(DB)
+	assert.Nil(t, err)
+	assert.Equal(t, fts5, exist)
+	
+	// migrations which are applied already don't stop creating it
+	assert.Nil(t, Migrate(&w, "up"))
+}
+
+func TestGetRecordById(t *testing.T) {
+	userId := "reopen_user"
+	InsertRecordInfo(&Record{UserId: userId, Question: "reopen question", Answer: "reopen answer", RecordType: param.TextRecordType})
+	
+	records, err := SearchRecords(userId, "reopen", 10)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	
+	record, err := GetRecordById(userId, records[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "reopen answer", record.Answer)
+	
+	record, err = GetRecordById("other_user", records[0].ID)
+	assert.Nil(t, err)
+	assert.Nil(t, record)
+}
+
+func TestGetSnippet(t *testing.T) {
+	text := "aaaaaaaaaa bbbbbbbbbb cccccccccc nginx dddddddddd"
+	assert.Equal(t, "...ccccc nginx dddddddddd e...", GetSnippet(text+" eeeeeeeeee", []string{"NGINX"}, 24))
+	assert.Equal(t, "...ccccccccc nginx dddddddddd", GetSnippet(text, []string{"nginx"}, 26))
+	assert.Equal(t, "aaaaaaaaaa...", GetSnippet(text, nil, 10))
+	assert.Equal(t, "short text", GetSnippet("short\n text", []string{"text"}, 20))
+}
@@ -7,11 +7,13 @@ import (
 	
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/metrics"
+	"github.com/yincongcyincong/MuseBot/utils"
 )
 
 type User struct {
 	ID         int64  `json:"id"`
 	UserId     string `json:"user_id"`
+	Platform   string `json:"platform"`
 	Mode       string `json:"mode"`
 	Token      int    `json:"token"`
 	UpdateTime int64  `json:"update_time"`
@@ -30,14 +32,9 @@ func InsertUser(userId string, mode string) (int64, error) {
 	}
 	
 	// insert data
-	insertSQL := `INSERT INTO users (user_id, mode, update_time, create_time, avail_token) VALUES (?, ?, ?, ?, ?)`
-	result, err := DB.Exec(insertSQL, userId, mode, time.Now().Unix(), time.Now().Unix(), *conf.BaseConfInfo.TokenPerUser)
-	if err != nil {
-		return 0, err
-	}
-	
-	// get last insert id
-	id, err := result.LastInsertId()
+	platform, _ := ParseUserId(userId)
+	insertSQL := `INSERT INTO users (user_id, platform, mode, update_time, create_time, avail_token) VALUES (?, ?, ?, ?, ?, ?)`
+	id, err := insertAndGetId(insertSQL, userId, platform, mode, time.Now().Unix(), time.Now().Unix(), *conf.BaseConfInfo.TokenPerUser)
 	if err != nil {
 		return 0, err
 	}
@@ -137,7 +134,7 @@ func GetUserByPage(page, pageSize int, userId string) ([]User, error) {
 	
 	// 查询数据
 	listSQL := fmt.Sprintf(`
-		SELECT id, user_id, mode, token, update_time, avail_token, create_time
+		SELECT id, user_id, platform, mode, token, update_time, avail_token, create_time
 		FROM users %s
 		ORDER BY id DESC
 		LIMIT ? OFFSET ?`, whereSQL)
@@ -152,7 +149,7 @@ func GetUserByPage(page, pageSize int, userId string) ([]User, error) {
 	var users []User
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.ID, &u.UserId, &u.Mode, &u.Token, &u.UpdateTime, &u.AvailToken, &u.CreateTime); err != nil {
+		if err := rows.Scan(&u.ID, &u.UserId, &u.Platform, &u.Mode, &u.Token, &u.UpdateTime, &u.AvailToken, &u.CreateTime); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -212,6 +209,16 @@ func GetDailyNewUsers(days int) ([]DailyStat, error) {
 			GROUP BY time_group
 			ORDER BY time_group DESC;
 		`
+	} else if *conf.BaseConfInfo.DBType == utils.Postgres {
+		query = `
+			SELECT
+				(create_time / ?) * ? AS time_group,
+				COUNT(DISTINCT user_id) AS new_count
+			FROM users
+			WHERE create_time >= EXTRACT(EPOCH FROM NOW() - MAKE_INTERVAL(days => ?))
+			GROUP BY time_group
+			ORDER BY time_group DESC;
+		`
 	} else {
 		return nil, fmt.Errorf("unsupported DBType: %s", *conf.BaseConfInfo.DBType)
 	}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func GetAgentConf(w http.ResponseWriter, r *http.Request) {
+	config, err := conf.GetAgentConf()
+	if err != nil {
+		logger.Error("get agent conf error", "err", err)
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, err)
+		return
+	}
+	
+	utils.Success(w, config)
+}
+
+func UpdateAgentConf(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" || name == llm.LLMToolName || strings.ContainsAny(name, " \n\t") {
+		logger.Error("agent name is invalid", "name", name)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("agent name is invalid"))
+		return
+	}
+	
+	// mcp server name can't be used as agent name
+	if agentInter, ok := conf.TaskTools.Load(name); ok && !agentInter.(*conf.AgentInfo).IsUserDefined() {
+		logger.Error("agent name is used by mcp server", "name", name)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("agent name is used by mcp server"))
+		return
+	}
+	
+	agent := new(conf.AgentInfo)
+	err := utils.HandleJsonBody(r, agent)
+	if err != nil {
+		logger.Error("parse json body error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	if !agent.IsUserDefined() {
+		logger.Error("agent conf is empty", "name", name)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("agent need prompt, model or tools"))
+		return
+	}
+	
+	if agent.Type != "" && !param.LLMTypes[agent.Type] {
+		logger.Error("agent type is invalid", "name", name, "type", agent.Type)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("agent type is invalid"))
+		return
+	}
+	
+	config, err := conf.GetAgentConf()
+	if err != nil {
+		logger.Error("get agent conf error", "err", err)
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, err)
+		return
+	}
+	
+	config.Agents[name] = agent
+	err = conf.UpdateAgentConfFile(config)
+	if err != nil {
+		logger.Error("update agent conf error", "err", err)
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, err)
+		return
+	}
+	
+	conf.InsertAgent(name, agent)
+	utils.Success(w, "")
+}
+
+func DeleteAgentConf(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	
+	config, err := conf.GetAgentConf()
+	if err != nil {
+		logger.Error("get agent conf error", "err", err)
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, err)
+		return
+	}
+	
+	if config.Agents[name] == nil {
+		logger.Error("agent not found", "name", name)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("agent not found"))
+		return
+	}
+	
+	delete(config.Agents, name)
+	err = conf.UpdateAgentConfFile(config)
+	if err != nil {
+		logger.Error("update agent conf error", "err", err)
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, err)
+		return
+	}
+	
+	conf.TaskTools.Delete(name)
+	utils.Success(w, "")
+}
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"strconv"
 	
+	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
 	"github.com/yincongcyincong/MuseBot/robot"
 )
 
@@ -24,8 +26,10 @@ func Communicate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	realUserId := r.URL.Query().Get("user_id")
-	intUserId, _ := strconv.ParseInt(realUserId, 10, 64)
+	// web user id is qualified by web platform unless it is already qualified
+	platformUserId := r.URL.Query().Get("user_id")
+	intUserId, _ := strconv.ParseInt(platformUserId, 10, 64)
+	realUserId := db.ResolveUserId(param.WebPlatform, platformUserId)
 	
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -40,5 +44,6 @@ func Communicate(w http.ResponseWriter, r *http.Request) {
 	command, p := robot.ParseCommand(prompt)
 	
 	web := robot.NewWeb(command, intUserId, realUserId, p, prompt, fileData, w, flusher)
+	web.FileName = r.URL.Query().Get("file_name")
 	web.Exec()
 }
@@ -13,12 +13,12 @@ import (
 	"strings"
 	"time"
 	
-	"github.com/yincongcyincong/mcp-client-go/clients"
-	mcpParam "github.com/yincongcyincong/mcp-client-go/clients/param"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
 	"github.com/yincongcyincong/MuseBot/utils"
+	"github.com/yincongcyincong/mcp-client-go/clients"
+	mcpParam "github.com/yincongcyincong/mcp-client-go/clients/param"
 )
 
 type UpdateConfParam struct {
@@ -35,6 +35,7 @@ func GetCommand(w http.ResponseWriter, r *http.Request) {
 	res += CompareFlagsWithStructTags(conf.RagConfInfo)
 	res += CompareFlagsWithStructTags(conf.VideoConfInfo)
 	res += fmt.Sprintf("-mcp_conf_path=%s", *conf.McpConfPath)
+	res += fmt.Sprintf(" -agent_conf_path=%s", *conf.AgentConfPath)
 	utils.Success(w, res)
 }
 
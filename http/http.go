@@ -48,10 +48,22 @@ func (p *HTTPServer) Start() {
 		http.HandleFunc("/mcp/disable", DisableMCPConf)
 		http.HandleFunc("/mcp/delete", DeleteMCPConf)
 		http.HandleFunc("/mcp/sync", SyncMCPConf)
+		http.HandleFunc("/agent/get", GetAgentConf)
+		http.HandleFunc("/agent/update", UpdateAgentConf)
+		http.HandleFunc("/agent/delete", DeleteAgentConf)
 		
 		http.HandleFunc("/user/list", GetUsers)
 		http.HandleFunc("/user/update/mode", UpdateMode)
+		http.HandleFunc("/user/forget", ForgetUser)
+		http.HandleFunc("/user/forget/receipts", GetDeletionReceipts)
 		http.HandleFunc("/record/list", GetRecords)
+		http.HandleFunc("/record/search", SearchRecords)
+		
+		http.HandleFunc("/rag/file/list", GetRagFiles)
+		http.HandleFunc("/rag/file/upload", UploadRagFile)
+		http.HandleFunc("/rag/file/delete", DeleteRagFile)
+		http.HandleFunc("/rag/file/reindex", ReindexRagFile)
+		http.HandleFunc("/rag/query", QueryRag)
 		
 		http.HandleFunc("/pong", PongHandler)
 		http.HandleFunc("/dashboard", DashboardHandler)
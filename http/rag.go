@@ -0,0 +1,209 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/rag"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	maxRagUploadSize = 50 << 20
+)
+
+var (
+	RagNotEnableErr = errors.New("rag is not enabled")
+)
+
+type RagFileInfo struct {
+	*db.RagFiles
+	Chunks int `json:"chunks"`
+}
+
+type RagQueryResult struct {
+	Query   string        `json:"query"`
+	Sources []*rag.Source `json:"sources"`
+}
+
+func checkRag(w http.ResponseWriter) bool {
+	if conf.RagConfInfo.Store == nil {
+		utils.Failure(w, param.CodeConfigError, param.MsgConfigError, RagNotEnableErr)
+		return false
+	}
+	return true
+}
+
+// GetRagFiles list indexed knowledge files with number of chunks
+func GetRagFiles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := utils.ParseInt(query.Get("page"))
+	pageSize := utils.ParseInt(query.Get("page_size"))
+	fileName := strings.TrimSpace(query.Get("file_name"))
+	
+	ragFiles, err := db.GetRagFileByPage(page, pageSize, fileName)
+	if err != nil {
+		logger.Error("get rag files error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	total, err := db.GetRagFileCount(fileName)
+	if err != nil {
+		logger.Error("get rag file count error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	list := make([]*RagFileInfo, 0, len(ragFiles))
+	for _, ragFile := range ragFiles {
+		list = append(list, &RagFileInfo{
+			RagFiles: ragFile,
+			Chunks:   rag.GetChunkCount(ragFile),
+		})
+	}
+	
+	utils.Success(w, map[string]interface{}{
+		"list":  list,
+		"total": total,
+	})
+}
+
+// UploadRagFile save uploaded file into namespace of knowledge base and embed it
+func UploadRagFile(w http.ResponseWriter, r *http.Request) {
+	if !checkRag(w) {
+		return
+	}
+	
+	err := r.ParseMultipartForm(maxRagUploadSize)
+	if err != nil {
+		logger.Error("parse form error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		logger.Error("get upload file error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	defer file.Close()
+	
+	data, err := io.ReadAll(file)
+	if err != nil {
+		logger.Error("read upload file error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	
+	filePath, chunks, err := rag.LearnFile(ctx, r.FormValue("namespace"), r.FormValue("owner"), header.Filename, data)
+	if err != nil {
+		logger.Error("upload rag file error", "file", header.Filename, "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	utils.Success(w, map[string]interface{}{
+		"file_name": filePath,
+		"chunks":    chunks,
+	})
+}
+
+// DeleteRagFile delete knowledge file and its vectors
+func DeleteRagFile(w http.ResponseWriter, r *http.Request) {
+	if !checkRag(w) {
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	
+	fileName := r.URL.Query().Get("file_name")
+	err := rag.DeleteKnowledgeFile(ctx, fileName)
+	if err != nil {
+		logger.Error("delete rag file error", "file", fileName, "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	utils.Success(w, "")
+}
+
+// ReindexRagFile embed one knowledge file again, all files are reindexed if file_name is empty
+func ReindexRagFile(w http.ResponseWriter, r *http.Request) {
+	if !checkRag(w) {
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	
+	fileName := r.URL.Query().Get("file_name")
+	if fileName == "" {
+		res, err := rag.ReindexAll(ctx)
+		if err != nil {
+			logger.Error("reindex knowledge base error", "err", err)
+			utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+			return
+		}
+		utils.Success(w, res)
+		return
+	}
+	
+	chunks, err := rag.ReindexFile(ctx, fileName)
+	if err != nil {
+		logger.Error("reindex rag file error", "file", fileName, "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	
+	utils.Success(w, map[string]interface{}{
+		"file_name": fileName,
+		"chunks":    chunks,
+	})
+}
+
+// QueryRag search knowledge base like rag answer does, return scored chunks
+func QueryRag(w http.ResponseWriter, r *http.Request) {
+	if !checkRag(w) {
+		return
+	}
+	
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("query"))
+	if q == "" {
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("query is empty"))
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	
+	retriever := rag.NewConfRetriever(query.Get("namespace"))
+	if topK := utils.ParseInt(query.Get("top_k")); topK > 0 {
+		retriever.NumDocuments = topK
+	}
+	docs, err := retriever.GetRelevantDocuments(ctx, q)
+	if err != nil {
+		logger.Error("query knowledge base error", "err", err)
+		utils.Failure(w, param.CodeServerFail, param.MsgServerFail, err)
+		return
+	}
+	
+	utils.Success(w, &RagQueryResult{
+		Query:   q,
+		Sources: rag.GetSources(docs),
+	})
+}
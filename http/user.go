@@ -1,11 +1,16 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 	
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/logger"
 	"github.com/yincongcyincong/MuseBot/param"
+	"github.com/yincongcyincong/MuseBot/rag"
 	"github.com/yincongcyincong/MuseBot/utils"
 )
 
@@ -14,6 +19,10 @@ type UserToken struct {
 	Token  int    `json:"token"`
 }
 
+type UserForget struct {
+	UserID string `json:"user_id"`
+}
+
 func AddUserToken(w http.ResponseWriter, r *http.Request) {
 	userToken := &UserToken{}
 	err := utils.HandleJsonBody(r, userToken)
@@ -131,3 +140,84 @@ func GetRecords(w http.ResponseWriter, r *http.Request) {
 	
 	utils.Success(w, result)
 }
+
+// SearchRecords full text search records of user, limit is 10 by default
+func SearchRecords(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userId := query.Get("user_id")
+	keyword := strings.TrimSpace(query.Get("query"))
+	limit := utils.ParseInt(query.Get("limit"))
+	if userId == "" || keyword == "" {
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("user_id and query are required"))
+		return
+	}
+	
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	
+	list, err := db.SearchRecords(userId, keyword, limit)
+	if err != nil {
+		logger.Error("search records error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	utils.Success(w, map[string]interface{}{
+		"list": list,
+	})
+}
+
+// ForgetUser hard delete all data of user, vectors of knowledge files uploaded by user are deleted too
+func ForgetUser(w http.ResponseWriter, r *http.Request) {
+	userForget := &UserForget{}
+	err := utils.HandleJsonBody(r, userForget)
+	if err != nil {
+		logger.Error("parse json body error", "err", err)
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, err)
+		return
+	}
+	if strings.TrimSpace(userForget.UserID) == "" {
+		utils.Failure(w, param.CodeParamError, param.MsgParamError, errors.New("user_id is required"))
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	
+	receipt, err := rag.ForgetUser(ctx, userForget.UserID)
+	if err != nil {
+		logger.Error("forget user error", "err", err)
+		utils.Failure(w, param.CodeDBWriteFail, param.MsgDBWriteFail, err)
+		return
+	}
+	
+	utils.Success(w, receipt)
+}
+
+// GetDeletionReceipts get deletion receipts of user, all receipts if user_id is empty. hash chain of receipts is verified
+func GetDeletionReceipts(w http.ResponseWriter, r *http.Request) {
+	userHash := ""
+	if userId := r.URL.Query().Get("user_id"); userId != "" {
+		userHash = db.HashUserId(userId)
+	}
+	
+	total, err := db.VerifyDeletionReceipts()
+	if err != nil {
+		logger.Error("verify deletion receipts error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	list, err := db.GetDeletionReceipts(userHash)
+	if err != nil {
+		logger.Error("get deletion receipts error", "err", err)
+		utils.Failure(w, param.CodeDBQueryFail, param.MsgDBQueryFail, err)
+		return
+	}
+	
+	utils.Success(w, map[string]interface{}{
+		"list":  list,
+		"total": total,
+	})
+}
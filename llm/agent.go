@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+// ExecuteAgent execute user defined agent, content format: <agent name> <prompt>
+func (d *LLMTaskReq) ExecuteAgent() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+	
+	name, prompt := ParseAgentContent(d.Content)
+	if name == "" || prompt == "" {
+		return errors.New("usage: /agent <name> <prompt>")
+	}
+	
+	agent, err := conf.GetAgent(name)
+	if err != nil {
+		logger.Warn("get agent fail", "agent", name, "err", err)
+		return err
+	}
+	
+	logger.Info("agent content", "agent", name, "content", prompt)
+	agentLLM := NewLLM(WithChatId(d.ChatId), WithMsgId(d.MsgId), WithUserId(d.UserId),
+		WithMessageChan(d.MessageChan), WithHTTPMsgChan(d.HTTPMsgChan), WithContent(prompt), WithAgent(agent))
+	if agent.Prompt != "" {
+		agentLLM.LLMClient.GetSystemMessage(agent.Prompt)
+	}
+	agentLLM.LLMClient.GetUserMessage(prompt)
+	agentLLM.LLMClient.GetModel(agentLLM)
+	err = agentLLM.LLMClient.Send(ctx, agentLLM)
+	if err != nil {
+		logger.Error("execute agent fail", "agent", name, "err", err)
+		return err
+	}
+	d.Token += agentLLM.Token
+	
+	return nil
+}
+
+// ParseAgentContent split content into agent name and prompt
+func ParseAgentContent(content string) (string, string) {
+	content = strings.TrimSpace(content)
+	idx := strings.IndexAny(content, " \n\t")
+	if idx < 0 {
+		return content, ""
+	}
+	
+	return content[:idx], strings.TrimSpace(content[idx+1:])
+}
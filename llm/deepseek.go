@@ -29,6 +29,10 @@ type DeepseekReq struct {
 }
 
 func (d *DeepseekReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = deepseek.DeepSeekChat
 	userInfo, err := db.GetUserByID(l.UserId)
 	if err != nil {
@@ -115,7 +119,7 @@ func (d *DeepseekReq) Send(ctx context.Context, l *LLM) error {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.DeepseekTools,
 	}
 	
@@ -207,6 +211,16 @@ func (d *DeepseekReq) GetAssistantMessage(msg string) {
 	d.GetMessage(constants.ChatMessageRoleAssistant, msg)
 }
 
+// GetSystemMessage put system prompt at the beginning of messages
+func (d *DeepseekReq) GetSystemMessage(msg string) {
+	d.DeepseekMsgs = append([]deepseek.ChatCompletionMessage{
+		{
+			Role:    constants.ChatMessageRoleSystem,
+			Content: msg,
+		},
+	}, d.DeepseekMsgs...)
+}
+
 func (d *DeepseekReq) AppendMessages(client LLMClient) {
 	if len(d.DeepseekMsgs) == 0 {
 		d.DeepseekMsgs = make([]deepseek.ChatCompletionMessage, 0)
@@ -257,11 +271,17 @@ func (d *DeepseekReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Messages:         d.DeepseekMsgs,
 		Tools:            l.DeepseekTools,
 	}
 	
+	if l.ResponseSchema != nil {
+		request.ResponseFormat = &deepseek.ResponseFormat{
+			Type: "json_object",
+		}
+	}
+	
 	// assign task
 	response, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
@@ -23,7 +23,8 @@ type GeminiReq struct {
 	ToolMessage        []*genai.Content
 	CurrentToolMessage []*genai.Content
 	
-	GeminiMsgs []*genai.Content
+	GeminiMsgs   []*genai.Content
+	SystemPrompt string
 }
 
 func (h *GeminiReq) GetMessages(userId string, prompt string) {
@@ -87,10 +88,14 @@ func (h *GeminiReq) Send(ctx context.Context, l *LLM) error {
 		TopP:             genai.Ptr[float32](float32(*conf.LLMConfInfo.TopP)),
 		FrequencyPenalty: genai.Ptr[float32](float32(*conf.LLMConfInfo.FrequencyPenalty)),
 		PresencePenalty:  genai.Ptr[float32](float32(*conf.LLMConfInfo.PresencePenalty)),
-		Temperature:      genai.Ptr[float32](float32(*conf.LLMConfInfo.Temperature)),
+		Temperature:      genai.Ptr[float32](float32(l.GetTemperature())),
 		Tools:            l.GeminiTools,
 	}
 	
+	if h.SystemPrompt != "" {
+		config.SystemInstruction = genai.NewContentFromText(h.SystemPrompt, genai.RoleUser)
+	}
+	
 	chat, err := client.Chats.Create(ctx, l.Model, config, h.GeminiMsgs)
 	if err != nil {
 		logger.Error("create chat fail", "err", err)
@@ -169,6 +174,11 @@ func (h *GeminiReq) GetAssistantMessage(msg string) {
 	h.GetMessage(genai.RoleModel, msg)
 }
 
+// GetSystemMessage gemini use system instruction in config
+func (h *GeminiReq) GetSystemMessage(msg string) {
+	h.SystemPrompt = msg
+}
+
 func (h *GeminiReq) AppendMessages(client LLMClient) {
 	if len(h.GeminiMsgs) == 0 {
 		h.GeminiMsgs = make([]*genai.Content, 0)
@@ -219,10 +229,20 @@ func (h *GeminiReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		TopP:             genai.Ptr[float32](float32(*conf.LLMConfInfo.TopP)),
 		FrequencyPenalty: genai.Ptr[float32](float32(*conf.LLMConfInfo.FrequencyPenalty)),
 		PresencePenalty:  genai.Ptr[float32](float32(*conf.LLMConfInfo.PresencePenalty)),
-		Temperature:      genai.Ptr[float32](float32(*conf.LLMConfInfo.Temperature)),
+		Temperature:      genai.Ptr[float32](float32(l.GetTemperature())),
 		Tools:            l.GeminiTools,
 	}
 	
+	if h.SystemPrompt != "" {
+		config.SystemInstruction = genai.NewContentFromText(h.SystemPrompt, genai.RoleUser)
+	}
+	
+	// gemini doesn't support function calling with response schema
+	if l.ResponseSchema != nil && len(l.GeminiTools) == 0 {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = l.ResponseSchema.GeminiSchema()
+	}
+	
 	chat, err := client.Chats.Create(ctx, l.Model, config, h.GeminiMsgs)
 	if err != nil {
 		logger.Error("create chat fail", "updateMsgID", l.MsgId, "err", err)
@@ -341,6 +361,10 @@ func (h *GeminiReq) RequestToolsCall(ctx context.Context, response *genai.Genera
 }
 
 func (h *GeminiReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = param.ModelGemini20Flash
 	userInfo, err := db.GetUserByID(l.UserId)
 	if err != nil {
@@ -46,6 +46,11 @@ type LLM struct {
 	GeminiTools     []*genai.Tool
 	OpenRouterTools []openrouter.Tool
 	
+	ResponseSchema *JsonSchema // response json in SyncSend when it is set
+	
+	Agent *conf.AgentInfo // user defined agent, overrides llm type, model and temperature
+	
+	AnswerPrefix string // shown before first chunk of answer, not kept in WholeContent
 	WholeContent string // whole answer from llm
 	LoopNum      int
 }
@@ -59,6 +64,8 @@ type LLMClient interface {
 	
 	GetAssistantMessage(msg string)
 	
+	GetSystemMessage(msg string)
+	
 	AppendMessages(client LLMClient)
 	
 	SyncSend(ctx context.Context, l *LLM) (string, error)
@@ -92,7 +99,12 @@ func NewLLM(opts ...Option) *LLM {
 		opt(l)
 	}
 	
-	switch *conf.BaseConfInfo.Type {
+	llmType := *conf.BaseConfInfo.Type
+	if l.Agent != nil && l.Agent.Type != "" {
+		llmType = l.Agent.Type
+	}
+	
+	switch llmType {
 	case param.DeepSeek:
 		l.LLMClient = &DeepseekReq{
 			ToolCall:           []godeepseek.ToolCall{},
@@ -135,6 +147,12 @@ func NewLLM(opts ...Option) *LLM {
 }
 
 func (l *LLM) SendMsg(msgInfoContent *param.MsgInfo, content string) *param.MsgInfo {
+	showContent := content
+	if l.AnswerPrefix != "" {
+		showContent = l.AnswerPrefix + content
+		l.AnswerPrefix = ""
+	}
+	
 	if l.MessageChan != nil {
 		// exceed max one message length
 		if utils.Utf16len(msgInfoContent.Content) > OneMsgLen {
@@ -144,7 +162,7 @@ func (l *LLM) SendMsg(msgInfoContent *param.MsgInfo, content string) *param.MsgI
 			}
 		}
 		
-		msgInfoContent.Content += content
+		msgInfoContent.Content += showContent
 		l.WholeContent += content
 		if len(msgInfoContent.Content) > msgInfoContent.SendLen {
 			l.MessageChan <- msgInfoContent
@@ -154,11 +172,28 @@ func (l *LLM) SendMsg(msgInfoContent *param.MsgInfo, content string) *param.MsgI
 		return msgInfoContent
 	} else {
 		l.WholeContent += content
-		l.HTTPMsgChan <- content
+		l.HTTPMsgChan <- showContent
 		return nil
 	}
 }
 
+// GetTemperature get temperature of agent first, then llm conf
+func (l *LLM) GetTemperature() float64 {
+	if l.Agent != nil && l.Agent.Temperature != nil {
+		return *l.Agent.Temperature
+	}
+	return *conf.LLMConfInfo.Temperature
+}
+
+// GetAgentModel set model of agent, return false if agent doesn't have model
+func (l *LLM) GetAgentModel() bool {
+	if l.Agent != nil && l.Agent.Model != "" {
+		l.Model = l.Agent.Model
+		return true
+	}
+	return false
+}
+
 func (l *LLM) OverLoop() bool {
 	if l.LoopNum >= MostLoop {
 		return true
@@ -199,6 +234,12 @@ func WithHTTPMsgChan(messageChan chan string) Option {
 	}
 }
 
+func WithAnswerPrefix(prefix string) Option {
+	return func(p *LLM) {
+		p.AnswerPrefix = prefix
+	}
+}
+
 func WithChatId(chatId string) Option {
 	return func(p *LLM) {
 		p.ChatId = chatId
@@ -234,3 +275,16 @@ func WithTaskTools(taskTool *conf.AgentInfo) Option {
 		p.OpenRouterTools = taskTool.OpenRouterTools
 	}
 }
+
+func WithResponseSchema(schema *JsonSchema) Option {
+	return func(p *LLM) {
+		p.ResponseSchema = schema
+	}
+}
+
+func WithAgent(agent *conf.AgentInfo) Option {
+	return func(p *LLM) {
+		WithTaskTools(agent)(p)
+		p.Agent = agent
+	}
+}
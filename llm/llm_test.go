@@ -5,6 +5,7 @@ import (
 	"time"
 	
 	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/param"
 )
 
@@ -30,6 +31,23 @@ func TestSendMsg_WithHTTPMsgChan(t *testing.T) {
 	}
 }
 
+func TestSendMsg_AnswerPrefix(t *testing.T) {
+	httpChan := make(chan string, 2)
+	l := &LLM{HTTPMsgChan: httpChan, AnswerPrefix: "agents\n\n"}
+	
+	l.SendMsg(&param.MsgInfo{}, "first")
+	l.SendMsg(&param.MsgInfo{}, " second")
+	assert.Equal(t, "agents\n\nfirst", <-httpChan)
+	assert.Equal(t, " second", <-httpChan)
+	assert.Equal(t, "first second", l.WholeContent)
+	
+	msgChan := make(chan *param.MsgInfo, 1)
+	l = &LLM{MessageChan: msgChan, AnswerPrefix: "agents\n\n"}
+	msg := l.SendMsg(&param.MsgInfo{SendLen: 100}, "hello")
+	assert.Equal(t, "agents\n\nhello", msg.Content)
+	assert.Equal(t, "hello", l.WholeContent)
+}
+
 func TestOverLoop(t *testing.T) {
 	l := &LLM{LoopNum: 9}
 	assert.False(t, l.OverLoop())
@@ -50,3 +68,10 @@ func TestNewLLM_DefaultsToClient(t *testing.T) {
 	assert.Equal(t, "ask", l.Content)
 	assert.Equal(t, "m1", l.Model)
 }
+
+func TestNewLLM_AgentTypes(t *testing.T) {
+	for llmType := range param.LLMTypes {
+		l := NewLLM(WithAgent(&conf.AgentInfo{Type: llmType}))
+		assert.NotNil(t, l.LLMClient, llmType)
+	}
+}
@@ -2,8 +2,8 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
-	"regexp"
+	"errors"
+	"strings"
 	"time"
 	
 	"github.com/yincongcyincong/MuseBot/conf"
@@ -11,12 +11,8 @@ import (
 	"github.com/yincongcyincong/MuseBot/logger"
 )
 
-var (
-	mcpRe = regexp.MustCompile(`\{\s*"agent"\s*:\s*"[^\"]*"\s*\}`)
-)
-
 type McpResult struct {
-	Agent string `json:"agent"`
+	Agents []string `json:"agents"` // ranked from the most relevant agent
 }
 
 // ExecuteMcp execute mcp request
@@ -44,31 +40,45 @@ func (d *LLMTaskReq) ExecuteMcp() error {
 	prompt := i18n.GetMessage(*conf.BaseConfInfo.Lang, "mcp_prompt", taskParam)
 	llm.LLMClient.GetUserMessage(prompt)
 	llm.Content = prompt
-	c, err := llm.LLMClient.SyncSend(ctx, llm)
-	if err != nil {
+	mcpResult := new(McpResult)
+	_, err := SyncSendJson(ctx, llm, McpSchema(), mcpResult)
+	if err != nil && !errors.Is(err, JsonInvalidErr) {
 		logger.Error("get message fail", "err", err)
 		return err
 	}
 	
-	matches := mcpRe.FindAllString(c, -1)
-	mcpResult := new(McpResult)
-	for _, match := range matches {
-		err := json.Unmarshal([]byte(match), mcpResult)
-		if err != nil {
-			logger.Error("json umarshal fail", "err", err)
+	// merge tools of all selected agents
+	var taskTool *conf.AgentInfo
+	selectedAgents := make([]string, 0, len(mcpResult.Agents))
+	agentInfos := make([]*conf.AgentInfo, 0, len(mcpResult.Agents))
+	selected := make(map[string]bool)
+	for _, agent := range mcpResult.Agents {
+		if selected[agent] {
+			continue
+		}
+		selected[agent] = true
+		selectedAgents = append(selectedAgents, agent)
+		
+		taskToolInter, ok := conf.TaskTools.Load(agent)
+		if ok {
+			agentInfos = append(agentInfos, taskToolInter.(*conf.AgentInfo))
 		}
 	}
-	
-	// execute mcp request
-	var taskTool *conf.AgentInfo
-	taskToolInter, ok := conf.TaskTools.Load(mcpResult.Agent)
-	if ok {
-		taskTool = taskToolInter.(*conf.AgentInfo)
+	if len(agentInfos) > 0 {
+		taskTool = conf.MergeAgentInfo(agentInfos...)
 	}
+	
+	logger.Info("mcp agents selected", "agents", selectedAgents)
+	
+	// execute mcp request, selected agents are shown before the answer
 	mcpLLM := NewLLM(WithChatId(d.ChatId), WithMsgId(d.MsgId), WithUserId(d.UserId),
-		WithMessageChan(d.MessageChan), WithContent(d.Content), WithTaskTools(taskTool))
+		WithMessageChan(d.MessageChan), WithContent(d.Content), WithHTTPMsgChan(d.HTTPMsgChan),
+		WithAgent(taskTool), WithAnswerPrefix(selectedAgentsPrefix(selectedAgents)))
 	mcpLLM.Token += llm.Token
 	mcpLLM.Content = d.Content
+	if taskTool != nil && taskTool.Prompt != "" {
+		mcpLLM.LLMClient.GetSystemMessage(taskTool.Prompt)
+	}
 	mcpLLM.LLMClient.GetUserMessage(d.Content)
 	mcpLLM.LLMClient.GetModel(mcpLLM)
 	err = mcpLLM.LLMClient.Send(ctx, mcpLLM)
@@ -78,3 +88,33 @@ func (d *LLMTaskReq) ExecuteMcp() error {
 	
 	return err
 }
+
+// McpSchema json schema of mcp agent selection
+func McpSchema() *JsonSchema {
+	return &JsonSchema{
+		Name: "mcp_agent",
+		Type: "object",
+		Properties: map[string]*JsonSchema{
+			"agents": {
+				Type:        "array",
+				Description: "agent names ranked from the most relevant one",
+				Items: &JsonSchema{
+					Type: "string",
+					Enum: GetAgentNames(),
+				},
+			},
+		},
+		Required: []string{"agents"},
+	}
+}
+
+// selectedAgentsPrefix show user which agents are selected
+func selectedAgentsPrefix(agents []string) string {
+	if len(agents) == 0 {
+		return ""
+	}
+	
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "mcp_agents_selected", map[string]interface{}{
+		"agents": strings.Join(agents, ", "),
+	}) + "\n\n"
+}
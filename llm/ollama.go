@@ -29,6 +29,10 @@ type OllamaDeepseekReq struct {
 }
 
 func (d *OllamaDeepseekReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = "llava:latest"
 }
 
@@ -94,7 +98,7 @@ func (d *OllamaDeepseekReq) Send(ctx context.Context, l *LLM) error {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 	}
 	
 	request.Messages = d.DeepseekMsgs
@@ -185,6 +189,16 @@ func (d *OllamaDeepseekReq) GetAssistantMessage(msg string) {
 	d.GetMessage(constants.ChatMessageRoleAssistant, msg)
 }
 
+// GetSystemMessage put system prompt at the beginning of messages
+func (d *OllamaDeepseekReq) GetSystemMessage(msg string) {
+	d.DeepseekMsgs = append([]deepseek.ChatCompletionMessage{
+		{
+			Role:    constants.ChatMessageRoleSystem,
+			Content: msg,
+		},
+	}, d.DeepseekMsgs...)
+}
+
 func (d *OllamaDeepseekReq) AppendMessages(client LLMClient) {
 	if len(d.DeepseekMsgs) == 0 {
 		d.DeepseekMsgs = make([]deepseek.ChatCompletionMessage, 0)
@@ -229,11 +243,17 @@ func (d *OllamaDeepseekReq) SyncSend(ctx context.Context, l *LLM) (string, error
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Messages:         d.DeepseekMsgs,
 		Tools:            l.DeepseekTools,
 	}
 	
+	if l.ResponseSchema != nil {
+		request.ResponseFormat = &deepseek.ResponseFormat{
+			Type: "json_object",
+		}
+	}
+	
 	// assign task
 	response, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
@@ -33,6 +33,10 @@ type OpenAIReq struct {
 }
 
 func (d *OpenAIReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = openai.GPT3Dot5Turbo0125
 	userInfo, err := db.GetUserByID(l.UserId)
 	if err != nil {
@@ -119,7 +123,7 @@ func (d *OpenAIReq) Send(ctx context.Context, l *LLM) error {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.OpenAITools,
 	}
 	
@@ -210,6 +214,16 @@ func (d *OpenAIReq) GetAssistantMessage(msg string) {
 	d.GetMessage(openai.ChatMessageRoleAssistant, msg)
 }
 
+// GetSystemMessage put system prompt at the beginning of messages
+func (d *OpenAIReq) GetSystemMessage(msg string) {
+	d.OpenAIMsgs = append([]openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: msg,
+		},
+	}, d.OpenAIMsgs...)
+}
+
 func (d *OpenAIReq) AppendMessages(client LLMClient) {
 	if len(d.OpenAIMsgs) == 0 {
 		d.OpenAIMsgs = make([]openai.ChatCompletionMessage, 0)
@@ -258,12 +272,22 @@ func (d *OpenAIReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.OpenAITools,
 	}
 	
 	request.Messages = d.OpenAIMsgs
 	
+	if l.ResponseSchema != nil {
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   l.ResponseSchema.Name,
+				Schema: l.ResponseSchema,
+			},
+		}
+	}
+	
 	response, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
 		logger.Error("ChatCompletionStream error", "updateMsgID", l.MsgId, "err", err)
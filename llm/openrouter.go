@@ -29,6 +29,10 @@ type AIRouterReq struct {
 }
 
 func (d *AIRouterReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = param.DeepseekDeepseekR1_0528Free
 	userInfo, err := db.GetUserByID(l.UserId)
 	if err != nil {
@@ -129,7 +133,7 @@ func (d *AIRouterReq) Send(ctx context.Context, l *LLM) error {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.OpenRouterTools,
 	}
 	
@@ -223,6 +227,23 @@ func (d *AIRouterReq) GetAssistantMessage(msg string) {
 	d.GetMessage(openrouter.ChatMessageRoleAssistant, msg)
 }
 
+// GetSystemMessage put system prompt at the beginning of messages
+func (d *AIRouterReq) GetSystemMessage(msg string) {
+	d.OpenRouterMsgs = append([]openrouter.ChatCompletionMessage{
+		{
+			Role: openrouter.ChatMessageRoleSystem,
+			Content: openrouter.Content{
+				Multi: []openrouter.ChatMessagePart{
+					{
+						Type: openrouter.ChatMessagePartTypeText,
+						Text: msg,
+					},
+				},
+			},
+		},
+	}, d.OpenRouterMsgs...)
+}
+
 func (d *AIRouterReq) AppendMessages(client LLMClient) {
 	if len(d.OpenRouterMsgs) == 0 {
 		d.OpenRouterMsgs = make([]openrouter.ChatCompletionMessage, 0)
@@ -277,11 +298,21 @@ func (d *AIRouterReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.OpenRouterTools,
 		Messages:         d.OpenRouterMsgs,
 	}
 	
+	if l.ResponseSchema != nil {
+		request.ResponseFormat = &openrouter.ChatCompletionResponseFormat{
+			Type: openrouter.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openrouter.ChatCompletionResponseFormatJSONSchema{
+				Name:   l.ResponseSchema.Name,
+				Schema: l.ResponseSchema,
+			},
+		}
+	}
+	
 	// assign task
 	response, err := client.CreateChatCompletion(ctx, request)
 	if err != nil {
@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"google.golang.org/genai"
+)
+
+const (
+	MostJsonRetry = 2
+)
+
+var (
+	JsonNotFoundErr = errors.New("json object not found")
+	JsonInvalidErr  = errors.New("llm response is invalid json")
+)
+
+// JsonSchema a subset of json schema, used for provider json mode and validating response
+type JsonSchema struct {
+	Name        string                 `json:"-"`
+	Type        string                 `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*JsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JsonSchema            `json:"items,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+}
+
+type jsonSchemaAlias JsonSchema
+
+// MarshalJSON implement json.Marshaler for openai json schema response format
+func (s *JsonSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal((*jsonSchemaAlias)(s))
+}
+
+// String schema content put into prompt
+func (s *JsonSchema) String() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// GeminiSchema trans schema to gemini response schema
+func (s *JsonSchema) GeminiSchema() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	
+	gs := &genai.Schema{
+		Type:        genai.Type(strings.ToUpper(s.Type)),
+		Description: s.Description,
+		Required:    s.Required,
+		Enum:        s.Enum,
+		Items:       s.Items.GeminiSchema(),
+	}
+	if len(s.Properties) > 0 {
+		gs.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			gs.Properties[name] = property.GeminiSchema()
+		}
+	}
+	
+	return gs
+}
+
+// Validate check value decoded from json match the schema
+func (s *JsonSchema) Validate(value interface{}) error {
+	return s.validate("$", value)
+}
+
+func (s *JsonSchema) validate(path string, value interface{}) error {
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s should be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, property := range s.Properties {
+			if v, ok := obj[name]; ok {
+				if err := property.validate(path+"."+name, v); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s should be an array", path)
+		}
+		if s.Items != nil {
+			for i, v := range arr {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), v); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s should be a string", path)
+		}
+		if len(s.Enum) > 0 {
+			for _, e := range s.Enum {
+				if e == str {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s should be one of [%s], got %q", path, strings.Join(s.Enum, ", "), str)
+		}
+	case "number", "integer":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s should be a number", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s should be a boolean", path)
+		}
+	}
+	
+	return nil
+}
+
+// ExtractJson get json object from llm response, response may be wrapped by markdown or other words
+func ExtractJson(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if start := strings.Index(content, "```"); start >= 0 {
+		block := content[start+3:]
+		if end := strings.Index(block, "```"); end >= 0 {
+			block = strings.TrimPrefix(block[:end], "json")
+			if strings.HasPrefix(strings.TrimSpace(block), "{") {
+				content = block
+			}
+		}
+	}
+	
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return "", JsonNotFoundErr
+	}
+	
+	return content[start : end+1], nil
+}
+
+// ParseJson extract json from content, validate it and unmarshal it into v
+func ParseJson(content string, schema *JsonSchema, v interface{}) error {
+	jsonStr, err := ExtractJson(content)
+	if err != nil {
+		return err
+	}
+	
+	var value interface{}
+	err = json.Unmarshal([]byte(jsonStr), &value)
+	if err != nil {
+		return err
+	}
+	
+	if schema != nil {
+		err = schema.Validate(value)
+		if err != nil {
+			return err
+		}
+	}
+	
+	return json.Unmarshal([]byte(jsonStr), v)
+}
+
+// SyncSendJson request llm in json mode, if response not match schema, send the validation error
+// to llm and retry. JsonInvalidErr is returned with the last response when all retries fail.
+func SyncSendJson(ctx context.Context, l *LLM, schema *JsonSchema, v interface{}) (string, error) {
+	l.ResponseSchema = schema
+	defer func() {
+		l.ResponseSchema = nil
+	}()
+	
+	var c string
+	var err error
+	for i := 0; i <= MostJsonRetry; i++ {
+		c, err = l.LLMClient.SyncSend(ctx, l)
+		if err != nil {
+			return "", err
+		}
+		
+		err = ParseJson(c, schema, v)
+		if err == nil {
+			return c, nil
+		}
+		
+		logger.Warn("llm response not match schema", "retry", i, "err", err, "content", c)
+		if i < MostJsonRetry {
+			retryPrompt := i18n.GetMessage(*conf.BaseConfInfo.Lang, "json_retry_prompt", map[string]interface{}{
+				"err":    err.Error(),
+				"schema": schema.String(),
+			})
+			l.LLMClient.GetAssistantMessage(c)
+			l.LLMClient.GetUserMessage(retryPrompt)
+			l.Content = retryPrompt
+		}
+	}
+	
+	return c, fmt.Errorf("%w: %v", JsonInvalidErr, err)
+}
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeJsonClient struct {
+	responses []string
+	err       error
+	schemas   []*JsonSchema
+}
+
+func (f *fakeJsonClient) GetMessages(userId string, prompt string) {}
+
+func (f *fakeJsonClient) Send(ctx context.Context, l *LLM) error { return nil }
+
+func (f *fakeJsonClient) GetUserMessage(msg string) {}
+
+func (f *fakeJsonClient) GetAssistantMessage(msg string) {}
+
+func (f *fakeJsonClient) GetSystemMessage(msg string) {}
+
+func (f *fakeJsonClient) AppendMessages(client LLMClient) {}
+
+func (f *fakeJsonClient) GetModel(l *LLM) {}
+
+func (f *fakeJsonClient) SyncSend(ctx context.Context, l *LLM) (string, error) {
+	f.schemas = append(f.schemas, l.ResponseSchema)
+	if f.err != nil {
+		return "", f.err
+	}
+	res := f.responses[0]
+	f.responses = f.responses[1:]
+	return res, nil
+}
+
+func TestExtractJson(t *testing.T) {
+	res, err := ExtractJson("here is plan:\n```json\n{\"plan\": []}\n```\nthanks")
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"plan\": []}", res)
+	
+	res, err = ExtractJson("{\"agent\": \"llm_tool\"}")
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"agent\": \"llm_tool\"}", res)
+	
+	_, err = ExtractJson("no json here")
+	assert.Equal(t, JsonNotFoundErr, err)
+}
+
+func TestJsonSchema_Validate(t *testing.T) {
+	schema := TaskSchema()
+	
+	plans := new(TaskInfo)
+	err := ParseJson(`{"plan": [{"name": "llm_tool", "description": "write code"}]}`, schema, plans)
+	assert.Nil(t, err)
+	assert.Len(t, plans.Plan, 1)
+	assert.Equal(t, "write code", plans.Plan[0].Description)
+	
+	err = ParseJson(`{"plan": [{"name": "unknown_agent", "description": "write code"}]}`, schema, new(TaskInfo))
+	assert.ErrorContains(t, err, "$.plan[0].name should be one of")
+	
+	err = ParseJson(`{"plan": [{"name": "llm_tool"}]}`, schema, new(TaskInfo))
+	assert.ErrorContains(t, err, "$.plan[0].description is required")
+	
+	err = ParseJson(`{"plan": "llm_tool"}`, schema, new(TaskInfo))
+	assert.ErrorContains(t, err, "$.plan should be an array")
+}
+
+func TestJsonSchema_GeminiSchema(t *testing.T) {
+	gs := McpSchema().GeminiSchema()
+	assert.Equal(t, "OBJECT", string(gs.Type))
+	assert.Equal(t, "ARRAY", string(gs.Properties["agents"].Type))
+	assert.Equal(t, "STRING", string(gs.Properties["agents"].Items.Type))
+	assert.Equal(t, []string{"agents"}, gs.Required)
+}
+
+func TestSyncSendJson(t *testing.T) {
+	client := &fakeJsonClient{responses: []string{`{"agents": ["llm_tool"]}`}}
+	l := &LLM{LLMClient: client}
+	
+	res := new(McpResult)
+	_, err := SyncSendJson(context.Background(), l, McpSchema(), res)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"llm_tool"}, res.Agents)
+	assert.NotNil(t, client.schemas[0])
+	assert.Nil(t, l.ResponseSchema)
+	
+	requestErr := errors.New("request fail")
+	_, err = SyncSendJson(context.Background(), &LLM{LLMClient: &fakeJsonClient{err: requestErr}}, McpSchema(), res)
+	assert.Equal(t, requestErr, err)
+}
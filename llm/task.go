@@ -2,9 +2,7 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"regexp"
 	"time"
 	
 	"github.com/yincongcyincong/MuseBot/conf"
@@ -13,8 +11,8 @@ import (
 	"github.com/yincongcyincong/MuseBot/param"
 )
 
-var (
-	jsonRe = regexp.MustCompile(`(?s)\{[\s\r\n]*"plan"\s*:\s*\[.*?][\s\r\n]*}`)
+const (
+	LLMToolName = "llm_tool"
 )
 
 type LLMTaskReq struct {
@@ -66,23 +64,15 @@ func (d *LLMTaskReq) ExecuteTask() error {
 		WithMessageChan(d.MessageChan), WithContent(prompt), WithHTTPMsgChan(d.HTTPMsgChan))
 	llm.LLMClient.GetUserMessage(prompt)
 	llm.LLMClient.GetModel(llm)
-	c, err := llm.LLMClient.SyncSend(ctx, llm)
-	if err != nil {
+	plans := new(TaskInfo)
+	c, err := SyncSendJson(ctx, llm, TaskSchema(), plans)
+	if err != nil && !errors.Is(err, JsonInvalidErr) {
 		logger.Error("get message fail", "err", err)
 		return err
 	}
 	
 	d.Token += llm.Token
 	
-	matches := jsonRe.FindAllString(c, -1)
-	plans := new(TaskInfo)
-	for _, match := range matches {
-		err = json.Unmarshal([]byte(match), &plans)
-		if err != nil {
-			logger.Warn("json umarshal fail", "err", err)
-		}
-	}
-	
 	if len(plans.Plan) == 0 {
 		logger.Info("no plan created!")
 		
@@ -133,11 +123,20 @@ func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan str
 		if ok {
 			tool = toolInter.(*conf.AgentInfo)
 		}
+		
+		logger.Info("execute task", "task", plan.Name)
+		if tool != nil && tool.IsUserDefined() {
+			err := d.requestAgent(ctx, taskLLM, tool, plan)
+			if err != nil {
+				return err
+			}
+			completeTasks[plan.Description] = true
+			continue
+		}
+		
 		WithTaskTools(tool)(taskLLM)
 		taskLLM.LLMClient.GetUserMessage(plan.Description)
 		taskLLM.Content = plan.Description
-		
-		logger.Info("execute task", "task", plan.Name)
 		err := d.requestTask(ctx, taskLLM, plan)
 		if err != nil {
 			return err
@@ -155,8 +154,9 @@ func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan str
 	}
 	
 	llm.LLMClient.GetUserMessage(i18n.GetMessage(*conf.BaseConfInfo.Lang, "loop_task_prompt", taskParam))
-	c, err := llm.LLMClient.SyncSend(ctx, llm)
-	if err != nil {
+	plans = new(TaskInfo)
+	c, err := SyncSendJson(ctx, llm, TaskSchema(), plans)
+	if err != nil && !errors.Is(err, JsonInvalidErr) {
 		logger.Error("ChatCompletionStream error", "err", err)
 		return err
 	}
@@ -168,15 +168,6 @@ func (d *LLMTaskReq) loopTask(ctx context.Context, plans *TaskInfo, lastPlan str
 	
 	d.Token += llm.Token
 	
-	matches := jsonRe.FindAllString(c, -1)
-	plans = new(TaskInfo)
-	for _, match := range matches {
-		err := json.Unmarshal([]byte(match), &plans)
-		if err != nil {
-			logger.Error("json umarshal fail", "err", err)
-		}
-	}
-	
 	llm.LLMClient.GetAssistantMessage(c)
 	
 	if len(plans.Plan) == 0 {
@@ -203,3 +194,68 @@ func (d *LLMTaskReq) requestTask(ctx context.Context, llm *LLM, plan *Task) erro
 	
 	return nil
 }
+
+// requestAgent user defined agent use its own prompt and model, result is merged into task llm
+func (d *LLMTaskReq) requestAgent(ctx context.Context, taskLLM *LLM, agent *conf.AgentInfo, plan *Task) error {
+	agentLLM := NewLLM(WithUserId(d.UserId), WithChatId(d.ChatId), WithMsgId(d.MsgId),
+		WithAgent(agent), WithContent(plan.Description))
+	if agent.Prompt != "" {
+		agentLLM.LLMClient.GetSystemMessage(agent.Prompt)
+	}
+	agentLLM.LLMClient.GetUserMessage(plan.Description)
+	agentLLM.LLMClient.GetModel(agentLLM)
+	
+	c, err := agentLLM.LLMClient.SyncSend(ctx, agentLLM)
+	if err != nil {
+		logger.Error("agent request fail", "agent", agent.Name, "err", err)
+		return err
+	}
+	d.Token += agentLLM.Token
+	
+	if c == "" {
+		c = plan.Name + " is completed"
+	}
+	taskLLM.LLMClient.GetUserMessage(plan.Description)
+	taskLLM.LLMClient.GetAssistantMessage(c)
+	
+	return nil
+}
+
+// GetAgentNames get all agent names which can be chosen by llm
+func GetAgentNames() []string {
+	names := []string{LLMToolName}
+	conf.TaskTools.Range(func(name, value any) bool {
+		names = append(names, name.(string))
+		return true
+	})
+	return names
+}
+
+// TaskSchema json schema of task plan
+func TaskSchema() *JsonSchema {
+	return &JsonSchema{
+		Name: "task_plan",
+		Type: "object",
+		Properties: map[string]*JsonSchema{
+			"plan": {
+				Type: "array",
+				Items: &JsonSchema{
+					Type: "object",
+					Properties: map[string]*JsonSchema{
+						"name": {
+							Type:        "string",
+							Description: "agent name",
+							Enum:        GetAgentNames(),
+						},
+						"description": {
+							Type:        "string",
+							Description: "detailed explanation of how to execute the task",
+						},
+					},
+					Required: []string{"name", "description"},
+				},
+			},
+		},
+		Required: []string{"plan"},
+	}
+}
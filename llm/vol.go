@@ -34,6 +34,10 @@ type VolReq struct {
 }
 
 func (h *VolReq) GetModel(l *LLM) {
+	if l.GetAgentModel() {
+		return
+	}
+	
 	l.Model = param.ModelDeepSeekR1_528
 	userInfo, err := db.GetUserByID(l.UserId)
 	if err != nil {
@@ -126,7 +130,7 @@ func (h *VolReq) Send(ctx context.Context, l *LLM) error {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.VolTools,
 	}
 	
@@ -277,6 +281,18 @@ func (h *VolReq) GetAssistantMessage(msg string) {
 	h.GetMessage(constants.ChatMessageRoleAssistant, msg)
 }
 
+// GetSystemMessage put system prompt at the beginning of messages
+func (h *VolReq) GetSystemMessage(msg string) {
+	h.VolMsgs = append([]*model.ChatCompletionMessage{
+		{
+			Role: constants.ChatMessageRoleSystem,
+			Content: &model.ChatCompletionMessageContent{
+				StringValue: &msg,
+			},
+		},
+	}, h.VolMsgs...)
+}
+
 func (h *VolReq) AppendMessages(client LLMClient) {
 	if len(h.VolMsgs) == 0 {
 		h.VolMsgs = make([]*model.ChatCompletionMessage, 0)
@@ -330,10 +346,16 @@ func (h *VolReq) SyncSend(ctx context.Context, l *LLM) (string, error) {
 		LogProbs:         *conf.LLMConfInfo.LogProbs,
 		Stop:             conf.LLMConfInfo.Stop,
 		PresencePenalty:  float32(*conf.LLMConfInfo.PresencePenalty),
-		Temperature:      float32(*conf.LLMConfInfo.Temperature),
+		Temperature:      float32(l.GetTemperature()),
 		Tools:            l.VolTools,
 	}
 	
+	if l.ResponseSchema != nil {
+		req.ResponseFormat = &model.ResponseFormat{
+			Type: model.ResponseFormatJsonObject,
+		}
+	}
+	
 	response, err := client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		logger.Error("CreateChatCompletion error", "updateMsgID", l.MsgId, "err", err)
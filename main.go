@@ -3,10 +3,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	
+	"github.com/yincongcyincong/MuseBot/backup"
 	"github.com/yincongcyincong/MuseBot/conf"
 	"github.com/yincongcyincong/MuseBot/db"
 	"github.com/yincongcyincong/MuseBot/http"
@@ -15,16 +19,35 @@ import (
 	"github.com/yincongcyincong/MuseBot/metrics"
 	"github.com/yincongcyincong/MuseBot/rag"
 	"github.com/yincongcyincong/MuseBot/robot"
+	"github.com/yincongcyincong/MuseBot/state"
 )
 
 func main() {
 	logger.InitLogger()
 	conf.InitConf()
 	i18n.InitI18n()
+	state.InitState()
 	db.InitTable()
+	if *conf.BaseConfInfo.Migrate != "" {
+		runMigrate()
+	}
+	if *conf.RecordConfInfo.RotateRecordKey {
+		runRotateRecordKey()
+	}
+	if *conf.BackupConfInfo.Backup != "" {
+		runBackup()
+	}
+	if *conf.BackupConfInfo.Restore != "" {
+		runRestore()
+	}
+	db.StartRecordWriter()
 	db.UpdateUserTime()
+	db.StartRetention()
 	conf.InitTools()
 	rag.InitRag()
+	if *conf.RagConfInfo.RagEvalFile != "" {
+		runRagEval()
+	}
 	http.InitHTTP()
 	metrics.RegisterMetrics()
 	robot.StartRobot()
@@ -32,4 +55,73 @@ func main() {
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
+	
+	db.StopRecordWriter()
+}
+
+// runRagEval evaluate knowledge base with golden set and exit
+func runRagEval() {
+	err := rag.RunEval(os.Stdout)
+	if err != nil {
+		logger.Error("rag eval fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runMigrate print or apply db migrations and exit
+func runMigrate() {
+	err := db.Migrate(os.Stdout, *conf.BaseConfInfo.Migrate)
+	if err != nil {
+		logger.Error("migrate db fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runRotateRecordKey re-encrypt records by current key and exit
+func runRotateRecordKey() {
+	_, err := db.RotateRecordKeys(os.Stdout)
+	if err != nil {
+		logger.Error("rotate record key fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runBackup write backup archive and exit
+func runBackup() {
+	_, err := backup.Backup(*conf.BackupConfInfo.Backup, os.Stdout)
+	if err != nil {
+		logger.Error("backup fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runRestore restore backup archive, embed knowledge files into current vector store if needed and exit
+func runRestore() {
+	_, err := backup.Restore(*conf.BackupConfInfo.Restore, *conf.BackupConfInfo.RestoreReembed, os.Stdout)
+	if err == nil && *conf.BackupConfInfo.RestoreReembed {
+		err = reembedKnowledge()
+	}
+	if err != nil {
+		logger.Error("restore fail", "err", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func reembedKnowledge() error {
+	rag.InitRag()
+	if conf.RagConfInfo.Store == nil {
+		return errors.New("vector store is not configured, knowledge files can't be embedded")
+	}
+	
+	result, err := rag.ReconcileKnowledgeBase(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "knowledge files embedded: %d files, %d chunks\n", result.Added+result.Changed+result.Unchanged, result.Chunks)
+	return nil
 }
@@ -35,6 +35,56 @@ var (
 		},
 	)
 	
+	PurgedRecords = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_retention_purged_records",
+			Help: "Total number of soft deleted records purged by retention job.",
+		},
+	)
+	
+	ArchivedRecords = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_retention_archived_records",
+			Help: "Total number of records archived by retention job.",
+		},
+	)
+	
+	RetentionErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_retention_errors",
+			Help: "Total number of failed retention jobs.",
+		},
+	)
+	
+	RecordQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_record_queue_depth",
+			Help: "Number of records waiting in write queue.",
+		},
+	)
+	
+	RecordQueueFull = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_record_queue_full",
+			Help: "Total number of records which wait because write queue is full.",
+		},
+	)
+	
+	RecordWriteLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "app_record_write_latency_seconds",
+			Help:    "Duration from record enqueued to committed in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	
+	RecordWriteErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_record_write_errors",
+			Help: "Total number of records failed to write.",
+		},
+	)
+	
 	ImageDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "generate_image_duration_seconds",
@@ -51,4 +101,11 @@ func RegisterMetrics() {
 	prometheus.MustRegister(TotalTokens)
 	prometheus.MustRegister(ConversationDuration)
 	prometheus.MustRegister(ImageDuration)
+	prometheus.MustRegister(PurgedRecords)
+	prometheus.MustRegister(ArchivedRecords)
+	prometheus.MustRegister(RetentionErrors)
+	prometheus.MustRegister(RecordQueueDepth)
+	prometheus.MustRegister(RecordQueueFull)
+	prometheus.MustRegister(RecordWriteLatency)
+	prometheus.MustRegister(RecordWriteErrors)
 }
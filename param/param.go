@@ -68,6 +68,12 @@ const (
 	ImageRecordType = 1
 	VideoRecordType = 2
 	WEBRecordType   = 3
+	
+	TelegramPlatform = "telegram"
+	DiscordPlatform  = "discord"
+	SlackPlatform    = "slack"
+	LarkPlatform     = "lark"
+	WebPlatform      = "web"
 )
 
 var (
@@ -82,6 +88,16 @@ var (
 		deepseek.OpenRouterDeepSeekR1DistillQwen32B:  true,
 	}
 	
+	// LLMTypes llm types which have client
+	LLMTypes = map[string]bool{
+		DeepSeek:      true,
+		DeepSeekLlava: true, // ollama
+		Gemini:        true,
+		OpenAi:        true,
+		OpenRouter:    true,
+		Vol:           true,
+	}
+	
 	GeminiModels = map[string]bool{
 		ModelGemini25Pro:       true,
 		ModelGemini25Flash:     true,
@@ -0,0 +1,376 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+const (
+	crawlUserAgent  = "MuseBot"
+	maxWebPageSize  = 5 * 1024 * 1024
+	maxRobotsTxtLen = 512 * 1024
+)
+
+var (
+	InvalidURLErr      = errors.New("invalid url, only http and https are supported")
+	RobotsDisallowErr  = errors.New("url is disallowed by robots.txt")
+	PageContentTypeErr = errors.New("content type of url is not html or text")
+	PrivateURLErr      = errors.New("url of loopback, private or link local address is not allowed")
+	
+	// publicIP check address can be crawled, internal addresses are forbidden to avoid ssrf
+	publicIP = func(ip net.IP) bool {
+		return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+	}
+	
+	// crawlInterval wait between two requests to same site
+	crawlInterval = 500 * time.Millisecond
+	
+	// webSkipExts links of these files are not followed
+	webSkipExts = regexp.MustCompile(`(?i)\.(png|jpe?g|gif|webp|svg|ico|css|js|pdf|zip|gz|tar|rar|7z|mp3|mp4|avi|mov|woff2?|ttf|exe|dmg|apk)$`)
+)
+
+// CrawlResult summary of web pages learned by one url
+type CrawlResult struct {
+	Pages   int `json:"pages"`
+	Chunks  int `json:"chunks"`
+	Skipped int `json:"skipped"`
+}
+
+type crawlItem struct {
+	url   *url.URL
+	depth int
+}
+
+type crawler struct {
+	client *http.Client
+	robots map[string]*robotsRules
+}
+
+// LearnURL fetch web page and same domain links up to depth, save readable text of pages into namespace
+// and embed them, owner is the user who sends url. Learning same url again refreshes its pages.
+func LearnURL(ctx context.Context, namespace, owner, rawURL string, depth int) (*CrawlResult, error) {
+	startURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (startURL.Scheme != "http" && startURL.Scheme != "https") || startURL.Host == "" {
+		return nil, InvalidURLErr
+	}
+	startURL.Fragment = ""
+	if err = checkURLHost(ctx, startURL); err != nil {
+		return nil, err
+	}
+	
+	if depth > *conf.RagConfInfo.CrawlMaxDepth {
+		depth = *conf.RagConfInfo.CrawlMaxDepth
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	maxPages := *conf.RagConfInfo.CrawlMaxPages
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+	
+	c := &crawler{
+		client: newCrawlClient(),
+		robots: make(map[string]*robotsRules),
+	}
+	
+	res := new(CrawlResult)
+	queue := []*crawlItem{{url: startURL}}
+	visited := map[string]bool{startURL.String(): true}
+	for len(queue) > 0 && res.Pages < maxPages {
+		if ctx.Err() != nil {
+			break
+		}
+		
+		item := queue[0]
+		queue = queue[1:]
+		if item.url != startURL && crawlInterval > 0 {
+			time.Sleep(crawlInterval)
+		}
+		
+		page, err := c.fetchPage(ctx, item.url)
+		if err != nil {
+			if item.url == startURL {
+				return nil, err
+			}
+			logger.Warn("crawl page fail", "url", item.url.String(), "err", err)
+			res.Skipped++
+			continue
+		}
+		
+		if page.Text != "" {
+			filePath := getWebPagePath(namespace, item.url)
+			_, chunks, err := saveKnowledgeFile(ctx, filePath, []byte(page.Content()))
+			if err != nil {
+				return res, err
+			}
+			if err = db.UpdateOwnerByFileName(filePath, owner); err != nil {
+				logger.Error("update rag file owner fail", "file", filePath, "err", err)
+			}
+			res.Pages++
+			res.Chunks += chunks
+		}
+		
+		if item.depth >= depth {
+			continue
+		}
+		for _, link := range page.Links {
+			if link.Host != startURL.Host || webSkipExts.MatchString(link.Path) || visited[link.String()] {
+				continue
+			}
+			visited[link.String()] = true
+			queue = append(queue, &crawlItem{url: link, depth: item.depth + 1})
+		}
+	}
+	
+	if res.Pages == 0 {
+		return res, FileContentEmptyErr
+	}
+	
+	logger.Info("learn url", "owner", owner, "url", startURL.String(), "pages", res.Pages,
+		"chunks", res.Chunks, "skipped", res.Skipped)
+	return res, nil
+}
+
+// newCrawlClient get client which refuses to connect internal addresses, redirects are checked too
+func newCrawlClient() *http.Client {
+	client := utils.GetLLMProxyClient()
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.Proxy == nil {
+		// check address really connected, host may resolve to another address after checking
+		dialer := &net.Dialer{Timeout: 30 * time.Second, Control: checkDialAddr}
+		transport.DialContext = dialer.DialContext
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return InvalidURLErr
+		}
+		return checkURLHost(req.Context(), req.URL)
+	}
+	return client
+}
+
+// checkURLHost resolve host of url, all addresses of host must be public
+func checkURLHost(ctx context.Context, u *url.URL) error {
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if !publicIP(ip) {
+			return PrivateURLErr
+		}
+		return nil
+	}
+	
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return PrivateURLErr
+		}
+	}
+	return nil
+}
+
+// checkDialAddr check address before connecting
+func checkDialAddr(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return PrivateURLErr
+	}
+	return nil
+}
+
+// fetchPage check robots.txt and get readable content of html or text page
+func (c *crawler) fetchPage(ctx context.Context, pageURL *url.URL) (*webPage, error) {
+	if !c.allowed(ctx, pageURL) {
+		return nil, RobotsDisallowErr
+	}
+	
+	resp, err := c.get(ctx, pageURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s fail: %s", pageURL.String(), resp.Status)
+	}
+	
+	body := io.LimitReader(resp.Body, maxWebPageSize)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return parseWebPage(pageURL, body)
+	case strings.HasPrefix(mediaType, "text/"):
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return &webPage{URL: pageURL.String(), Text: strings.TrimSpace(string(data))}, nil
+	default:
+		return nil, PageContentTypeErr
+	}
+}
+
+// allowed check robots.txt of site, site without robots.txt allows everything
+func (c *crawler) allowed(ctx context.Context, pageURL *url.URL) bool {
+	site := pageURL.Scheme + "://" + pageURL.Host
+	rules, ok := c.robots[site]
+	if !ok {
+		rules = new(robotsRules)
+		resp, err := c.get(ctx, site+"/robots.txt")
+		if err == nil {
+			if resp.StatusCode == http.StatusOK {
+				rules = parseRobots(io.LimitReader(resp.Body, maxRobotsTxtLen), crawlUserAgent)
+			} else if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				rules = &robotsRules{disallowAll: true}
+			}
+			resp.Body.Close()
+		}
+		c.robots[site] = rules
+	}
+	
+	return rules.allowed(pageURL.RequestURI())
+}
+
+func (c *crawler) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", crawlUserAgent)
+	return c.client.Do(req)
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern *regexp.Regexp
+	length  int
+}
+
+// robotsRules rules of robots.txt group which matches user agent
+type robotsRules struct {
+	rules       []*robotsRule
+	disallowAll bool
+}
+
+// parseRobots parse robots.txt, rules of group which names user agent are used,
+// otherwise rules of group "*" are used.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	userAgent = strings.ToLower(userAgent)
+	agentRules := make([]*robotsRule, 0)
+	defaultRules := make([]*robotsRule, 0)
+	matchAgent, matchDefault, inAgents := false, false, false
+	foundAgent := false
+	
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		
+		switch key {
+		case "user-agent":
+			// a new group starts after rules
+			if !inAgents {
+				matchAgent, matchDefault = false, false
+				inAgents = true
+			}
+			agent := strings.ToLower(value)
+			if agent == "*" {
+				matchDefault = true
+			} else if agent != "" && strings.Contains(userAgent, agent) {
+				matchAgent = true
+				foundAgent = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue
+			}
+			rule := &robotsRule{
+				allow:   key == "allow",
+				pattern: robotsPattern(value),
+				length:  len(value),
+			}
+			if matchAgent {
+				agentRules = append(agentRules, rule)
+			}
+			if matchDefault {
+				defaultRules = append(defaultRules, rule)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	
+	if foundAgent {
+		return &robotsRules{rules: agentRules}
+	}
+	return &robotsRules{rules: defaultRules}
+}
+
+// robotsPattern convert robots.txt path to regexp, * matches any characters and $ matches end
+func robotsPattern(value string) *regexp.Regexp {
+	end := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if end {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed the longest matched rule wins, allow wins when length is same
+func (r *robotsRules) allowed(urlPath string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if urlPath == "" {
+		urlPath = "/"
+	}
+	
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(urlPath) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
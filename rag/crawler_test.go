@@ -0,0 +1,171 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestParseWebPage(t *testing.T) {
+	pageURL, _ := url.Parse("https://docs.example.com/guide/")
+	page, err := parseWebPage(pageURL, strings.NewReader(`<html><head><title> Guide </title><style>p{}</style></head>
+<body><nav><a href="/install">Install</a></nav>
+<main><h1>Install</h1><p>run   <b>make</b> first</p><ul><li>apple</li><li>car</li></ul>
+<pre>go build
+go test</pre><script>alert(1)</script><a href="../faq#top">FAQ</a><a href="mailto:a@b.c">mail</a></main>
+<footer>copyright</footer></body></html>`))
+	assert.Nil(t, err)
+	assert.Equal(t, "Guide", page.Title)
+	assert.Equal(t, "# Install\n\nrun make first\n\n- apple\n- car\n\n```\ngo build\ngo test\n```\n\nFAQ mail", page.Text)
+	assert.Len(t, page.Links, 2)
+	assert.Equal(t, "https://docs.example.com/install", page.Links[0].String())
+	assert.Equal(t, "https://docs.example.com/faq", page.Links[1].String())
+	assert.Equal(t, "# Guide\nSource: https://docs.example.com/guide/\n\n"+page.Text, page.Content())
+}
+
+func TestGetWebPagePath(t *testing.T) {
+	u, _ := url.Parse("https://docs.example.com:8080/a/b.html")
+	assert.Equal(t, "web/docs.example.com_8080/a_b.html.md", getWebPagePath("", u))
+	u, _ = url.Parse("https://docs.example.com")
+	assert.Equal(t, "namespaces/team_a/web/docs.example.com/index.md", getWebPagePath("team_a", u))
+	u, _ = url.Parse("https://docs.example.com/search?q=1")
+	assert.True(t, strings.HasPrefix(getWebPagePath("", u), "web/docs.example.com/search_"))
+	
+	assert.True(t, isWebPageFile("web/docs.example.com/index.md"))
+	assert.True(t, isWebPageFile("namespaces/team_a/web/docs.example.com/index.md"))
+	assert.False(t, isWebPageFile("web.md"))
+	assert.False(t, isWebPageFile("namespaces/team_a/web.md"))
+}
+
+func TestParseRobots(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: *
+Disallow: /private
+Allow: /private/public
+
+User-agent: OtherBot
+Disallow: /
+`), crawlUserAgent)
+	assert.True(t, rules.allowed("/"))
+	assert.False(t, rules.allowed("/private/a"))
+	assert.True(t, rules.allowed("/private/public/a"))
+	
+	rules = parseRobots(strings.NewReader(`
+User-agent: Google
+User-agent: MuseBot
+Disallow: /*.json$
+`), crawlUserAgent)
+	assert.False(t, rules.allowed("/data/a.json"))
+	assert.True(t, rules.allowed("/data/a.json?x=1"))
+	assert.True(t, rules.allowed("/private"))
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888"} {
+		assert.True(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0",
+		"::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, publicIP(net.ParseIP(ip)), ip)
+	}
+	
+	ctx := context.Background()
+	for _, rawURL := range []string{"http://127.0.0.1/", "http://[::1]:8080/", "http://169.254.169.254/latest/meta-data"} {
+		u, err := url.Parse(rawURL)
+		assert.Nil(t, err)
+		assert.ErrorIs(t, checkURLHost(ctx, u), PrivateURLErr, rawURL)
+	}
+}
+
+func TestLearnURL(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	proxy, maxDepth, maxPages := "", 2, 10
+	conf.BaseConfInfo.LLMProxy = &proxy
+	conf.RagConfInfo.CrawlMaxDepth = &maxDepth
+	conf.RagConfInfo.CrawlMaxPages = &maxPages
+	crawlInterval = 0
+	
+	// test server listens on 127.0.0.1, other internal addresses are still forbidden
+	oldPublicIP := publicIP
+	publicIP = func(ip net.IP) bool {
+		return ip.Equal(net.IPv4(127, 0, 0, 1))
+	}
+	defer func() {
+		publicIP = oldPublicIP
+	}()
+	
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><title>Home</title><body><p>apple home</p>
+<a href="/a">a</a><a href="/private/x">x</a><a href="https://other.example.com/">o</a><a href="/logo.png">l</a></body></html>`)
+		case "/a":
+			fmt.Fprint(w, `<html><body><p>car page</p><a href="/b">b</a><a href="/">home</a></body></html>`)
+		case "/b":
+			fmt.Fprint(w, `<html><body><p>dog page</p></body></html>`)
+		case "/redirect":
+			http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "127.0.0.2", 1)+"/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+	
+	host := CleanNamespace(strings.TrimPrefix(server.URL, "http://"))
+	res, err := LearnURL(ctx, "-1001", "42", server.URL+"/", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, &CrawlResult{Pages: 2, Chunks: 2, Skipped: 1}, res)
+	
+	filePath := "namespaces/-1001/web/" + host + "/index.md"
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(filePath)))
+	assert.Nil(t, err)
+	assert.Equal(t, "# Home\nSource: "+server.URL+"/\n\napple home\n\na x o l", string(content))
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "42", ragFiles[0].Owner)
+	
+	docs, err := NewRetriever(conf.RagConfInfo.Store, 1, "-1001").GetRelevantDocuments(ctx, "car page")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, server.URL+"/a", docs[0].Metadata["source_url"])
+	
+	// learn again refreshes pages, depth is limited by crawl_max_depth
+	res, err = LearnURL(ctx, "-1001", "42", server.URL+"/", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, res.Pages)
+	assert.Equal(t, 3, conf.RagConfInfo.Store.(*LocalStore).Count())
+	
+	_, err = LearnURL(ctx, "-1001", "42", server.URL+"/private/x", 0)
+	assert.ErrorIs(t, err, RobotsDisallowErr)
+	
+	_, err = LearnURL(ctx, "-1001", "42", "ftp://example.com", 0)
+	assert.ErrorIs(t, err, InvalidURLErr)
+	
+	_, err = LearnURL(ctx, "-1001", "42", "http://10.0.0.1/", 0)
+	assert.ErrorIs(t, err, PrivateURLErr)
+	
+	// redirect to internal address is refused
+	_, err = LearnURL(ctx, "-1001", "42", server.URL+"/redirect", 0)
+	assert.ErrorIs(t, err, PrivateURLErr)
+}
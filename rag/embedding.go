@@ -0,0 +1,224 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/embeddings"
+)
+
+const (
+	defaultOllamaURL            = "http://localhost:11434"
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+	defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+)
+
+var (
+	EmbeddingDimensionErr = errors.New("embedding dimension not match")
+)
+
+type ollamaEmbeddingReq struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type ollamaEmbeddingResp struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error"`
+}
+
+type openAIEmbeddingReq struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbeddingResp struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// HTTPEmbedderClient request ollama /api/embed or openai compatible /embeddings api
+type HTTPEmbedderClient struct {
+	Type      string
+	BaseURL   string
+	Token     string
+	Model     string
+	Dimension int
+	Retry     int
+	
+	Client *http.Client
+}
+
+// CreateEmbedding implement embeddings.EmbedderClient, retry when request fail
+func (h *HTTPEmbedderClient) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	var res [][]float32
+	var err error
+	for i := 0; i <= h.Retry; i++ {
+		if i > 0 {
+			logger.Warn("embedding request fail, retry", "retry", i, "err", err)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(i) * time.Second):
+			}
+		}
+		
+		if h.Type == "ollama" {
+			res, err = h.createOllamaEmbedding(ctx, texts)
+		} else {
+			res, err = h.createOpenAIEmbedding(ctx, texts)
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	if len(res) != len(texts) {
+		return nil, EmbeddingNumberErr
+	}
+	for _, v := range res {
+		if h.Dimension > 0 && len(v) != h.Dimension {
+			return nil, fmt.Errorf("%w: expect %d, got %d", EmbeddingDimensionErr, h.Dimension, len(v))
+		}
+	}
+	
+	return res, nil
+}
+
+func (h *HTTPEmbedderClient) createOllamaEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	resp := new(ollamaEmbeddingResp)
+	err := h.post(ctx, "/api/embed", &ollamaEmbeddingReq{
+		Model:      h.Model,
+		Input:      texts,
+		Dimensions: h.Dimension,
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	
+	return resp.Embeddings, nil
+}
+
+func (h *HTTPEmbedderClient) createOpenAIEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	resp := new(openAIEmbeddingResp)
+	err := h.post(ctx, "/embeddings", &openAIEmbeddingReq{
+		Model:      h.Model,
+		Input:      texts,
+		Dimensions: h.Dimension,
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, errors.New(resp.Error.Message)
+	}
+	
+	// data may be out of order, sort by index
+	res := make([][]float32, len(resp.Data))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(res) {
+			return nil, fmt.Errorf("embedding index out of range: %d", data.Index)
+		}
+		res[data.Index] = data.Embedding
+	}
+	
+	return res, nil
+}
+
+func (h *HTTPEmbedderClient) post(ctx context.Context, path string, body interface{}, resp interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(h.BaseURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	
+	httpResp, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embedding request fail, status: %d, body: %s", httpResp.StatusCode, string(respBody))
+	}
+	
+	return json.Unmarshal(respBody, resp)
+}
+
+func initOllamaEmbedding() (embeddings.Embedder, error) {
+	client := newHTTPEmbedderClient("ollama", defaultOllamaURL, defaultOllamaEmbeddingModel)
+	return embeddings.NewEmbedder(client, embeddings.WithBatchSize(getEmbeddingBatchSize()))
+}
+
+func initOpenAICompatibleEmbedding() (embeddings.Embedder, error) {
+	if *conf.RagConfInfo.EmbeddingBaseURL == "" {
+		return nil, errors.New("embedding_base_url is empty")
+	}
+	
+	client := newHTTPEmbedderClient("openai_compatible", "", defaultOpenAIEmbeddingModel)
+	return embeddings.NewEmbedder(client, embeddings.WithBatchSize(getEmbeddingBatchSize()))
+}
+
+func newHTTPEmbedderClient(embeddingType, defaultURL, defaultModel string) *HTTPEmbedderClient {
+	client := &HTTPEmbedderClient{
+		Type:      embeddingType,
+		BaseURL:   *conf.RagConfInfo.EmbeddingBaseURL,
+		Token:     *conf.RagConfInfo.EmbeddingToken,
+		Model:     *conf.RagConfInfo.EmbeddingModel,
+		Dimension: *conf.RagConfInfo.EmbeddingDimension,
+		Retry:     *conf.RagConfInfo.EmbeddingRetry,
+		Client: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+	
+	if client.BaseURL == "" {
+		client.BaseURL = defaultURL
+	}
+	if client.Model == "" {
+		client.Model = defaultModel
+	}
+	
+	return client
+}
+
+func getEmbeddingBatchSize() int {
+	if *conf.RagConfInfo.EmbeddingBatchSize <= 0 {
+		return 1
+	}
+	return *conf.RagConfInfo.EmbeddingBatchSize
+}
@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPEmbedderClient_Ollama(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		
+		req := new(ollamaEmbeddingReq)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		resp := new(ollamaEmbeddingResp)
+		for range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float32{1, 2, 3})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	
+	client := &HTTPEmbedderClient{
+		Type:      "ollama",
+		BaseURL:   server.URL,
+		Model:     "nomic-embed-text",
+		Dimension: 3,
+		Retry:     1,
+		Client:    server.Client(),
+	}
+	res, err := client.CreateEmbedding(context.Background(), []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float32{{1, 2, 3}, {1, 2, 3}}, res)
+	assert.Equal(t, 2, requests)
+	
+	client.Dimension = 4
+	_, err = client.CreateEmbedding(context.Background(), []string{"a"})
+	assert.ErrorIs(t, err, EmbeddingDimensionErr)
+}
+
+func TestHTTPEmbedderClient_OpenAICompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		
+		req := new(openAIEmbeddingReq)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		assert.Equal(t, 2, req.Dimensions)
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer server.Close()
+	
+	client := &HTTPEmbedderClient{
+		Type:      "openai_compatible",
+		BaseURL:   server.URL + "/v1/",
+		Token:     "token",
+		Model:     "bge-m3",
+		Dimension: 2,
+		Client:    server.Client(),
+	}
+	res, err := client.CreateEmbedding(context.Background(), []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, res)
+}
@@ -0,0 +1,305 @@
+package rag
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/chains"
+	"github.com/yincongcyincong/langchaingo/embeddings"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	// evalUserId user id of llm requests sent by evaluation
+	evalUserId = "rag_eval"
+)
+
+var (
+	EvalCaseEmptyErr = errors.New("golden set has no question")
+)
+
+// EvalCase one line of golden set, sources are file paths, file names or urls of expected documents
+type EvalCase struct {
+	Question  string   `json:"question"`
+	Namespace string   `json:"namespace"`
+	Sources   []string `json:"sources"`
+	Answer    string   `json:"answer"`
+}
+
+// EvalCaseResult metrics of one question
+type EvalCaseResult struct {
+	Question         string   `json:"question"`
+	Retrieved        []string `json:"retrieved"`
+	Recall           float64  `json:"recall"`
+	ReciprocalRank   float64  `json:"reciprocal_rank"`
+	Answer           string   `json:"answer,omitempty"`
+	AnswerSimilarity float64  `json:"answer_similarity"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// EvalReport average metrics of golden set, recall and mrr only count questions with expected sources,
+// answer similarity only counts generated answers with expected answer
+type EvalReport struct {
+	Cases            int               `json:"cases"`
+	K                int               `json:"k"`
+	RecallAtK        float64           `json:"recall_at_k"`
+	MRR              float64           `json:"mrr"`
+	SourceCases      int               `json:"source_cases"`
+	AnswerSimilarity float64           `json:"answer_similarity"`
+	AnswerCases      int               `json:"answer_cases"`
+	Results          []*EvalCaseResult `json:"results"`
+}
+
+// Evaluator run golden set against current vector store
+type Evaluator struct {
+	K int
+	
+	// NewRetriever create retriever of namespace, retrieval conf is used if nil
+	NewRetriever func(namespace string) *Retriever
+	// Generate generate answer by retrieved documents, answers are not generated if nil
+	Generate func(ctx context.Context, question string, docs []schema.Document) (string, error)
+	// Embedder embed answers to compare them, answer similarity is not computed if nil
+	Embedder embeddings.Embedder
+}
+
+// ReadEvalCases read golden set from jsonl file, empty lines are skipped
+func ReadEvalCases(r io.Reader) ([]*EvalCase, error) {
+	cases := make([]*EvalCase, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		
+		evalCase := new(EvalCase)
+		err := json.Unmarshal([]byte(text), evalCase)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.TrimSpace(evalCase.Question) == "" {
+			return nil, fmt.Errorf("line %d: %w", line, EvalCaseEmptyErr)
+		}
+		cases = append(cases, evalCase)
+	}
+	
+	return cases, scanner.Err()
+}
+
+// Evaluate retrieve documents of every question, generate answer if generator is set
+func (e *Evaluator) Evaluate(ctx context.Context, cases []*EvalCase) *EvalReport {
+	report := &EvalReport{
+		Cases:   len(cases),
+		K:       e.K,
+		Results: make([]*EvalCaseResult, 0, len(cases)),
+	}
+	
+	for _, evalCase := range cases {
+		res := e.evaluateCase(ctx, evalCase)
+		report.Results = append(report.Results, res)
+		
+		if len(evalCase.Sources) > 0 {
+			report.SourceCases++
+			report.RecallAtK += res.Recall
+			report.MRR += res.ReciprocalRank
+		}
+		if e.Generate != nil && e.Embedder != nil && evalCase.Answer != "" && res.Error == "" {
+			report.AnswerCases++
+			report.AnswerSimilarity += res.AnswerSimilarity
+		}
+	}
+	
+	if report.SourceCases > 0 {
+		report.RecallAtK /= float64(report.SourceCases)
+		report.MRR /= float64(report.SourceCases)
+	}
+	if report.AnswerCases > 0 {
+		report.AnswerSimilarity /= float64(report.AnswerCases)
+	}
+	return report
+}
+
+func (e *Evaluator) evaluateCase(ctx context.Context, evalCase *EvalCase) *EvalCaseResult {
+	res := &EvalCaseResult{
+		Question:  evalCase.Question,
+		Retrieved: make([]string, 0),
+	}
+	
+	var retriever *Retriever
+	if e.NewRetriever != nil {
+		retriever = e.NewRetriever(evalCase.Namespace)
+	} else {
+		retriever = NewConfRetriever(evalCase.Namespace, llm.WithUserId(evalUserId))
+	}
+	if e.K > 0 {
+		retriever.NumDocuments = e.K
+	}
+	
+	docs, err := retriever.GetRelevantDocuments(ctx, evalCase.Question)
+	if err != nil {
+		logger.Error("eval retrieve documents fail", "question", evalCase.Question, "err", err)
+		res.Error = err.Error()
+		return res
+	}
+	
+	for _, source := range GetSources(docs) {
+		if source.URL != "" {
+			res.Retrieved = append(res.Retrieved, source.URL)
+		} else {
+			res.Retrieved = append(res.Retrieved, source.FilePath)
+		}
+	}
+	res.Recall, res.ReciprocalRank = retrievalMetrics(docs, evalCase.Sources)
+	
+	if e.Generate == nil {
+		return res
+	}
+	res.Answer, err = e.Generate(ctx, evalCase.Question, docs)
+	if err != nil {
+		logger.Error("eval generate answer fail", "question", evalCase.Question, "err", err)
+		res.Error = err.Error()
+		return res
+	}
+	if e.Embedder != nil && evalCase.Answer != "" {
+		res.AnswerSimilarity, err = answerSimilarity(ctx, e.Embedder, res.Answer, evalCase.Answer)
+		if err != nil {
+			logger.Error("eval embed answer fail", "question", evalCase.Question, "err", err)
+			res.Error = err.Error()
+		}
+	}
+	return res
+}
+
+// retrievalMetrics get recall of expected sources in documents and reciprocal rank of first relevant document
+func retrievalMetrics(docs []schema.Document, sources []string) (float64, float64) {
+	if len(sources) == 0 {
+		return 0, 0
+	}
+	
+	found := make(map[int]bool)
+	reciprocalRank := 0.0
+	for i, doc := range docs {
+		for j, source := range sources {
+			if !matchSource(doc, source) {
+				continue
+			}
+			if reciprocalRank == 0 {
+				reciprocalRank = 1 / float64(i+1)
+			}
+			found[j] = true
+		}
+	}
+	
+	return float64(len(found)) / float64(len(sources)), reciprocalRank
+}
+
+// matchSource check whether document comes from expected source
+func matchSource(doc schema.Document, source string) bool {
+	source = strings.TrimSpace(strings.ReplaceAll(source, "\\", "/"))
+	if source == "" {
+		return false
+	}
+	
+	filePath := metadataString(doc.Metadata["file_path"])
+	fileName := metadataString(doc.Metadata["file_name"])
+	sourceURL := metadataString(doc.Metadata["source_url"])
+	return (filePath != "" && (filePath == source || path.Base(filePath) == source)) ||
+		(fileName != "" && fileName == source) ||
+		(sourceURL != "" && strings.TrimSuffix(sourceURL, "/") == strings.TrimSuffix(source, "/"))
+}
+
+// answerSimilarity cosine similarity of embeddings of generated answer and expected answer
+func answerSimilarity(ctx context.Context, embedder embeddings.Embedder, answer, expected string) (float64, error) {
+	if strings.TrimSpace(answer) == "" {
+		return 0, nil
+	}
+	
+	vectors, err := embedder.EmbedDocuments(ctx, []string{answer, expected})
+	if err != nil {
+		return 0, err
+	}
+	if len(vectors) != 2 {
+		return 0, fmt.Errorf("expect 2 embeddings, got %d", len(vectors))
+	}
+	
+	return float64(cosineSimilarity(vectors[0], vectorNorm(vectors[0]), vectors[1], vectorNorm(vectors[1]))), nil
+}
+
+// GenerateAnswer generate answer of question by documents with configured llm, like rag answer of robot
+func GenerateAnswer(ctx context.Context, question string, docs []schema.Document) (string, error) {
+	msgChan := make(chan string)
+	go func() {
+		for range msgChan {
+		}
+	}()
+	defer close(msgChan)
+	
+	dpLLM := NewRag(
+		llm.WithHTTPMsgChan(msgChan),
+		llm.WithContent(question),
+		llm.WithUserId(evalUserId),
+	)
+	dpLLM.Retriever = &Retriever{Docs: docs}
+	
+	res, err := chains.Call(ctx, chains.LoadStuffQA(dpLLM), map[string]any{
+		"input_documents": docs,
+		"question":        question,
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	answer, _ := res["text"].(string)
+	return answer, nil
+}
+
+// RunEval evaluate golden set of rag_eval_file with current store and conf, print report as json
+func RunEval(w io.Writer) error {
+	if conf.RagConfInfo.Store == nil {
+		return errors.New("rag is not enabled")
+	}
+	
+	f, err := os.Open(*conf.RagConfInfo.RagEvalFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	cases, err := ReadEvalCases(f)
+	if err != nil {
+		return err
+	}
+	
+	evaluator := &Evaluator{
+		K:        *conf.RagConfInfo.RagEvalK,
+		Embedder: conf.RagConfInfo.Embedder,
+	}
+	if *conf.RagConfInfo.RagEvalGenerate {
+		evaluator.Generate = GenerateAnswer
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(len(cases)+1)*5*time.Minute)
+	defer cancel()
+	
+	report := evaluator.Evaluate(ctx, cases)
+	logger.Info("rag eval finish", "cases", report.Cases, "recall_at_k", report.RecallAtK,
+		"mrr", report.MRR, "answer_similarity", report.AnswerSimilarity)
+		
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
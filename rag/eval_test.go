@@ -0,0 +1,92 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestReadEvalCases(t *testing.T) {
+	cases, err := ReadEvalCases(strings.NewReader(`{"question": "apple?", "sources": ["a.txt"], "answer": "apple"}
+
+{"question": "car?", "namespace": "team"}`))
+	assert.Nil(t, err)
+	assert.Len(t, cases, 2)
+	assert.Equal(t, []string{"a.txt"}, cases[0].Sources)
+	assert.Equal(t, "team", cases[1].Namespace)
+	
+	_, err = ReadEvalCases(strings.NewReader(`{"sources": ["a.txt"]}`))
+	assert.ErrorIs(t, err, EvalCaseEmptyErr)
+	
+	_, err = ReadEvalCases(strings.NewReader(`{"question": `))
+	assert.NotNil(t, err)
+}
+
+func TestRetrievalMetrics(t *testing.T) {
+	docs := []schema.Document{
+		{Metadata: map[string]any{"file_path": "b.txt"}},
+		{Metadata: map[string]any{"file_path": "namespaces/team/a.txt"}},
+		{Metadata: map[string]any{"source_url": "https://example.com/doc/"}},
+	}
+	
+	recall, rr := retrievalMetrics(docs, []string{"a.txt", "https://example.com/doc", "c.txt"})
+	assert.InDelta(t, 2.0/3, recall, 1e-6)
+	assert.InDelta(t, 0.5, rr, 1e-6)
+	
+	recall, rr = retrievalMetrics(docs, []string{"c.txt"})
+	assert.Equal(t, 0.0, recall)
+	assert.Equal(t, 0.0, rr)
+}
+
+func TestEvaluate(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "apple.txt"), []byte("apple apple banana"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "car.txt"), []byte("car car dog"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, NamespaceDir, "team"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, NamespaceDir, "team", "dog.txt"), []byte("dog dog"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	
+	evaluator := &Evaluator{
+		K: 1,
+		NewRetriever: func(namespace string) *Retriever {
+			return NewRetriever(conf.RagConfInfo.Store, 3, namespace)
+		},
+		Generate: func(ctx context.Context, question string, docs []schema.Document) (string, error) {
+			return docs[0].PageContent, nil
+		},
+		Embedder: &fakeEmbedder{},
+	}
+	
+	report := evaluator.Evaluate(ctx, []*EvalCase{
+		{Question: "apple", Sources: []string{"apple.txt"}, Answer: "apple apple banana"},
+		{Question: "dog", Namespace: "team", Sources: []string{"dog.txt", "car.txt"}, Answer: "car"},
+		{Question: "banana"},
+	})
+	assert.Equal(t, 3, report.Cases)
+	assert.Equal(t, 2, report.SourceCases)
+	assert.Equal(t, 2, report.AnswerCases)
+	assert.Len(t, report.Results, 3)
+	
+	assert.Equal(t, []string{"apple.txt"}, report.Results[0].Retrieved)
+	assert.Equal(t, 1.0, report.Results[0].Recall)
+	assert.InDelta(t, 1.0, report.Results[0].AnswerSimilarity, 1e-6)
+	
+	// only top 1 document is retrieved, dog.txt is found but car.txt is not
+	assert.Equal(t, []string{"namespaces/team/dog.txt"}, report.Results[1].Retrieved)
+	assert.Equal(t, 0.5, report.Results[1].Recall)
+	assert.Equal(t, 1.0, report.Results[1].ReciprocalRank)
+	assert.Equal(t, 0.0, report.Results[1].AnswerSimilarity)
+	
+	assert.InDelta(t, 0.75, report.RecallAtK, 1e-6)
+	assert.InDelta(t, 1.0, report.MRR, 1e-6)
+	assert.InDelta(t, 0.5, report.AnswerSimilarity, 1e-6)
+}
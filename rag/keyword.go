@@ -0,0 +1,291 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	
+	// rrfK rank constant of reciprocal rank fusion
+	rrfK = 60
+)
+
+// keywords bm25 index of all knowledge chunks
+var keywords = newKeywordIndex()
+
+type keywordDoc struct {
+	doc    schema.Document
+	length int
+}
+
+// keywordIndex bm25 index of knowledge chunks, exact words like error codes and ticket numbers
+// are often missed by vector search but found by keywords.
+type keywordIndex struct {
+	lock     sync.RWMutex
+	docs     map[string]*keywordDoc
+	postings map[string]map[string]int // term -> chunk key -> term frequency
+	files    map[string][]string       // file path -> chunk keys
+	totalLen int
+}
+
+func newKeywordIndex() *keywordIndex {
+	return &keywordIndex{
+		docs:     make(map[string]*keywordDoc),
+		postings: make(map[string]map[string]int),
+		files:    make(map[string][]string),
+	}
+}
+
+// hybridEnabled check keyword index is used
+func hybridEnabled() bool {
+	return conf.RagConfInfo.HybridSearch != nil && *conf.RagConfInfo.HybridSearch
+}
+
+// chunkKey identify chunk by file, index and content, same chunk returned by vector db and keyword index
+// gets same key
+func chunkKey(doc schema.Document) string {
+	return fmt.Sprintf("%s#%d#%s", metadataString(doc.Metadata["file_path"]),
+		metadataInt(doc.Metadata["chunk_index"]), doc.PageContent)
+}
+
+// Add add chunks into index, old chunks of same files are replaced
+func (k *keywordIndex) Add(docs []schema.Document) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	
+	replaced := make(map[string]bool)
+	for _, doc := range docs {
+		filePath := metadataString(doc.Metadata["file_path"])
+		if !replaced[filePath] {
+			k.removeFile(filePath)
+			replaced[filePath] = true
+		}
+		
+		key := chunkKey(doc)
+		if _, ok := k.docs[key]; ok {
+			continue
+		}
+		
+		terms := tokenize(doc.PageContent)
+		k.docs[key] = &keywordDoc{doc: doc, length: len(terms)}
+		k.files[filePath] = append(k.files[filePath], key)
+		k.totalLen += len(terms)
+		for _, term := range terms {
+			if k.postings[term] == nil {
+				k.postings[term] = make(map[string]int)
+			}
+			k.postings[term][key]++
+		}
+	}
+}
+
+// RemoveFile remove all chunks of file
+func (k *keywordIndex) RemoveFile(filePath string) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.removeFile(filePath)
+}
+
+func (k *keywordIndex) removeFile(filePath string) {
+	for _, key := range k.files[filePath] {
+		kd, ok := k.docs[key]
+		if !ok {
+			continue
+		}
+		for _, term := range tokenize(kd.doc.PageContent) {
+			delete(k.postings[term], key)
+			if len(k.postings[term]) == 0 {
+				delete(k.postings, term)
+			}
+		}
+		k.totalLen -= kd.length
+		delete(k.docs, key)
+	}
+	delete(k.files, filePath)
+}
+
+// Count number of chunks in index
+func (k *keywordIndex) Count() int {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return len(k.docs)
+}
+
+// Search get chunks of namespaces with the highest bm25 score, score of document is bm25 score
+func (k *keywordIndex) Search(query string, num int, namespaces ...string) []schema.Document {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	
+	if len(k.docs) == 0 {
+		return nil
+	}
+	
+	allowed := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		allowed[namespace] = true
+	}
+	
+	n := float64(len(k.docs))
+	avgLen := float64(k.totalLen) / n
+	scores := make(map[string]float64)
+	searched := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if searched[term] {
+			continue
+		}
+		searched[term] = true
+		
+		posting := k.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for key, tf := range posting {
+			kd := k.docs[key]
+			if !allowed[metadataString(kd.doc.Metadata[namespaceKey])] {
+				continue
+			}
+			f := float64(tf)
+			scores[key] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(kd.length)/avgLen))
+		}
+	}
+	
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] == scores[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return scores[keys[i]] > scores[keys[j]]
+	})
+	if num > 0 && len(keys) > num {
+		keys = keys[:num]
+	}
+	
+	docs := make([]schema.Document, 0, len(keys))
+	for _, key := range keys {
+		doc := k.docs[key].doc
+		doc.Score = float32(scores[key])
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// hasFile check chunks of file with md5 are in index
+func (k *keywordIndex) hasFile(filePath, fileMd5 string) bool {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	
+	keys := k.files[filePath]
+	return len(keys) > 0 && metadataString(k.docs[keys[0]].doc.Metadata["file_md5"]) == fileMd5
+}
+
+// loadKeywordIndex add chunks of indexed file into keyword index, vectors of file are kept in vector db
+// so file is only loaded and split.
+func loadKeywordIndex(ctx context.Context, filePath, fileMd5 string) {
+	if !hybridEnabled() || keywords.hasFile(filePath, fileMd5) {
+		return
+	}
+	
+	docs, err := loadDoc(ctx, filePath)
+	if err != nil {
+		logger.Error("load keyword index fail", "file", filePath, "err", err)
+		return
+	}
+	keywords.Add(docs)
+}
+
+// reciprocalRankFusion merge ranked lists, score of document is sum of 1 / (rrfK + rank) in every list
+func reciprocalRankFusion(lists ...[]schema.Document) []schema.Document {
+	scores := make(map[string]float64)
+	docs := make(map[string]schema.Document)
+	keys := make([]string, 0)
+	for _, list := range lists {
+		for rank, doc := range list {
+			key := chunkKey(doc)
+			if _, ok := docs[key]; !ok {
+				docs[key] = doc
+				keys = append(keys, key)
+			}
+			scores[key] += 1 / float64(rrfK+rank+1)
+		}
+	}
+	
+	sort.SliceStable(keys, func(i, j int) bool {
+		return scores[keys[i]] > scores[keys[j]]
+	})
+	
+	res := make([]schema.Document, 0, len(keys))
+	for _, key := range keys {
+		doc := docs[key]
+		doc.Score = float32(scores[key])
+		res = append(res, doc)
+	}
+	return res
+}
+
+// tokenize split text into lower case terms. Words keep "_", "-" and "." inside, so identifiers like
+// ERR_CONN-42 are matched as whole and by parts. Chinese, Japanese and Korean text is split into
+// single characters and bigrams.
+func tokenize(text string) []string {
+	terms := make([]string, 0)
+	word := make([]rune, 0)
+	var prevCJK rune
+	
+	flush := func() {
+		w := strings.Trim(string(word), "_-.")
+		word = word[:0]
+		if w == "" {
+			return
+		}
+		terms = append(terms, w)
+		if strings.ContainsAny(w, "_-.") {
+			for _, part := range strings.FieldsFunc(w, func(r rune) bool {
+				return r == '_' || r == '-' || r == '.'
+			}) {
+				terms = append(terms, part)
+			}
+		}
+	}
+	
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			terms = append(terms, string(r))
+			if prevCJK != 0 {
+				terms = append(terms, string([]rune{prevCJK, r}))
+			}
+			prevCJK = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.':
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevCJK = 0
+	}
+	flush()
+	
+	return terms
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
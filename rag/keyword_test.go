@@ -0,0 +1,110 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"error", "err_conn-42", "err", "conn", "42", "in", "v1.2", "v1", "2"},
+		tokenize("Error ERR_CONN-42 in v1.2."))
+	assert.Equal(t, []string{"工", "单", "工单", "123"}, tokenize("工单 123"))
+}
+
+func TestKeywordIndex(t *testing.T) {
+	index := newKeywordIndex()
+	index.Add([]schema.Document{
+		{PageContent: "restart the server", Metadata: map[string]any{"file_path": "a.txt", "chunk_index": 0}},
+		{PageContent: "error E1234 means timeout", Metadata: map[string]any{"file_path": "a.txt", "chunk_index": 1}},
+		{PageContent: "error E5678 of team", Metadata: map[string]any{"file_path": "namespaces/team/b.txt", "chunk_index": 0, "namespace": "team"}},
+	})
+	assert.Equal(t, 3, index.Count())
+	
+	docs := index.Search("what is E1234", 3, "")
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "error E1234 means timeout", docs[0].PageContent)
+	assert.True(t, docs[0].Score > 0)
+	
+	// documents of other namespaces are invisible
+	assert.Len(t, index.Search("error", 3, ""), 1)
+	assert.Len(t, index.Search("error", 3, "", "team"), 2)
+	
+	// file added again replaces old chunks
+	index.Add([]schema.Document{
+		{PageContent: "error E9999", Metadata: map[string]any{"file_path": "a.txt", "chunk_index": 0}},
+	})
+	assert.Equal(t, 2, index.Count())
+	assert.Len(t, index.Search("E1234", 3, ""), 0)
+	
+	index.RemoveFile("a.txt")
+	assert.Equal(t, 1, index.Count())
+	assert.Len(t, index.Search("E9999", 3, ""), 0)
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	a := schema.Document{PageContent: "a", Metadata: map[string]any{"file_path": "a.txt"}}
+	b := schema.Document{PageContent: "b", Metadata: map[string]any{"file_path": "b.txt"}}
+	c := schema.Document{PageContent: "c", Metadata: map[string]any{"file_path": "c.txt"}}
+	
+	docs := reciprocalRankFusion([]schema.Document{a, b}, []schema.Document{c, b})
+	assert.Len(t, docs, 3)
+	assert.Equal(t, "b", docs[0].PageContent)
+	assert.Equal(t, "a", docs[1].PageContent)
+	assert.Equal(t, "c", docs[2].PageContent)
+	assert.InDelta(t, 2.0/62, docs[0].Score, 1e-6)
+}
+
+func TestHybridRetriever(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	hybrid := true
+	conf.RagConfInfo.HybridSearch = &hybrid
+	defer func() {
+		conf.RagConfInfo.HybridSearch = nil
+	}()
+	keywords = newKeywordIndex()
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("apple"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("apple banana"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("dog ticket JIRA-4521 fixed"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, keywords.Count())
+	
+	// vector search misses ticket number
+	retriever := NewRetriever(store, 1, "")
+	retriever.ScoreThreshold = 0.5
+	docs, err := retriever.GetRelevantDocuments(ctx, "JIRA-4521")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 0)
+	
+	retriever.Hybrid = true
+	docs, err = retriever.GetRelevantDocuments(ctx, "JIRA-4521")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "c.txt", docs[0].Metadata["file_path"])
+	
+	docs, err = retriever.GetRelevantDocuments(ctx, "apple")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "a.txt", docs[0].Metadata["file_path"])
+	
+	// keyword index is rebuilt from knowledge files after restart
+	keywords = newKeywordIndex()
+	_, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, keywords.Count())
+	
+	assert.Nil(t, os.Remove(filepath.Join(dir, "c.txt")))
+	_, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, keywords.Count())
+}
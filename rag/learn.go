@@ -0,0 +1,62 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+var (
+	UnsupportedFileErr  = errors.New("unsupported file type")
+	FileContentEmptyErr = errors.New("no content found in file")
+)
+
+// learnFileExts extensions of files which can be sent to bot, markdown and source code are checked separately
+var learnFileExts = map[string]bool{
+	".txt":   true,
+	".pdf":   true,
+	".csv":   true,
+	".html":  true,
+	".docx":  true,
+	".json":  true,
+	".jsonl": true,
+	".epub":  true,
+}
+
+// IsSupportedFile check file can be loaded into knowledge base
+func IsSupportedFile(fileName string) bool {
+	return learnFileExts[strings.ToLower(filepath.Ext(fileName))] || isMarkdownFile(fileName) ||
+		getCodeLanguage(fileName) != ""
+}
+
+// LearnFile save file sent by user into namespace of chat and record user as owner,
+// return relative path and number of chunks
+func LearnFile(ctx context.Context, namespace, owner, fileName string, data []byte) (string, int, error) {
+	if !IsSupportedFile(fileName) {
+		return "", 0, UnsupportedFileErr
+	}
+	if len(data) == 0 {
+		return "", 0, FileContentEmptyErr
+	}
+	
+	filePath, chunks, err := SaveNamespaceFile(ctx, namespace, fileName, data)
+	if err != nil {
+		return "", 0, err
+	}
+	
+	err = db.UpdateOwnerByFileName(filePath, owner)
+	if err != nil {
+		logger.Error("update rag file owner fail", "file", filePath, "err", err)
+	}
+	
+	if chunks == 0 {
+		return filePath, 0, FileContentEmptyErr
+	}
+	
+	logger.Info("learn file", "owner", owner, "file", filePath, "chunks", chunks)
+	return filePath, chunks, nil
+}
@@ -0,0 +1,36 @@
+package rag
+
+import (
+	"context"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestLearnFile(t *testing.T) {
+	initTestRagConf(t)
+	ctx := context.Background()
+	
+	assert.True(t, IsSupportedFile("a.PDF"))
+	assert.True(t, IsSupportedFile("readme.md"))
+	assert.True(t, IsSupportedFile("main.go"))
+	assert.False(t, IsSupportedFile("a.bin"))
+	
+	filePath, chunks, err := LearnFile(ctx, "-1001", "42", "note.txt", []byte("apple banana"))
+	assert.Nil(t, err)
+	assert.Equal(t, "namespaces/-1001/note.txt", filePath)
+	assert.Equal(t, 1, chunks)
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "42", ragFiles[0].Owner)
+	assert.NotEmpty(t, ragFiles[0].VectorId)
+	
+	_, _, err = LearnFile(ctx, "-1001", "42", "a.bin", []byte("apple"))
+	assert.ErrorIs(t, err, UnsupportedFileErr)
+	
+	_, _, err = LearnFile(ctx, "-1001", "42", "empty.txt", nil)
+	assert.ErrorIs(t, err, FileContentEmptyErr)
+}
@@ -0,0 +1,366 @@
+package rag
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	
+	"github.com/yincongcyincong/langchaingo/documentloaders"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/textsplitter"
+)
+
+var (
+	DocxContentNotFoundErr = errors.New("word/document.xml not found in docx")
+	EpubContentNotFoundErr = errors.New("no chapter found in epub")
+)
+
+// docxLoader load text of word document, paragraphs are split by new line
+type docxLoader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+var _ documentloaders.Loader = docxLoader{}
+
+func newDocxLoader(r io.ReaderAt, size int64) docxLoader {
+	return docxLoader{r: r, size: size}
+}
+
+// Load read word/document.xml and collect text of w:t elements
+func (l docxLoader) Load(_ context.Context) ([]schema.Document, error) {
+	zr, err := zip.NewReader(l.r, l.size)
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		
+		content, err := parseDocxXML(rc)
+		if err != nil {
+			return nil, err
+		}
+		
+		return []schema.Document{
+			{
+				PageContent: content,
+				Metadata:    map[string]any{},
+			},
+		}, nil
+	}
+	
+	return nil, DocxContentNotFoundErr
+}
+
+func (l docxLoader) LoadAndSplit(ctx context.Context, splitter textsplitter.TextSplitter) ([]schema.Document, error) {
+	docs, err := l.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return textsplitter.SplitDocuments(splitter, docs)
+}
+
+func parseDocxXML(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	sb := new(strings.Builder)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// jsonLoader load json or jsonl file, every element of top level array or every line
+// of jsonl is a document, fields are flattened into "a.b: value" lines.
+type jsonLoader struct {
+	r     io.Reader
+	lines bool
+}
+
+var _ documentloaders.Loader = jsonLoader{}
+
+func newJSONLoader(r io.Reader, lines bool) jsonLoader {
+	return jsonLoader{r: r, lines: lines}
+}
+
+func (l jsonLoader) Load(_ context.Context) ([]schema.Document, error) {
+	docs := make([]schema.Document, 0)
+	if l.lines {
+		scanner := bufio.NewScanner(l.r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			
+			var v interface{}
+			if err := json.Unmarshal(line, &v); err != nil {
+				return nil, fmt.Errorf("parse line %d fail: %w", lineNum, err)
+			}
+			docs = appendJSONDoc(docs, v, map[string]any{"line": lineNum})
+		}
+		return docs, scanner.Err()
+	}
+	
+	var v interface{}
+	if err := json.NewDecoder(l.r).Decode(&v); err != nil {
+		return nil, err
+	}
+	
+	if arr, ok := v.([]interface{}); ok {
+		for i, item := range arr {
+			docs = appendJSONDoc(docs, item, map[string]any{"index": i})
+		}
+		return docs, nil
+	}
+	
+	return appendJSONDoc(docs, v, map[string]any{}), nil
+}
+
+func (l jsonLoader) LoadAndSplit(ctx context.Context, splitter textsplitter.TextSplitter) ([]schema.Document, error) {
+	docs, err := l.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return textsplitter.SplitDocuments(splitter, docs)
+}
+
+func appendJSONDoc(docs []schema.Document, v interface{}, metadata map[string]any) []schema.Document {
+	lines := make([]string, 0)
+	flattenJSON("", v, &lines)
+	content := strings.TrimSpace(strings.Join(lines, "\n"))
+	if content == "" {
+		return docs
+	}
+	
+	return append(docs, schema.Document{
+		PageContent: content,
+		Metadata:    metadata,
+	})
+}
+
+func flattenJSON(prefix string, v interface{}, lines *[]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenJSON(key, val[k], lines)
+		}
+	case []interface{}:
+		for i, item := range val {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), item, lines)
+		}
+	case nil:
+	default:
+		if prefix == "" {
+			*lines = append(*lines, fmt.Sprint(val))
+		} else {
+			*lines = append(*lines, fmt.Sprintf("%s: %v", prefix, val))
+		}
+	}
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Title    string `xml:"metadata>title"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubLoader load epub book, every chapter in spine is a document
+type epubLoader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+var _ documentloaders.Loader = epubLoader{}
+
+func newEpubLoader(r io.ReaderAt, size int64) epubLoader {
+	return epubLoader{r: r, size: size}
+}
+
+func (l epubLoader) Load(ctx context.Context) ([]schema.Document, error) {
+	zr, err := zip.NewReader(l.r, l.size)
+	if err != nil {
+		return nil, err
+	}
+	
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	
+	chapters := getEpubChapters(files)
+	docs := make([]schema.Document, 0, len(chapters))
+	for i, chapter := range chapters {
+		f, ok := files[chapter]
+		if !ok {
+			continue
+		}
+		
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		htmlDocs, err := documentloaders.NewHTML(rc).Load(ctx)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("load chapter %s fail: %w", chapter, err)
+		}
+		
+		for _, doc := range htmlDocs {
+			if doc.PageContent == "" {
+				continue
+			}
+			doc.Metadata["chapter"] = i + 1
+			docs = append(docs, doc)
+		}
+	}
+	
+	if len(docs) == 0 {
+		return nil, EpubContentNotFoundErr
+	}
+	return docs, nil
+}
+
+func (l epubLoader) LoadAndSplit(ctx context.Context, splitter textsplitter.TextSplitter) ([]schema.Document, error) {
+	docs, err := l.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return textsplitter.SplitDocuments(splitter, docs)
+}
+
+// getEpubChapters get chapter paths by spine order, use all html files if package is broken
+func getEpubChapters(files map[string]*zip.File) []string {
+	chapters := make([]string, 0)
+	if opfPath := getEpubPackagePath(files); opfPath != "" {
+		pkg := new(epubPackage)
+		if err := readZipXML(files[opfPath], pkg); err == nil {
+			hrefs := make(map[string]string, len(pkg.Manifest))
+			for _, item := range pkg.Manifest {
+				hrefs[item.ID] = item.Href
+			}
+			for _, item := range pkg.Spine {
+				if href, ok := hrefs[item.IDRef]; ok {
+					chapters = append(chapters, path.Join(path.Dir(opfPath), href))
+				}
+			}
+		}
+	}
+	if len(chapters) > 0 {
+		return chapters
+	}
+	
+	for name := range files {
+		ext := strings.ToLower(path.Ext(name))
+		if ext == ".xhtml" || ext == ".html" || ext == ".htm" {
+			chapters = append(chapters, name)
+		}
+	}
+	sort.Strings(chapters)
+	return chapters
+}
+
+func getEpubPackagePath(files map[string]*zip.File) string {
+	container := new(epubContainer)
+	if err := readZipXML(files["META-INF/container.xml"], container); err == nil {
+		for _, rootfile := range container.Rootfiles {
+			if _, ok := files[rootfile.FullPath]; ok {
+				return rootfile.FullPath
+			}
+		}
+	}
+	
+	for name := range files {
+		if strings.HasSuffix(strings.ToLower(name), ".opf") {
+			return name
+		}
+	}
+	return ""
+}
+
+func readZipXML(f *zip.File, v interface{}) error {
+	if f == nil {
+		return os.ErrNotExist
+	}
+	
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	
+	return xml.NewDecoder(rc).Decode(v)
+}
@@ -0,0 +1,125 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+)
+
+func buildZip(t *testing.T, files map[string]string) *bytes.Reader {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = f.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestDocxLoader(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve">world</w:t></w:r></w:p>
+<w:p><w:r><w:t>second</w:t><w:br/><w:t>line</w:t></w:r></w:p>
+</w:body></w:document>`,
+	})
+	
+	docs, err := newDocxLoader(r, r.Size()).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(docs))
+	assert.Equal(t, "Hello\tworld\nsecond\nline", docs[0].PageContent)
+	
+	r = buildZip(t, map[string]string{"other.xml": "<a/>"})
+	_, err = newDocxLoader(r, r.Size()).Load(context.Background())
+	assert.ErrorIs(t, err, DocxContentNotFoundErr)
+}
+
+func TestJSONLoader(t *testing.T) {
+	docs, err := newJSONLoader(strings.NewReader(`[{"name":"apple","tags":["red","sweet"]},{"name":"car","info":{"wheels":4}}]`), false).
+		Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(docs))
+	assert.Equal(t, "name: apple\ntags[0]: red\ntags[1]: sweet", docs[0].PageContent)
+	assert.Equal(t, "info.wheels: 4\nname: car", docs[1].PageContent)
+	assert.Equal(t, 1, docs[1].Metadata["index"])
+	
+	docs, err = newJSONLoader(strings.NewReader("{\"q\":\"dog\"}\n\n{\"q\":\"banana\"}\n"), true).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(docs))
+	assert.Equal(t, "q: banana", docs[1].PageContent)
+	assert.Equal(t, 3, docs[1].Metadata["line"])
+	
+	_, err = newJSONLoader(strings.NewReader("{\"q\":\n"), true).Load(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestEpubLoader(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+<metadata><title>Book</title></metadata>
+<manifest>
+<item id="c1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+<item id="c2" href="text/ch2.xhtml" media-type="application/xhtml+xml"/>
+</manifest>
+<spine><itemref idref="c2"/><itemref idref="c1"/></spine>
+</package>`,
+		"OEBPS/text/ch1.xhtml": `<html><body><p>apple chapter</p></body></html>`,
+		"OEBPS/text/ch2.xhtml": `<html><body><p>banana chapter</p></body></html>`,
+	})
+	
+	docs, err := newEpubLoader(r, r.Size()).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(docs))
+	assert.Equal(t, "banana chapter", docs[0].PageContent)
+	assert.Equal(t, "apple chapter", docs[1].PageContent)
+	assert.Equal(t, 2, docs[1].Metadata["chapter"])
+	
+	// broken package, fall back to html files
+	r = buildZip(t, map[string]string{
+		"b.html": `<html><body>dog</body></html>`,
+		"a.html": `<html><body>car</body></html>`,
+	})
+	docs, err = newEpubLoader(r, r.Size()).Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(docs))
+	assert.Equal(t, "car", docs[0].PageContent)
+}
+
+func TestGetSplitter(t *testing.T) {
+	chunkSize, chunkOverlap, extChunkSize := 100, 0, "go:60:0"
+	conf.RagConfInfo.ChunkSize = &chunkSize
+	conf.RagConfInfo.ChunkOverlap = &chunkOverlap
+	conf.RagConfInfo.ExtChunkSize = &extChunkSize
+	
+	chunks, err := getSplitter("readme.MD").SplitText("# Fruit\n\n## Apple\n\napple is red\n\n## Banana\n\nbanana is yellow\n")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(chunks))
+	assert.Equal(t, "# Fruit\n## Apple\napple is red", chunks[1])
+	assert.Equal(t, "# Fruit\n## Banana\nbanana is yellow", chunks[2])
+	
+	code := "package main\n\nfunc apple() {\n\treturn\n}\n\nfunc banana() {\n\treturn\n}\n"
+	chunks, err = getSplitter("main.go").SplitText(code)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(chunks))
+	assert.True(t, strings.HasSuffix(chunks[0], "func apple() {\n\treturn\n}"))
+	assert.Equal(t, "func banana() {\n\treturn\n}", chunks[1])
+	
+	assert.Equal(t, "go", getCodeLanguage("main.go"))
+	assert.Equal(t, "ts", getCodeLanguage("app.TSX"))
+	assert.Equal(t, "", getCodeLanguage("a.txt"))
+	assert.True(t, isMarkdownFile("a.markdown"))
+}
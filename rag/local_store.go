@@ -0,0 +1,276 @@
+package rag
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	
+	uuid "github.com/satori/go.uuid"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/embeddings"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
+)
+
+var (
+	EmbedderEmptyErr   = errors.New("embedder is empty")
+	EmbeddingNumberErr = errors.New("number of embeddings not match number of documents")
+)
+
+type localVector struct {
+	id        string
+	content   string
+	metadata  map[string]any
+	embedding []float32
+	norm      float64
+}
+
+// LocalStore embedded vector store, vectors are persisted in rag_vectors table
+// and searched by brute force cosine similarity in memory.
+type LocalStore struct {
+	embedder embeddings.Embedder
+	
+	mu      sync.RWMutex
+	vectors map[string]*localVector
+}
+
+var _ vectorstores.VectorStore = (*LocalStore)(nil)
+
+// NewLocalStore create embedded vector store and load vectors from db
+func NewLocalStore(embedder embeddings.Embedder) (*LocalStore, error) {
+	s := &LocalStore{
+		embedder: embedder,
+		vectors:  make(map[string]*localVector),
+	}
+	
+	rows, err := db.GetRagVectors()
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, row := range rows {
+		metadata := make(map[string]any)
+		if row.Metadata != "" {
+			if err = json.Unmarshal([]byte(row.Metadata), &metadata); err != nil {
+				logger.Warn("unmarshal vector metadata fail", "vector_id", row.VectorId, "err", err)
+			}
+		}
+		s.put(row.VectorId, row.Content, metadata, decodeEmbedding(row.Embedding))
+	}
+	
+	logger.Info("local vector store loaded", "vectors", len(s.vectors))
+	return s, nil
+}
+
+// AddDocuments embed documents and save them, return vector ids
+func (s *LocalStore) AddDocuments(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) ([]string, error) {
+	opts := s.getOptions(options...)
+	if opts.Embedder == nil {
+		return nil, EmbedderEmptyErr
+	}
+	
+	if opts.Deduplicater != nil {
+		filtered := make([]schema.Document, 0, len(docs))
+		for _, doc := range docs {
+			if !opts.Deduplicater(ctx, doc) {
+				filtered = append(filtered, doc)
+			}
+		}
+		docs = filtered
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	
+	texts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		texts = append(texts, doc.PageContent)
+	}
+	
+	vectors, err := opts.Embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(docs) {
+		return nil, EmbeddingNumberErr
+	}
+	
+	ids := make([]string, 0, len(docs))
+	rows := make([]*db.RagVector, 0, len(docs))
+	for i, doc := range docs {
+		metadata, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("marshal metadata fail: %w", err)
+		}
+		
+		id := uuid.NewV4().String()
+		ids = append(ids, id)
+		rows = append(rows, &db.RagVector{
+			VectorId:  id,
+			Content:   doc.PageContent,
+			Metadata:  string(metadata),
+			Embedding: encodeEmbedding(vectors[i]),
+		})
+	}
+	
+	err = db.InsertRagVectors(rows)
+	if err != nil {
+		return nil, err
+	}
+	
+	for i, doc := range docs {
+		s.put(ids[i], doc.PageContent, doc.Metadata, vectors[i])
+	}
+	
+	return ids, nil
+}
+
+// SimilaritySearch search most similar documents, filters is map[string]any matched with metadata
+func (s *LocalStore) SimilaritySearch(ctx context.Context, query string, numDocuments int, options ...vectorstores.Option) ([]schema.Document, error) {
+	opts := s.getOptions(options...)
+	if opts.Embedder == nil {
+		return nil, EmbedderEmptyErr
+	}
+	
+	queryVector, err := opts.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	
+	filters, _ := opts.Filters.(map[string]any)
+	queryNorm := vectorNorm(queryVector)
+	
+	s.mu.RLock()
+	docs := make([]schema.Document, 0)
+	for _, v := range s.vectors {
+		if !matchFilters(v.metadata, filters) {
+			continue
+		}
+		
+		score := cosineSimilarity(queryVector, queryNorm, v.embedding, v.norm)
+		if opts.ScoreThreshold > 0 && score < opts.ScoreThreshold {
+			continue
+		}
+		
+		docs = append(docs, schema.Document{
+			PageContent: v.content,
+			Metadata:    copyMetadata(v.metadata),
+			Score:       score,
+		})
+	}
+	s.mu.RUnlock()
+	
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Score > docs[j].Score
+	})
+	if numDocuments > 0 && len(docs) > numDocuments {
+		docs = docs[:numDocuments]
+	}
+	
+	return docs, nil
+}
+
+// Delete delete vectors by vector ids
+func (s *LocalStore) Delete(ctx context.Context, ids []string) error {
+	err := db.DeleteRagVectorByVectorIds(ids)
+	if err != nil {
+		return err
+	}
+	
+	s.mu.Lock()
+	for _, id := range ids {
+		delete(s.vectors, id)
+	}
+	s.mu.Unlock()
+	
+	return nil
+}
+
+// Count get number of vectors in store
+func (s *LocalStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.vectors)
+}
+
+func (s *LocalStore) put(id, content string, metadata map[string]any, embedding []float32) {
+	s.mu.Lock()
+	s.vectors[id] = &localVector{
+		id:        id,
+		content:   content,
+		metadata:  metadata,
+		embedding: embedding,
+		norm:      vectorNorm(embedding),
+	}
+	s.mu.Unlock()
+}
+
+func (s *LocalStore) getOptions(options ...vectorstores.Option) vectorstores.Options {
+	opts := vectorstores.Options{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.Embedder == nil {
+		opts.Embedder = s.embedder
+	}
+	return opts
+}
+
+func matchFilters(metadata map[string]any, filters map[string]any) bool {
+	for k, v := range filters {
+		if metadataString(metadata[k]) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyMetadata(metadata map[string]any) map[string]any {
+	res := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		res[k] = v
+	}
+	return res
+}
+
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, f := range v {
+		sum += float64(f) * float64(f)
+	}
+	return math.Sqrt(sum)
+}
+
+func cosineSimilarity(a []float32, aNorm float64, b []float32, bNorm float64) float32 {
+	if len(a) != len(b) || aNorm == 0 || bNorm == 0 {
+		return 0
+	}
+	
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return float32(dot / (aNorm * bNorm))
+}
+
+func encodeEmbedding(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeEmbedding(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package rag
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
+)
+
+// fakeEmbedder embed text by counting some keywords
+type fakeEmbedder struct{}
+
+var fakeKeywords = []string{"apple", "banana", "car", "dog"}
+
+func (f *fakeEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	res := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		v, _ := f.EmbedQuery(ctx, text)
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (f *fakeEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	v := make([]float32, len(fakeKeywords))
+	for i, k := range fakeKeywords {
+		v[i] = float32(strings.Count(strings.ToLower(text), k))
+	}
+	return v, nil
+}
+
+func initTestDB(t *testing.T) {
+	var err error
+	db.DB, err = sql.Open("sqlite3", ":memory:")
+	assert.Nil(t, err)
+	db.DB.SetMaxOpenConns(1)
+	_, err = db.DB.Exec(`CREATE TABLE rag_vectors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		vector_id VARCHAR(64) NOT NULL DEFAULT '',
+		content TEXT NOT NULL,
+		metadata TEXT NOT NULL,
+		embedding BLOB NOT NULL,
+		create_time int(10) NOT NULL DEFAULT '0'
+	);
+	CREATE TABLE rag_files (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_name VARCHAR(255) NOT NULL DEFAULT '',
+		file_md5 VARCHAR(255) NOT NULL DEFAULT '',
+		vector_id TEXT NOT NULL DEFAULT '',
+		owner varchar(100) NOT NULL DEFAULT '',
+		create_time int(10) NOT NULL DEFAULT '0',
+		update_time int(10) NOT NULL DEFAULT '0',
+		is_deleted int(10) NOT NULL DEFAULT '0'
+	);`)
+	assert.Nil(t, err)
+}
+
+func TestLocalStore(t *testing.T) {
+	initTestDB(t)
+	ctx := context.Background()
+	
+	store, err := NewLocalStore(&fakeEmbedder{})
+	assert.Nil(t, err)
+	
+	ids, err := store.AddDocuments(ctx, []schema.Document{
+		{PageContent: "apple apple banana", Metadata: map[string]any{"file_name": "fruit.txt"}},
+		{PageContent: "car car dog", Metadata: map[string]any{"file_name": "other.txt"}},
+		{PageContent: "apple", Metadata: map[string]any{"file_name": "other.txt"}},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ids, 3)
+	
+	docs, err := store.SimilaritySearch(ctx, "car", 1)
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "car car dog", docs[0].PageContent)
+	
+	docs, err = store.SimilaritySearch(ctx, "apple", 3, vectorstores.WithFilters(map[string]any{"file_name": "fruit.txt"}))
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "fruit.txt", docs[0].Metadata["file_name"])
+	
+	docs, err = store.SimilaritySearch(ctx, "apple", 3, vectorstores.WithScoreThreshold(0.5))
+	assert.Nil(t, err)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "apple", docs[0].PageContent)
+	
+	// reload from db
+	reload, err := NewLocalStore(&fakeEmbedder{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, reload.Count())
+	
+	err = reload.Delete(ctx, ids[:2])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, reload.Count())
+	
+	reload, err = NewLocalStore(&fakeEmbedder{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, reload.Count())
+}
@@ -0,0 +1,160 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+var (
+	InvalidFilePathErr = errors.New("file path is invalid")
+	FileNotExistErr    = errors.New("file not exist in knowledge path")
+)
+
+// CleanFilePath clean path relative to knowledge path, paths out of knowledge path are invalid
+func CleanFilePath(filePath string) (string, error) {
+	filePath = strings.TrimSpace(strings.ReplaceAll(filePath, "\\", "/"))
+	if filePath == "" || strings.HasPrefix(filePath, "/") {
+		return "", InvalidFilePathErr
+	}
+	
+	filePath = path.Clean(filePath)
+	if filePath == "." || filePath == ".." || strings.HasPrefix(filePath, "../") {
+		return "", InvalidFilePathErr
+	}
+	return filePath, nil
+}
+
+// DeleteKnowledgeFile delete vectors, rag_files record and knowledge file
+func DeleteKnowledgeFile(ctx context.Context, filePath string) error {
+	filePath, err := CleanFilePath(filePath)
+	if err != nil {
+		return err
+	}
+	
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	if err != nil {
+		return err
+	}
+	for _, ragFile := range ragFiles {
+		if err = deleteRagFile(ctx, ragFile); err != nil {
+			return err
+		}
+	}
+	
+	err = os.Remove(filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(ragFiles) == 0 && os.IsNotExist(err) {
+		return FileNotExistErr
+	}
+	
+	logger.Info("delete knowledge file", "file", filePath)
+	return nil
+}
+
+// ForgetUser delete knowledge files and vectors uploaded by user, then hard delete all data of user in db.
+// no receipt is written if any file or vector isn't deleted
+func ForgetUser(ctx context.Context, userId string) (*db.DeletionReceipt, error) {
+	stats, err := forgetRagFiles(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	
+	deleteLastSources(userId)
+	return db.ForgetUser(userId, stats)
+}
+
+// forgetRagFiles delete knowledge files and vectors uploaded by user
+func forgetRagFiles(ctx context.Context, userId string) (*db.ForgetStats, error) {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	ragFiles, err := db.GetRagFilesByOwner(userId)
+	if err != nil {
+		return nil, err
+	}
+	
+	stats := new(db.ForgetStats)
+	for _, ragFile := range ragFiles {
+		stats.Vectors += int64(GetChunkCount(ragFile))
+		var deleteErr error
+		if conf.RagConfInfo.Store != nil {
+			deleteErr = deleteRagFile(ctx, ragFile)
+		} else if ragFile.VectorId != "" {
+			// vectors of local store are kept in db even if rag isn't enabled now
+			deleteErr = db.DeleteRagVectorByVectorIds(strings.Split(ragFile.VectorId, ","))
+		}
+		if deleteErr != nil {
+			return nil, fmt.Errorf("delete vectors of %s fail: %w", ragFile.FileName, deleteErr)
+		}
+		
+		err = os.Remove(filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(ragFile.FileName)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("delete knowledge file %s fail: %w", ragFile.FileName, err)
+		}
+	}
+	return stats, nil
+}
+
+// ReindexFile delete vectors of knowledge file and embed it again, return number of chunks
+func ReindexFile(ctx context.Context, filePath string) (int, error) {
+	filePath, err := CleanFilePath(filePath)
+	if err != nil {
+		return 0, err
+	}
+	
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	info, err := os.Stat(filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath)))
+	if err != nil || info.IsDir() {
+		return 0, FileNotExistErr
+	}
+	if !IsSupportedFile(filePath) {
+		return 0, UnsupportedFileErr
+	}
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	if err != nil {
+		return 0, err
+	}
+	owners := getFileOwners(ragFiles)
+	for _, ragFile := range ragFiles {
+		if err = deleteRagFile(ctx, ragFile); err != nil {
+			return 0, err
+		}
+	}
+	
+	docs, err := handleFiles(ctx, []string{filePath})
+	restoreFileOwners(owners)
+	if err != nil {
+		return 0, err
+	}
+	if len(docs) > 0 {
+		insertVectorDb(ctx, docs)
+	}
+	
+	logger.Info("reindex knowledge file", "file", filePath, "chunks", len(docs))
+	return len(docs), nil
+}
+
+// GetChunkCount get number of chunks of file by its vector ids
+func GetChunkCount(ragFile *db.RagFiles) int {
+	if ragFile.VectorId == "" {
+		return 0
+	}
+	return strings.Count(ragFile.VectorId, ",") + 1
+}
@@ -0,0 +1,150 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+func TestCleanFilePath(t *testing.T) {
+	filePath, err := CleanFilePath("doc\\a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "doc/a.txt", filePath)
+	
+	filePath, err = CleanFilePath("doc/../a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", filePath)
+	
+	for _, p := range []string{"", "/etc/passwd", "../a.txt", "doc/../../a.txt", "."} {
+		_, err = CleanFilePath(p)
+		assert.ErrorIs(t, err, InvalidFilePathErr, p)
+	}
+}
+
+func TestManageKnowledgeFile(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Repeat("apple ", 10)+"\n\n"+strings.Repeat("banana ", 10)), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("car"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	
+	ragFiles, err := db.GetRagFileByPage(1, 10, "a.")
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, 2, GetChunkCount(ragFiles[0]))
+	total, err := db.GetRagFileCount("")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, total)
+	
+	assert.Nil(t, db.UpdateOwnerByFileName("a.txt", "42"))
+	chunks, err := ReindexFile(ctx, "a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, chunks)
+	assert.Equal(t, 3, store.Count())
+	
+	// owner is kept after file is embedded again
+	ragFiles, err = db.GetRagFileByFileName("a.txt")
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "42", ragFiles[0].Owner)
+	assert.NotEmpty(t, ragFiles[0].VectorId)
+	
+	_, err = ReindexFile(ctx, "c.txt")
+	assert.ErrorIs(t, err, FileNotExistErr)
+	
+	assert.Nil(t, DeleteKnowledgeFile(ctx, "a.txt"))
+	assert.Equal(t, 1, store.Count())
+	_, err = os.Stat(filepath.Join(dir, "a.txt"))
+	assert.True(t, os.IsNotExist(err))
+	total, err = db.GetRagFileCount("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+	
+	assert.ErrorIs(t, DeleteKnowledgeFile(ctx, "a.txt"), FileNotExistErr)
+	assert.ErrorIs(t, DeleteKnowledgeFile(ctx, "../a.txt"), InvalidFilePathErr)
+}
+
+// initForgetTestConf init rag conf and all tables of db, forgetting deletes data of user in all tables
+func initForgetTestConf(t *testing.T) string {
+	dir := initTestRagConf(t)
+	oldDBType, oldArchiveDir := conf.BaseConfInfo.DBType, conf.RetentionConfInfo.ArchiveDir
+	t.Cleanup(func() {
+		conf.BaseConfInfo.DBType, conf.RetentionConfInfo.ArchiveDir = oldDBType, oldArchiveDir
+	})
+	dbType, archiveDir := utils.Sqlite3, t.TempDir()
+	conf.BaseConfInfo.DBType, conf.RetentionConfInfo.ArchiveDir = &dbType, &archiveDir
+	
+	_, err := db.RunMigrations()
+	assert.Nil(t, err)
+	return dir
+}
+
+func TestForgetUserKeepsSharedFile(t *testing.T) {
+	dir := initForgetTestConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "mine.txt"), []byte("apple"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "manual.txt"), []byte("car"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, db.UpdateOwnerByFileName("mine.txt", "telegram:7"))
+	assert.Nil(t, db.UpdateOwnerByFileName("manual.txt", "telegram:7"))
+	
+	// user uploaded manual once, admin replaced it later
+	ragFiles, err := db.GetRagFileByFileName("manual.txt")
+	assert.Nil(t, err)
+	for _, ragFile := range ragFiles {
+		deleteRagFile(ctx, ragFile)
+	}
+	_, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	
+	_, err = ForgetUser(ctx, "telegram:7")
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dir, "mine.txt"))
+	assert.True(t, os.IsNotExist(err))
+	
+	// shared file without owner is kept
+	_, err = os.Stat(filepath.Join(dir, "manual.txt"))
+	assert.Nil(t, err)
+	ragFiles, err = db.GetRagFileByFileName("manual.txt")
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, 1, store.Count())
+}
+
+func TestForgetUserVectorDeleteFail(t *testing.T) {
+	dir := initForgetTestConf(t)
+	ctx := context.Background()
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "mine.txt"), []byte("apple"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, db.UpdateOwnerByFileName("mine.txt", "telegram:8"))
+	
+	// vectors can't be deleted, no receipt is written and file is kept
+	_, err = db.DB.Exec(`DROP TABLE rag_vectors`)
+	assert.Nil(t, err)
+	_, err = ForgetUser(ctx, "telegram:8")
+	assert.NotNil(t, err)
+	
+	receipts, err := db.GetDeletionReceipts("")
+	assert.Nil(t, err)
+	assert.Len(t, receipts, 0)
+	_, err = os.Stat(filepath.Join(dir, "mine.txt"))
+	assert.Nil(t, err)
+	ragFiles, err := db.GetRagFilesByOwner("telegram:8")
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+}
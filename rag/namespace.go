@@ -0,0 +1,227 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/langchaingo/schema"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
+)
+
+const (
+	// NamespaceDir files under knowledge_path/namespaces/<namespace>/ only belong to the namespace,
+	// other files are global and visible to every chat.
+	NamespaceDir = "namespaces"
+	
+	namespaceKey = "namespace"
+	
+	// candidateFactor times of top k documents searched before fusion and rerank
+	candidateFactor = 4
+)
+
+var (
+	namespaceReg = regexp.MustCompile(`[^a-zA-Z0-9_.\-]`)
+	
+	FileNameEmptyErr = errors.New("file name is empty")
+)
+
+// CleanNamespace replace characters which are not allowed in dir name
+func CleanNamespace(namespace string) string {
+	namespace = namespaceReg.ReplaceAllString(strings.TrimSpace(namespace), "_")
+	if strings.Trim(namespace, ".") == "" {
+		return ""
+	}
+	return namespace
+}
+
+// getFileNamespace get namespace of knowledge file by its relative path
+func getFileNamespace(filePath string) string {
+	parts := strings.Split(filePath, "/")
+	if len(parts) < 3 || parts[0] != NamespaceDir {
+		return ""
+	}
+	return parts[1]
+}
+
+// namespaceOptions get vector store options to search or add documents of namespace
+func namespaceOptions(namespace string) []vectorstores.Option {
+	switch *conf.RagConfInfo.VectorDBType {
+	case "weaviate":
+		return []vectorstores.Option{vectorstores.WithNameSpace(namespace)}
+	case "milvus":
+		return []vectorstores.Option{vectorstores.WithFilters(fmt.Sprintf(`meta["%s"] == "%s"`, namespaceKey, namespace))}
+	default:
+		return []vectorstores.Option{vectorstores.WithFilters(map[string]any{namespaceKey: namespace})}
+	}
+}
+
+// Retriever search documents of global knowledge base and namespace of chat
+type Retriever struct {
+	Store        vectorstores.VectorStore
+	NumDocuments int
+	Namespace    string
+	
+	// ScoreThreshold vector documents with lower score are dropped, 0 means no threshold
+	ScoreThreshold float32
+	// Hybrid search keyword index too, vector and keyword results are fused by reciprocal rank fusion
+	Hybrid bool
+	// Reranker rerank fused documents before top documents are picked, nil means no rerank
+	Reranker Reranker
+	
+	// Docs documents found by last search
+	Docs []schema.Document
+}
+
+var _ schema.Retriever = (*Retriever)(nil)
+
+// NewRetriever create retriever of namespace, empty namespace only search global documents
+func NewRetriever(store vectorstores.VectorStore, numDocuments int, namespace string) *Retriever {
+	return &Retriever{
+		Store:        store,
+		NumDocuments: numDocuments,
+		Namespace:    CleanNamespace(namespace),
+	}
+}
+
+// NewConfRetriever create retriever of namespace with top k, score threshold, hybrid search and rerank
+// of rag conf, options are used by llm reranker
+func NewConfRetriever(namespace string, options ...llm.Option) *Retriever {
+	r := NewRetriever(conf.RagConfInfo.Store, *conf.RagConfInfo.RagTopK, namespace)
+	r.ScoreThreshold = float32(*conf.RagConfInfo.RagScoreThreshold)
+	r.Hybrid = *conf.RagConfInfo.HybridSearch
+	r.Reranker = NewReranker(options...)
+	return r
+}
+
+// GetRelevantDocuments search global and namespace documents, merge them by score. More candidates are
+// searched when keyword results are fused or documents are reranked.
+func (r *Retriever) GetRelevantDocuments(ctx context.Context, query string) ([]schema.Document, error) {
+	num := r.NumDocuments
+	if r.Hybrid || r.Reranker != nil {
+		num *= candidateFactor
+	}
+	
+	docs, err := r.vectorSearch(ctx, query, num)
+	if err != nil {
+		return nil, err
+	}
+	
+	if r.Hybrid {
+		namespaces := []string{""}
+		if r.Namespace != "" {
+			namespaces = append(namespaces, r.Namespace)
+		}
+		docs = reciprocalRankFusion(docs, keywords.Search(query, num, namespaces...))
+	}
+	
+	if r.Reranker != nil && len(docs) > 1 {
+		rerankDocs, err := r.Reranker.Rerank(ctx, query, docs)
+		if err != nil {
+			logger.Warn("rerank documents fail", "err", err)
+		} else {
+			docs = rerankDocs
+		}
+	}
+	
+	if r.NumDocuments > 0 && len(docs) > r.NumDocuments {
+		docs = docs[:r.NumDocuments]
+	}
+	r.Docs = docs
+	return docs, nil
+}
+	
+// vectorSearch search global and namespace documents in vector db, documents lower than threshold are dropped
+func (r *Retriever) vectorSearch(ctx context.Context, query string, num int) ([]schema.Document, error) {
+	docs, err := r.Store.SimilaritySearch(ctx, query, num, namespaceOptions("")...)
+	if err != nil {
+		return nil, err
+	}
+	
+	if r.Namespace != "" {
+		namespaceDocs, err := r.Store.SimilaritySearch(ctx, query, num, namespaceOptions(r.Namespace)...)
+		if err != nil {
+			return nil, err
+		}
+	
+		docs = append(docs, namespaceDocs...)
+		sort.SliceStable(docs, func(i, j int) bool {
+			return docs[i].Score > docs[j].Score
+		})
+	}
+	
+	res := make([]schema.Document, 0, len(docs))
+	for _, doc := range docs {
+		if r.ScoreThreshold > 0 && doc.Score < r.ScoreThreshold {
+			continue
+		}
+		res = append(res, doc)
+	}
+	if num > 0 && len(res) > num {
+		res = res[:num]
+	}
+	return res, nil
+}
+
+// SaveNamespaceFile save file into namespace dir and embed it, return relative path and number of chunks
+func SaveNamespaceFile(ctx context.Context, namespace, fileName string, data []byte) (string, int, error) {
+	fileName = filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "" || fileName == "." || fileName == "/" || strings.HasPrefix(fileName, ".") {
+		return "", 0, FileNameEmptyErr
+	}
+	
+	filePath := fileName
+	if namespace = CleanNamespace(namespace); namespace != "" {
+		filePath = path.Join(NamespaceDir, namespace, fileName)
+	}
+	
+	return saveKnowledgeFile(ctx, filePath, data)
+}
+
+// saveKnowledgeFile write file into knowledge path and embed it, old vectors of same file are deleted
+func saveKnowledgeFile(ctx context.Context, filePath string, data []byte) (string, int, error) {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	// file uploaded again, delete old vectors
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	for _, ragFile := range ragFiles {
+		if err = deleteRagFile(ctx, ragFile); err != nil {
+			return "", 0, err
+		}
+	}
+	
+	fullPath := filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath))
+	err = os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err != nil {
+		return "", 0, err
+	}
+	err = os.WriteFile(fullPath, data, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	
+	docs, err := handleFiles(ctx, []string{filePath})
+	if err != nil {
+		return "", 0, err
+	}
+	if len(docs) > 0 {
+		insertVectorDb(ctx, docs)
+	}
+	
+	logger.Info("save knowledge file", "file", filePath, "chunks", len(docs))
+	return filePath, len(docs), nil
+}
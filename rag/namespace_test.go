@@ -0,0 +1,74 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+)
+
+func TestNamespace(t *testing.T) {
+	assert.Equal(t, "-1001", CleanNamespace("-1001"))
+	assert.Equal(t, "a_b_c", CleanNamespace("a/b c"))
+	assert.Equal(t, "", CleanNamespace(".."))
+	assert.Equal(t, "team_a", getFileNamespace("namespaces/team_a/doc/a.md"))
+	assert.Equal(t, "", getFileNamespace("namespaces/a.md"))
+	assert.Equal(t, "", getFileNamespace("doc/a.md"))
+}
+
+func TestNamespaceRetriever(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "global.txt"), []byte("apple"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, NamespaceDir, "team_a"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, NamespaceDir, "team_a", "a.txt"), []byte("apple car"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	
+	filePath, chunks, err := SaveNamespaceFile(ctx, "team/b", "../b.txt", []byte("apple dog"))
+	assert.Nil(t, err)
+	assert.Equal(t, "namespaces/team_b/b.txt", filePath)
+	assert.Equal(t, 1, chunks)
+	assert.Equal(t, 3, store.Count())
+	
+	// upload again, old vectors are deleted
+	_, chunks, err = SaveNamespaceFile(ctx, "team/b", "b.txt", []byte("apple dog dog"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, chunks)
+	assert.Equal(t, 3, store.Count())
+	assert.True(t, isFileIndexed(filepath.Join(dir, NamespaceDir, "team_b", "b.txt")))
+	
+	ragFiles, err := db.GetRagFileByFileName("namespaces/team_b/b.txt")
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	
+	_, _, err = SaveNamespaceFile(ctx, "team_b", "", []byte("dog"))
+	assert.ErrorIs(t, err, FileNameEmptyErr)
+	
+	// only global documents
+	docs, err := NewRetriever(store, 3, "").GetRelevantDocuments(ctx, "apple")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "global.txt", docs[0].Metadata["file_path"])
+	
+	// global and team_a documents, team_b is invisible
+	retriever := NewRetriever(store, 3, "team_a")
+	docs, err = retriever.GetRelevantDocuments(ctx, "apple car")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "namespaces/team_a/a.txt", docs[0].Metadata["file_path"])
+	assert.Equal(t, "team_a", docs[0].Metadata["namespace"])
+	assert.Equal(t, "global.txt", docs[1].Metadata["file_path"])
+	assert.Equal(t, docs, retriever.Docs)
+	
+	docs, err = NewRetriever(store, 1, "team_b").GetRelevantDocuments(ctx, "dog")
+	assert.Nil(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, "namespaces/team_b/b.txt", docs[0].Metadata["file_path"])
+}
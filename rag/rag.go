@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,6 +14,11 @@ import (
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 	"github.com/milvus-io/milvus-sdk-go/v2/entity"
 	db_weaviate "github.com/weaviate/weaviate-go-client/v4/weaviate"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
 	"github.com/yincongcyincong/langchaingo/documentloaders"
 	"github.com/yincongcyincong/langchaingo/embeddings"
 	"github.com/yincongcyincong/langchaingo/llms"
@@ -19,14 +26,9 @@ import (
 	"github.com/yincongcyincong/langchaingo/llms/googleai"
 	"github.com/yincongcyincong/langchaingo/llms/openai"
 	"github.com/yincongcyincong/langchaingo/schema"
-	"github.com/yincongcyincong/langchaingo/textsplitter"
+	"github.com/yincongcyincong/langchaingo/vectorstores"
 	"github.com/yincongcyincong/langchaingo/vectorstores/milvus"
 	"github.com/yincongcyincong/langchaingo/vectorstores/weaviate"
-	"github.com/yincongcyincong/MuseBot/conf"
-	"github.com/yincongcyincong/MuseBot/db"
-	"github.com/yincongcyincong/MuseBot/llm"
-	"github.com/yincongcyincong/MuseBot/logger"
-	"github.com/yincongcyincong/MuseBot/utils"
 	"gopkg.in/fsnotify.v1"
 )
 
@@ -36,6 +38,9 @@ const (
 
 type Rag struct {
 	LLM *llm.LLM
+	
+	// Retriever retriever of chain, used to check whether documents are found
+	Retriever *Retriever
 }
 
 func NewRag(options ...llm.Option) *Rag {
@@ -59,9 +64,15 @@ func (l *Rag) GenerateContent(ctx context.Context, messages []llms.MessageConten
 		opt(opts)
 	}
 	
-	doc, err := conf.RagConfInfo.Store.SimilaritySearch(ctx, l.LLM.Content, 3)
-	if err != nil {
-		logger.Error("request vector db fail", "err", err)
+	var doc []schema.Document
+	var err error
+	if l.Retriever != nil {
+		doc = l.Retriever.Docs
+	} else {
+		doc, err = conf.RagConfInfo.Store.SimilaritySearch(ctx, l.LLM.Content, *conf.RagConfInfo.RagTopK)
+		if err != nil {
+			logger.Error("request vector db fail", "err", err)
+		}
 	}
 	if len(doc) != 0 {
 		tmpContent := ""
@@ -106,6 +117,10 @@ func InitRag() {
 		conf.RagConfInfo.Embedder, err = initGeminiEmbedding(ctx)
 	case "ernie":
 		conf.RagConfInfo.Embedder, err = initErnieEmbedding()
+	case "ollama":
+		conf.RagConfInfo.Embedder, err = initOllamaEmbedding()
+	case "openai_compatible":
+		conf.RagConfInfo.Embedder, err = initOpenAICompatibleEmbedding()
 	default:
 		logger.Error("embedding type not exist", "embedding type", *conf.RagConfInfo.EmbeddingType)
 		return
@@ -135,9 +150,10 @@ func InitRag() {
 		conf.RagConfInfo.Store, err = milvus.New(ctx, clientConf,
 			milvus.WithCollectionName(*conf.RagConfInfo.Space),
 			milvus.WithEmbedder(conf.RagConfInfo.Embedder),
-			milvus.WithIndex(idx),
-			milvus.WithDropOld())
+			milvus.WithIndex(idx))
 		conf.RagConfInfo.MilvusClient, _ = client.NewClient(ctx, clientConf)
+	case "local":
+		conf.RagConfInfo.Store, err = NewLocalStore(conf.RagConfInfo.Embedder)
 	case "weaviate":
 		conf.RagConfInfo.Store, err = weaviate.New(
 			weaviate.WithEmbedder(conf.RagConfInfo.Embedder),
@@ -159,82 +175,71 @@ func InitRag() {
 		return
 	}
 	
-	docs, err := handleKnowledgeBase(ctx)
+	_, err = ReconcileKnowledgeBase(ctx)
 	if err != nil {
-		logger.Error("get doc fail", "err", err)
+		logger.Error("reconcile knowledge base fail", "err", err)
 		return
 	}
 	
-	if len(docs) > 0 {
-		insertVectorDb(ctx, docs)
-	}
-	
 	go CheckDirChange()
 	
 }
 
 func insertVectorDb(ctx context.Context, docs []schema.Document) {
-	ids, err := conf.RagConfInfo.Store.AddDocuments(ctx, docs)
+	// weaviate save namespace by option, so documents of different namespaces are added separately
+	namespaceDocs := make(map[string][]schema.Document)
+	for _, doc := range docs {
+		namespace := metadataString(doc.Metadata[namespaceKey])
+		namespaceDocs[namespace] = append(namespaceDocs[namespace], doc)
+	}
+	
+	for namespace, nsDocs := range namespaceDocs {
+		var options []vectorstores.Option
+		if *conf.RagConfInfo.VectorDBType == "weaviate" {
+			options = namespaceOptions(namespace)
+		}
+		insertNamespaceDocs(ctx, nsDocs, options...)
+	}
+}
+
+func insertNamespaceDocs(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) {
+	ids, err := conf.RagConfInfo.Store.AddDocuments(ctx, docs, options...)
 	if err != nil {
 		logger.Error("get save doc fail", "err", err)
 		return
 	}
+	if hybridEnabled() {
+		keywords.Add(docs)
+	}
 	
 	fileVectorIds := make(map[string]string)
 	for i := range docs {
-		fileMd5 := docs[i].Metadata["file_md5"].(string)
-		fileVectorIds[fileMd5] += ids[i] + ","
+		filePath := docs[i].Metadata["file_path"].(string)
+		fileVectorIds[filePath] += ids[i] + ","
 	}
 	
-	for fileMd5, vectorIds := range fileVectorIds {
-		err = db.UpdateVectorIdByFileMd5(fileMd5, strings.TrimRight(vectorIds, ","))
+	for filePath, vectorIds := range fileVectorIds {
+		err = db.UpdateVectorIdByFileName(filePath, strings.TrimRight(vectorIds, ","))
 		if err != nil {
 			logger.Error("update vector id fail", "err", err)
 		}
 	}
 }
 
-func handleKnowledgeBase(ctx context.Context) ([]schema.Document, error) {
-	entries, err := os.ReadDir(*conf.RagConfInfo.KnowledgePath)
-	if err != nil {
-		return nil, err
-	}
-	
-	return handleEntry(ctx, entries)
-	
-}
-
-func handleEntry(ctx context.Context, entries []os.DirEntry) ([]schema.Document, error) {
-	var err error
+// handleFiles load and split knowledge files, filePaths are relative to knowledge path
+func handleFiles(ctx context.Context, filePaths []string) ([]schema.Document, error) {
 	res := make([]schema.Document, 0)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			var docs []schema.Document
-			switch {
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".txt"):
-				docs, err = handleTextDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle text doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".pdf"):
-				docs, err = handlePDFDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle pdf doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".csv"):
-				docs, err = handleCSVDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle csv doc fail", "err", err)
-				}
-			case strings.HasSuffix(strings.ToLower(entry.Name()), ".html"):
-				docs, err = handleHTMLDoc(ctx, entry)
-				if err != nil {
-					logger.Error("handle html doc fail", "err", err)
-				}
-			}
-			if len(docs) > 0 {
-				res = append(res, docs...)
-			}
+	for _, filePath := range filePaths {
+		if !IsSupportedFile(filePath) {
+			continue
+		}
+		
+		docs, err := handleDoc(ctx, filePath)
+		if err != nil {
+			logger.Error("handle doc fail", "file", filePath, "err", err)
+		}
+		if len(docs) > 0 {
+			res = append(res, docs...)
 		}
 	}
 	
@@ -290,8 +295,8 @@ func initGeminiEmbedding(ctx context.Context) (embeddings.Embedder, error) {
 	return embedder, err
 }
 
-func getFileResource(entry os.DirEntry) (*os.File, string, error) {
-	fullPath := filepath.Join(*conf.RagConfInfo.KnowledgePath, entry.Name())
+func getFileResource(filePath string) (*os.File, string, error) {
+	fullPath := filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath))
 	
 	fileMd5, err := utils.FileToMd5(fullPath)
 	if err != nil {
@@ -299,48 +304,37 @@ func getFileResource(entry os.DirEntry) (*os.File, string, error) {
 		return nil, "", err
 	}
 	
-	fileInfos, err := db.GetRagFileByFileMd5(fileMd5)
+	fileInfos, err := db.GetRagFileByFileName(filePath)
 	if err != nil {
 		logger.Error("get file from db fail", "err", err)
 		return nil, "", err
 	}
 	
-	if len(fileInfos) > 0 {
-		logger.Info("file exist", "path", fullPath)
-		return nil, "", nil
+	for _, fileInfo := range fileInfos {
+		if fileInfo.FileMd5 == fileMd5 {
+			logger.Info("file exist", "path", fullPath)
+			return nil, "", nil
+		}
 	}
 	
-	err = db.DeleteRagFileByFileName(entry.Name())
+	err = db.DeleteRagFileByFileName(filePath)
 	if err != nil {
 		logger.Error("delete file from db fail", "err", err)
 	}
 	
-	_, err = db.InsertRagFile(entry.Name(), fileMd5)
+	_, err = db.InsertRagFile(filePath, fileMd5)
 	if err != nil {
 		logger.Error("insert rag file fail", "err", err)
 	}
+	restoreFileOwners(getFileOwners(fileInfos))
 	
 	f, err := os.Open(fullPath)
 	return f, fileMd5, err
 }
 
-func handleTextDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
-	if err != nil {
-		logger.Error("read file fail", "err", err)
-		return nil, err
-	}
-	if f == nil {
-		return nil, nil
-	}
-	defer f.Close()
-	
-	loader := documentloaders.NewText(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
-}
-
-func handlePDFDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+// handleDoc load and split file which is new or changed, nil is returned if file is indexed
+func handleDoc(ctx context.Context, filePath string) ([]schema.Document, error) {
+	f, fMd5, err := getFileResource(filePath)
 	if err != nil {
 		logger.Error("read file fail", "err", err)
 		return nil, err
@@ -350,61 +344,85 @@ func handlePDFDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, er
 	}
 	defer f.Close()
 	
-	finfo, err := f.Stat()
+	loader, err := newFileLoader(f, filePath)
 	if err != nil {
-		logger.Error("get file stat fail", "err", err)
 		return nil, err
 	}
-	loader := documentloaders.NewPDF(f, finfo.Size())
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
+	return saveDocIntoStore(ctx, loader, fMd5, filePath)
 }
 
-func handleCSVDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+// loadDoc load and split file without checking rag_files, used to rebuild keyword index
+func loadDoc(ctx context.Context, filePath string) ([]schema.Document, error) {
+	fullPath := filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath))
+	fMd5, err := utils.FileToMd5(fullPath)
 	if err != nil {
-		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
+	
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
 	}
 	defer f.Close()
 	
-	loader := documentloaders.NewCSV(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
-}
-
-func handleHTMLDoc(ctx context.Context, entry os.DirEntry) ([]schema.Document, error) {
-	f, fMd5, err := getFileResource(entry)
+	loader, err := newFileLoader(f, filePath)
 	if err != nil {
-		logger.Error("read file fail", "err", err)
 		return nil, err
 	}
-	if f == nil {
-		return nil, nil
+	return saveDocIntoStore(ctx, loader, fMd5, filePath)
+}
+
+// newFileLoader get document loader by file extension
+func newFileLoader(f *os.File, filePath string) (documentloaders.Loader, error) {
+	lowerPath := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(lowerPath, ".csv"):
+		return documentloaders.NewCSV(f), nil
+	case strings.HasSuffix(lowerPath, ".html"):
+		return documentloaders.NewHTML(f), nil
+	case strings.HasSuffix(lowerPath, ".json"), strings.HasSuffix(lowerPath, ".jsonl"):
+		return newJSONLoader(f, strings.HasSuffix(lowerPath, ".jsonl")), nil
+	case strings.HasSuffix(lowerPath, ".pdf"), strings.HasSuffix(lowerPath, ".docx"),
+		strings.HasSuffix(lowerPath, ".epub"):
+		finfo, err := f.Stat()
+		if err != nil {
+			logger.Error("get file stat fail", "err", err)
+			return nil, err
+		}
+		if strings.HasSuffix(lowerPath, ".pdf") {
+			return documentloaders.NewPDF(f, finfo.Size()), nil
+		}
+		if strings.HasSuffix(lowerPath, ".docx") {
+			return newDocxLoader(f, finfo.Size()), nil
+		}
+		return newEpubLoader(f, finfo.Size()), nil
+	default:
+		// txt, markdown and source code
+		return documentloaders.NewText(f), nil
 	}
-	defer f.Close()
-	
-	loader := documentloaders.NewHTML(f)
-	return saveDocIntoStore(ctx, loader, fMd5, entry)
 }
 
-func saveDocIntoStore(ctx context.Context, loader documentloaders.Loader, fMd5 string, entry os.DirEntry) ([]schema.Document, error) {
-	splitter := textsplitter.NewRecursiveCharacter(
-		textsplitter.WithChunkSize(*conf.RagConfInfo.ChunkSize),
-		textsplitter.WithChunkOverlap(*conf.RagConfInfo.ChunkOverlap),
-		textsplitter.WithSeparators(conf.DefaultSpliter),
-	)
-	
-	docs, err := loader.LoadAndSplit(ctx, splitter)
+func saveDocIntoStore(ctx context.Context, loader documentloaders.Loader, fMd5 string, filePath string) ([]schema.Document, error) {
+	docs, err := loader.LoadAndSplit(ctx, getSplitter(filePath))
 	if err != nil {
 		logger.Error("get rag docs fail: %v", err)
 		return nil, err
 	}
 	
-	for _, doc := range docs {
-		doc.Metadata["file_name"] = entry.Name()
+	language := getCodeLanguage(filePath)
+	sourceURL := getSourceURL(filePath)
+	for i, doc := range docs {
+		doc.Metadata["file_name"] = path.Base(filePath)
+		doc.Metadata["file_path"] = filePath
 		doc.Metadata["file_md5"] = fMd5
+		doc.Metadata["chunk_index"] = i
+		doc.Metadata[namespaceKey] = getFileNamespace(filePath)
+		if language != "" {
+			doc.Metadata["language"] = language
+		}
+		if sourceURL != "" {
+			doc.Metadata["source_url"] = sourceURL
+		}
 	}
 	
 	return docs, nil
@@ -424,8 +442,8 @@ func CheckDirChange() {
 	}
 	defer watcher.Close()
 	
-	// 监控当前目录
-	err = watcher.Add(*conf.RagConfInfo.KnowledgePath)
+	// 监控知识库目录及所有子目录
+	err = watchDir(watcher, *conf.RagConfInfo.KnowledgePath)
 	if err != nil {
 		logger.Error("add watcher fail", "err", err)
 		return
@@ -437,7 +455,7 @@ func CheckDirChange() {
 			if !ok {
 				return
 			}
-			insertNewDoc(event)
+			insertNewDoc(watcher, event)
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				logger.Error("watcher channel closed")
@@ -449,59 +467,126 @@ func CheckDirChange() {
 	
 }
 
-func insertNewDoc(event fsnotify.Event) {
+// watchDir add dir and all sub dirs into watcher
+func watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if isHiddenDir(path, dir) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func insertNewDoc(watcher *fsnotify.Watcher, event fsnotify.Event) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
+	
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
 	switch {
 	case event.Op&fsnotify.Create == fsnotify.Create:
 		logger.Info("rag dir changed", "event", event.Name, "op", "create")
+		if fileInfo, err := os.Stat(event.Name); err == nil && fileInfo.IsDir() {
+			err = watchDir(watcher, event.Name)
+			if err != nil {
+				logger.Error("add watcher fail", "dir", event.Name, "err", err)
+			}
+		}
 		InsertDoc(ctx, event)
 	case event.Op&fsnotify.Write == fsnotify.Write:
 		logger.Info("rag dir changed", "event", event.Name, "op", "write")
+		if isFileIndexed(event.Name) {
+			return
+		}
+		owners := getPathOwners(event.Name)
 		DeleteDoc(ctx, event)
 		InsertDoc(ctx, event)
-	case event.Op&fsnotify.Remove == fsnotify.Remove:
+		restoreFileOwners(owners)
+	case event.Op&fsnotify.Remove == fsnotify.Remove, event.Op&fsnotify.Rename == fsnotify.Rename:
 		logger.Info("rag dir changed", "event", event.Name, "op", "remove")
 		DeleteDoc(ctx, event)
 	}
 	
 }
 
+// getPathOwners get owners of file changed in knowledge path
+func getPathOwners(fullPath string) map[string]string {
+	filePath, err := getRelativePath(fullPath)
+	if err != nil {
+		return nil
+	}
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	if err != nil {
+		logger.Error("get file db info fail", "err", err)
+		return nil
+	}
+	return getFileOwners(ragFiles)
+}
+
+// DeleteDoc delete vectors of removed file, or all files under removed dir
 func DeleteDoc(ctx context.Context, event fsnotify.Event) {
-	fileName := filepath.Base(event.Name)
-	fileDbInfo, err := db.GetRagFileByFileName(fileName)
+	filePath, err := getRelativePath(event.Name)
+	if err != nil {
+		logger.Error("get relative path fail", "err", err)
+		return
+	}
+	
+	ragFiles, err := db.GetRagFileByFileName(filePath)
 	if err != nil {
 		logger.Error("get file db info fail", "err", err)
 		return
 	}
-	if fileDbInfo != nil && len(fileDbInfo) > 0 {
-		err = DeleteStoreData(ctx, fileDbInfo[0].VectorId)
+	
+	if len(ragFiles) == 0 {
+		// path may be a dir, delete all files under it
+		allFiles, err := db.GetRagFiles()
 		if err != nil {
-			logger.Error("delete doc fail", "err", err)
+			logger.Error("get rag files fail", "err", err)
 			return
 		}
-		err = db.DeleteRagFileByFileName(fileDbInfo[0].FileName)
-		if err != nil {
-			logger.Error("delete doc in db fail", "err", err)
-			return
+		for _, ragFile := range allFiles {
+			if strings.HasPrefix(ragFile.FileName, filePath+"/") {
+				ragFiles = append(ragFiles, ragFile)
+			}
 		}
 	}
+	
+	for _, ragFile := range ragFiles {
+		deleteRagFile(ctx, ragFile)
+	}
 }
 
+// InsertDoc embed created file, or all files under created dir
 func InsertDoc(ctx context.Context, event fsnotify.Event) {
 	fileInfo, err := os.Stat(event.Name)
 	if err != nil {
 		logger.Error("stat file fail", "err", err)
 		return
 	}
-	entry, err := findDirEntry(*conf.RagConfInfo.KnowledgePath, fileInfo.Name())
+	
+	var filePaths []string
+	if fileInfo.IsDir() {
+		filePaths, err = listKnowledgeFiles(event.Name)
+	} else {
+		var filePath string
+		filePath, err = getRelativePath(event.Name)
+		filePaths = []string{filePath}
+	}
 	if err != nil {
-		logger.Error("find dir entry fail", "err", err)
+		logger.Error("get knowledge files fail", "err", err)
 		return
 	}
-	docs, err := handleEntry(ctx, []os.DirEntry{entry})
+	
+	docs, err := handleFiles(ctx, filePaths)
 	if err != nil {
-		logger.Error("handle entry fail", "err", err)
+		logger.Error("handle files fail", "err", err)
 		return
 	}
 	if len(docs) > 0 {
@@ -509,40 +594,106 @@ func InsertDoc(ctx context.Context, event fsnotify.Event) {
 	}
 }
 
-func findDirEntry(path string, filename string) (os.DirEntry, error) {
-	entries, err := os.ReadDir(path)
+// isFileIndexed check file content is not changed since last embedding, such as file saved by SaveNamespaceFile
+func isFileIndexed(fullPath string) bool {
+	filePath, err := getRelativePath(fullPath)
 	if err != nil {
-		return nil, err
+		return false
+	}
+	
+	fileMd5, err := utils.FileToMd5(fullPath)
+	if err != nil {
+		return false
 	}
 	
-	for _, entry := range entries {
-		if entry.Name() == filename {
-			return entry, nil
+	ragFiles, err := db.GetRagFileByFileName(filePath)
+	if err != nil {
+		return false
+	}
+	for _, ragFile := range ragFiles {
+		if ragFile.FileMd5 == fileMd5 && ragFile.VectorId != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// listKnowledgeFiles get all files under dir recursively, return paths relative to knowledge path
+func listKnowledgeFiles(dir string) ([]string, error) {
+	filePaths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if isHiddenDir(path, dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+	
+		filePath, err := getRelativePath(path)
+		if err != nil {
+			return err
 		}
+		filePaths = append(filePaths, filePath)
+		return nil
+	})
+	
+	return filePaths, err
+}
+
+// getRelativePath get slash separated path relative to knowledge path, it is the key of rag_files
+func getRelativePath(fullPath string) (string, error) {
+	relPath, err := filepath.Rel(*conf.RagConfInfo.KnowledgePath, fullPath)
+	if err != nil {
+		return "", err
 	}
-	return nil, fmt.Errorf("file not exist: %s", filename)
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file not in knowledge path: %s", fullPath)
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// isHiddenDir skip dirs like .git, root dir is never skipped
+func isHiddenDir(path, root string) bool {
+	return filepath.Clean(path) != filepath.Clean(root) && strings.HasPrefix(filepath.Base(path), ".")
 }
 
+// DeleteStoreData delete vectors from vector store, errors of all vectors are returned
 func DeleteStoreData(ctx context.Context, vectorIds string) error {
-	var err error
+	var errs []error
 	switch *conf.RagConfInfo.VectorDBType {
 	case "weaviate":
 		for _, vectorId := range strings.Split(vectorIds, ",") {
-			err = conf.RagConfInfo.WeaviateClient.Data().Deleter().
+			err := conf.RagConfInfo.WeaviateClient.Data().Deleter().
 				WithClassName(weaviateIndexName).
 				WithID(vectorId).
 				Do(ctx)
 			if err != nil {
-				logger.Error("delete store data fail", "err", err)
+				errs = append(errs, fmt.Errorf("delete vector %s fail: %w", vectorId, err))
 			}
 		}
 	
 	case "milvus":
 		for _, vectorId := range strings.Split(vectorIds, ",") {
 			expr := fmt.Sprintf(`pk == %s`, vectorId)
-			err = conf.RagConfInfo.MilvusClient.Delete(ctx, *conf.RagConfInfo.Space, "", expr)
+			err := conf.RagConfInfo.MilvusClient.Delete(ctx, *conf.RagConfInfo.Space, "", expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("delete vector %s fail: %w", vectorId, err))
+			}
+		}
+		
+	case "local":
+		if store, ok := conf.RagConfInfo.Store.(*LocalStore); ok {
+			if err := store.Delete(ctx, strings.Split(vectorIds, ",")); err != nil {
+				errs = append(errs, err)
+			}
 		}
 	}
 	
-	return nil
+	return errors.Join(errs...)
 }
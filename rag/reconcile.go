@@ -0,0 +1,180 @@
+package rag
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+)
+
+var (
+	reconcileLock sync.Mutex
+)
+
+// ReconcileResult summary of knowledge base reconcile
+type ReconcileResult struct {
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	Chunks    int `json:"chunks"`
+}
+
+// ReconcileKnowledgeBase compare rag_files with knowledge files recursively, only embed new or changed
+// files and delete vectors of removed files.
+func ReconcileKnowledgeBase(ctx context.Context) (*ReconcileResult, error) {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	return reconcileKnowledgeBase(ctx)
+}
+
+// ReindexAll delete all vectors of knowledge files and embed them again
+func ReindexAll(ctx context.Context) (*ReconcileResult, error) {
+	reconcileLock.Lock()
+	defer reconcileLock.Unlock()
+	
+	ragFiles, err := db.GetRagFiles()
+	if err != nil {
+		logger.Error("get rag files fail", "err", err)
+		return nil, err
+	}
+	
+	owners := getFileOwners(ragFiles)
+	for _, ragFile := range ragFiles {
+		deleteRagFile(ctx, ragFile)
+	}
+	
+	res, err := reconcileKnowledgeBase(ctx)
+	restoreFileOwners(owners)
+	return res, err
+}
+
+func reconcileKnowledgeBase(ctx context.Context) (*ReconcileResult, error) {
+	filePaths, err := listKnowledgeFiles(*conf.RagConfInfo.KnowledgePath)
+	if err != nil {
+		logger.Error("read knowledge dir fail", "err", err)
+		return nil, err
+	}
+	
+	ragFiles, err := db.GetRagFiles()
+	if err != nil {
+		logger.Error("get rag files fail", "err", err)
+		return nil, err
+	}
+	
+	owners := getFileOwners(ragFiles)
+	fileEntries := make(map[string]bool)
+	for _, filePath := range filePaths {
+		fileEntries[filePath] = true
+	}
+	
+	res := new(ReconcileResult)
+	unchanged := make(map[string]bool)
+	changed := make(map[string]bool)
+	for _, ragFile := range ragFiles {
+		if unchanged[ragFile.FileName] || changed[ragFile.FileName] {
+			// duplicate record of same file, only keep one
+			deleteRagFile(ctx, ragFile)
+			continue
+		}
+		
+		if _, ok := fileEntries[ragFile.FileName]; !ok {
+			logger.Info("knowledge file removed", "file", ragFile.FileName)
+			deleteRagFile(ctx, ragFile)
+			res.Removed++
+			continue
+		}
+		
+		fileMd5, err := utils.FileToMd5(filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(ragFile.FileName)))
+		if err != nil {
+			logger.Error("file to md5 fail", "file", ragFile.FileName, "err", err)
+			unchanged[ragFile.FileName] = true
+			continue
+		}
+		
+		if fileMd5 == ragFile.FileMd5 && ragFile.VectorId != "" {
+			loadKeywordIndex(ctx, ragFile.FileName, fileMd5)
+			unchanged[ragFile.FileName] = true
+			res.Unchanged++
+			continue
+		}
+		
+		// file changed or embedding failed last time, embed it again
+		logger.Info("knowledge file changed", "file", ragFile.FileName)
+		deleteRagFile(ctx, ragFile)
+		changed[ragFile.FileName] = true
+		res.Changed++
+	}
+	
+	for _, name := range filePaths {
+		if unchanged[name] {
+			continue
+		}
+		
+		docs, err := handleFiles(ctx, []string{name})
+		if err != nil {
+			logger.Error("handle files fail", "file", name, "err", err)
+			continue
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		
+		insertVectorDb(ctx, docs)
+		res.Chunks += len(docs)
+		if !changed[name] {
+			res.Added++
+		}
+	}
+	restoreFileOwners(owners)
+	
+	logger.Info("knowledge base reconciled", "added", res.Added, "changed", res.Changed,
+		"removed", res.Removed, "unchanged", res.Unchanged, "chunks", res.Chunks)
+	return res, nil
+}
+
+// getFileOwners get owners of rag files by file name, owners are set again after files are embedded again
+func getFileOwners(ragFiles []*db.RagFiles) map[string]string {
+	owners := make(map[string]string)
+	for _, ragFile := range ragFiles {
+		if ragFile.Owner != "" {
+			owners[ragFile.FileName] = ragFile.Owner
+		}
+	}
+	return owners
+}
+
+// restoreFileOwners set owners of files which are embedded again, removed files are skipped
+func restoreFileOwners(owners map[string]string) {
+	for fileName, owner := range owners {
+		err := db.UpdateOwnerByFileName(fileName, owner)
+		if err != nil {
+			logger.Error("update rag file owner fail", "file", fileName, "err", err)
+		}
+	}
+}
+
+// deleteRagFile delete vectors of file and mark file record deleted, record is kept if vectors aren't deleted
+// so that they can be deleted again
+func deleteRagFile(ctx context.Context, ragFile *db.RagFiles) error {
+	keywords.RemoveFile(ragFile.FileName)
+	
+	if ragFile.VectorId != "" {
+		err := DeleteStoreData(ctx, ragFile.VectorId)
+		if err != nil {
+			logger.Error("delete store data fail", "file", ragFile.FileName, "err", err)
+			return err
+		}
+	}
+	
+	err := db.DeleteRagFileById(ragFile.ID)
+	if err != nil {
+		logger.Error("delete rag file fail", "file", ragFile.FileName, "err", err)
+	}
+	return err
+}
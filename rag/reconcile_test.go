@@ -0,0 +1,131 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/db"
+	"gopkg.in/fsnotify.v1"
+)
+
+func initTestRagConf(t *testing.T) string {
+	dir := t.TempDir()
+	vectorDBType := "local"
+	chunkSize := 100
+	chunkOverlap := 0
+	conf.RagConfInfo.KnowledgePath = &dir
+	conf.RagConfInfo.VectorDBType = &vectorDBType
+	conf.RagConfInfo.ChunkSize = &chunkSize
+	conf.RagConfInfo.ChunkOverlap = &chunkOverlap
+	
+	initTestDB(t)
+	store, err := NewLocalStore(&fakeEmbedder{})
+	assert.Nil(t, err)
+	conf.RagConfInfo.Store = store
+	return dir
+}
+
+func TestReconcileKnowledgeBase(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "apple.txt"), []byte("apple banana"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "car.txt"), []byte("car dog"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "ignore.bin"), []byte("car dog"), 0644))
+	
+	res, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Added: 2, Chunks: 2}, res)
+	assert.Equal(t, 2, store.Count())
+	
+	// nothing changed, nothing is embedded again
+	res, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Unchanged: 2}, res)
+	assert.Equal(t, 2, store.Count())
+	
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "apple.txt"), []byte("apple apple"), 0644))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "car.txt")))
+	res, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Changed: 1, Removed: 1, Chunks: 1}, res)
+	assert.Equal(t, 1, store.Count())
+	
+	ragFiles, err := db.GetRagFiles()
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "apple.txt", ragFiles[0].FileName)
+	
+	assert.Nil(t, db.UpdateOwnerByFileName("apple.txt", "42"))
+	res, err = ReindexAll(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Added: 1, Chunks: 1}, res)
+	assert.Equal(t, 1, store.Count())
+	
+	ragFiles, err = db.GetRagFiles()
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "42", ragFiles[0].Owner)
+	
+	// file restored without vector id is embedded again and keeps owner
+	assert.Nil(t, db.UpdateVectorIdByFileName("apple.txt", ""))
+	res, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Changed: 1, Chunks: 1}, res)
+	
+	ragFiles, err = db.GetRagFiles()
+	assert.Nil(t, err)
+	assert.Len(t, ragFiles, 1)
+	assert.Equal(t, "42", ragFiles[0].Owner)
+	assert.NotEmpty(t, ragFiles[0].VectorId)
+}
+
+func TestReconcileSubDir(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	store := conf.RagConfInfo.Store.(*LocalStore)
+	
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("apple"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a", "README.md"), []byte("apple"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "a", "b", "car.txt"), []byte("car dog"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD.txt"), []byte("dog"), 0644))
+	
+	res, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Added: 3, Chunks: 3}, res)
+	
+	docs, err := store.SimilaritySearch(ctx, "car", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "a/b/car.txt", docs[0].Metadata["file_path"])
+	assert.Equal(t, "car.txt", docs[0].Metadata["file_name"])
+	
+	// files with same name and content in different dirs both have vectors
+	for _, name := range []string{"README.md", "a/README.md"} {
+		ragFiles, err := db.GetRagFileByFileName(name)
+		assert.Nil(t, err)
+		assert.Len(t, ragFiles, 1)
+		assert.NotEmpty(t, ragFiles[0].VectorId)
+	}
+	
+	// remove dir, all files under it are deleted
+	assert.Nil(t, os.RemoveAll(filepath.Join(dir, "a")))
+	DeleteDoc(ctx, fsnotify.Event{Name: filepath.Join(dir, "a"), Op: fsnotify.Remove})
+	assert.Equal(t, 1, store.Count())
+	
+	// create dir with files, all files are embedded
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "c", "d"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "c", "d", "dog.txt"), []byte("dog"), 0644))
+	InsertDoc(ctx, fsnotify.Event{Name: filepath.Join(dir, "c"), Op: fsnotify.Create})
+	assert.Equal(t, 2, store.Count())
+	
+	res, err = ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &ReconcileResult{Unchanged: 2}, res)
+}
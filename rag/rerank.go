@@ -0,0 +1,254 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/i18n"
+	"github.com/yincongcyincong/MuseBot/llm"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/utils"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	maxRerankChunkLen = 1000
+)
+
+var (
+	RerankURLEmptyErr = errors.New("rerank url is empty")
+)
+
+// Reranker sort documents by relevance to query, documents not relevant may be dropped
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []schema.Document) ([]schema.Document, error)
+}
+
+// NewReranker create reranker of rerank_type, nil is returned if rerank is disabled
+func NewReranker(options ...llm.Option) Reranker {
+	if conf.RagConfInfo.RerankType == nil {
+		return nil
+	}
+	
+	switch *conf.RagConfInfo.RerankType {
+	case "llm":
+		return &LLMReranker{Options: options}
+	case "cross_encoder":
+		return &CrossEncoderReranker{
+			URL:   *conf.RagConfInfo.RerankURL,
+			Token: *conf.RagConfInfo.RerankToken,
+			Model: *conf.RagConfInfo.RerankModel,
+		}
+	case "":
+		return nil
+	default:
+		logger.Warn("rerank type not exist", "rerank type", *conf.RagConfInfo.RerankType)
+		return nil
+	}
+}
+
+// LLMReranker ask llm to rank chunks by relevance, chunks not in ranking are dropped
+type LLMReranker struct {
+	Options []llm.Option
+}
+
+type llmRerankResult struct {
+	Ranking []int `json:"ranking"` // chunk numbers from the most relevant one
+}
+
+// LLMRerankSchema json schema of llm rerank result
+func LLMRerankSchema() *llm.JsonSchema {
+	return &llm.JsonSchema{
+		Name: "rag_rerank",
+		Type: "object",
+		Properties: map[string]*llm.JsonSchema{
+			"ranking": {
+				Type:        "array",
+				Description: "numbers of relevant chunks ranked from the most relevant one",
+				Items: &llm.JsonSchema{
+					Type: "integer",
+				},
+			},
+		},
+		Required: []string{"ranking"},
+	}
+}
+
+// Rerank implement Reranker
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []schema.Document) ([]schema.Document, error) {
+	chunks := new(strings.Builder)
+	for i, doc := range docs {
+		chunks.WriteString(fmt.Sprintf("[%d]\n%s\n\n", i+1, truncateChunk(doc.PageContent)))
+	}
+	
+	prompt := i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_rerank_prompt", map[string]interface{}{
+		"query":  query,
+		"chunks": chunks.String(),
+	})
+	rerankLLM := llm.NewLLM(append(r.Options, llm.WithContent(prompt))...)
+	if rerankLLM.LLMClient == nil {
+		return nil, errors.New("llm type not exist")
+	}
+	rerankLLM.LLMClient.GetUserMessage(prompt)
+	rerankLLM.LLMClient.GetModel(rerankLLM)
+	
+	res := new(llmRerankResult)
+	_, err := llm.SyncSendJson(ctx, rerankLLM, LLMRerankSchema(), res)
+	if err != nil {
+		return nil, err
+	}
+	
+	return rankDocuments(docs, res.Ranking), nil
+}
+
+// rankDocuments get documents by 1-based ranking, invalid and duplicate numbers are ignored,
+// all documents are kept if ranking is empty
+func rankDocuments(docs []schema.Document, ranking []int) []schema.Document {
+	res := make([]schema.Document, 0, len(ranking))
+	exist := make(map[int]bool)
+	for _, num := range ranking {
+		if num < 1 || num > len(docs) || exist[num] {
+			continue
+		}
+		exist[num] = true
+		res = append(res, docs[num-1])
+	}
+	if len(res) == 0 {
+		return docs
+	}
+	
+	for i := range res {
+		res[i].Score = 1 - float32(i)/float32(len(res))
+	}
+	return res
+}
+
+// CrossEncoderReranker request rerank api of cross encoder model, both jina / cohere style api which returns
+// {"results": [{"index": 0, "relevance_score": 0.9}]} and text-embeddings-inference api which returns
+// [{"index": 0, "score": 0.9}] are supported.
+type CrossEncoderReranker struct {
+	URL   string
+	Token string
+	Model string
+}
+
+type crossEncoderRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	Texts     []string `json:"texts"`
+	TopN      int      `json:"top_n"`
+}
+
+type crossEncoderResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+	Score          float64 `json:"score"`
+}
+
+type crossEncoderResponse struct {
+	Results []*crossEncoderResult `json:"results"`
+}
+
+// Rerank implement Reranker
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, docs []schema.Document) ([]schema.Document, error) {
+	if r.URL == "" {
+		return nil, RerankURLEmptyErr
+	}
+	
+	texts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		texts = append(texts, truncateChunk(doc.PageContent))
+	}
+	body, err := json.Marshal(&crossEncoderRequest{
+		Model:     r.Model,
+		Query:     query,
+		Documents: texts,
+		Texts:     texts,
+		TopN:      len(texts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	
+	resp, err := utils.GetLLMProxyClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank fail: %s %s", resp.Status, string(data))
+	}
+	
+	results, err := parseCrossEncoderResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RelevanceScore > results[j].RelevanceScore
+	})
+	res := make([]schema.Document, 0, len(results))
+	exist := make(map[int]bool)
+	for _, result := range results {
+		if result.Index < 0 || result.Index >= len(docs) || exist[result.Index] {
+			continue
+		}
+		exist[result.Index] = true
+		doc := docs[result.Index]
+		doc.Score = float32(result.RelevanceScore)
+		res = append(res, doc)
+	}
+	return res, nil
+}
+
+func parseCrossEncoderResponse(data []byte) ([]*crossEncoderResult, error) {
+	var results []*crossEncoderResult
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &results)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			result.RelevanceScore = result.Score
+		}
+		return results, nil
+	}
+	
+	resp := new(crossEncoderResponse)
+	err := json.Unmarshal(data, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+func truncateChunk(content string) string {
+	runes := []rune(content)
+	if len(runes) > maxRerankChunkLen {
+		return string(runes[:maxRerankChunkLen])
+	}
+	return content
+}
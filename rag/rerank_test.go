@@ -0,0 +1,64 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestRankDocuments(t *testing.T) {
+	docs := []schema.Document{{PageContent: "a"}, {PageContent: "b"}, {PageContent: "c"}}
+	
+	res := rankDocuments(docs, []int{3, 0, 3, 1, 9})
+	assert.Len(t, res, 2)
+	assert.Equal(t, "c", res[0].PageContent)
+	assert.Equal(t, "a", res[1].PageContent)
+	assert.True(t, res[0].Score > res[1].Score)
+	
+	assert.Equal(t, docs, rankDocuments(docs, nil))
+}
+
+func TestCrossEncoderReranker(t *testing.T) {
+	proxy := ""
+	conf.BaseConfInfo.LLMProxy = &proxy
+	
+	tei := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		req := new(crossEncoderRequest)
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		assert.Equal(t, "E1234", req.Query)
+		assert.Equal(t, []string{"a", "b"}, req.Documents)
+		
+		if tei {
+			w.Write([]byte(`[{"index": 0, "score": 0.1}, {"index": 1, "score": 0.8}]`))
+			return
+		}
+		w.Write([]byte(`{"results": [{"index": 1, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.2}]}`))
+	}))
+	defer server.Close()
+	
+	reranker := &CrossEncoderReranker{URL: server.URL, Token: "token"}
+	docs := []schema.Document{{PageContent: "a"}, {PageContent: "b"}}
+	
+	res, err := reranker.Rerank(context.Background(), "E1234", docs)
+	assert.Nil(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "b", res[0].PageContent)
+	assert.InDelta(t, 0.9, res[0].Score, 1e-6)
+	
+	tei = true
+	res, err = reranker.Rerank(context.Background(), "E1234", docs)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", res[0].PageContent)
+	assert.InDelta(t, 0.8, res[0].Score, 1e-6)
+	
+	_, err = (&CrossEncoderReranker{}).Rerank(context.Background(), "E1234", docs)
+	assert.ErrorIs(t, err, RerankURLEmptyErr)
+}
@@ -0,0 +1,165 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+const (
+	maxExcerptLen   = 200
+	maxSourcesUsers = 10000
+)
+
+var (
+	lastSources     = make(map[string][]*Source)
+	lastSourcesLock sync.RWMutex
+)
+
+// Source citation of retrieved chunk
+type Source struct {
+	FileName   string  `json:"file_name"`
+	FilePath   string  `json:"file_path"`
+	URL        string  `json:"url"`
+	ChunkIndex int     `json:"chunk_index"`
+	Page       int     `json:"page"`
+	Score      float32 `json:"score"`
+	Content    string  `json:"content"`
+}
+
+// GetSources get sources from retrieved documents, same chunk is only kept once
+func GetSources(docs []schema.Document) []*Source {
+	sources := make([]*Source, 0, len(docs))
+	exist := make(map[string]bool)
+	for _, doc := range docs {
+		source := &Source{
+			FileName:   metadataString(doc.Metadata["file_name"]),
+			FilePath:   metadataString(doc.Metadata["file_path"]),
+			URL:        metadataString(doc.Metadata["source_url"]),
+			ChunkIndex: metadataInt(doc.Metadata["chunk_index"]),
+			Page:       metadataInt(doc.Metadata["page"]),
+			Score:      doc.Score,
+			Content:    doc.PageContent,
+		}
+		if source.FilePath == "" {
+			source.FilePath = source.FileName
+		}
+		if source.FileName == "" {
+			source.FileName = path.Base(source.FilePath)
+		}
+		
+		key := fmt.Sprintf("%s#%d#%s", source.FilePath, source.ChunkIndex, source.Content)
+		if exist[key] {
+			continue
+		}
+		exist[key] = true
+		sources = append(sources, source)
+	}
+	
+	return sources
+}
+
+// FormatSources format sources as markdown list, excerpt means show content of chunk
+func FormatSources(title string, sources []*Source, excerpt bool) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	
+	sb := new(strings.Builder)
+	sb.WriteString("**" + title + "**\n")
+	for i, source := range sources {
+		name := source.FilePath
+		if source.URL != "" {
+			name = source.URL
+		}
+		if name == "" {
+			name = "-"
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s #%d", i+1, name, source.ChunkIndex+1))
+		if source.Page > 0 {
+			sb.WriteString(fmt.Sprintf(", page %d", source.Page))
+		}
+		if source.Score != 0 {
+			sb.WriteString(fmt.Sprintf(" (%.2f)", source.Score))
+		}
+		sb.WriteString("\n")
+		
+		if excerpt {
+			sb.WriteString("> " + getExcerpt(source.Content) + "\n")
+		}
+	}
+	
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// SaveLastSources save sources of last rag answer, user can view excerpts later
+func SaveLastSources(key string, sources []*Source) {
+	lastSourcesLock.Lock()
+	defer lastSourcesLock.Unlock()
+	
+	if _, ok := lastSources[key]; !ok && len(lastSources) >= maxSourcesUsers {
+		for k := range lastSources {
+			delete(lastSources, k)
+			break
+		}
+	}
+	lastSources[key] = sources
+}
+
+// deleteLastSources delete sources of last rag answer
+func deleteLastSources(key string) {
+	lastSourcesLock.Lock()
+	defer lastSourcesLock.Unlock()
+	delete(lastSources, key)
+}
+
+// GetLastSources get sources of last rag answer
+func GetLastSources(key string) []*Source {
+	lastSourcesLock.RLock()
+	defer lastSourcesLock.RUnlock()
+	return lastSources[key]
+}
+
+func getExcerpt(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	runes := []rune(content)
+	if len(runes) > maxExcerptLen {
+		return string(runes[:maxExcerptLen]) + "..."
+	}
+	return content
+}
+
+func metadataString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// metadataInt get int from metadata, numbers become float64 after json unmarshal
+func metadataInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float32:
+		return int(n)
+	case float64:
+		return int(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
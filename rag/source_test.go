@@ -0,0 +1,60 @@
+package rag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/langchaingo/schema"
+)
+
+func TestGetSources(t *testing.T) {
+	docs := []schema.Document{
+		{PageContent: "apple\nis   red", Score: 0.9, Metadata: map[string]any{
+			"file_name": "a.pdf", "file_path": "fruit/a.pdf", "chunk_index": float64(1), "page": float64(3)}},
+		{PageContent: "apple\nis   red", Score: 0.9, Metadata: map[string]any{
+			"file_name": "a.pdf", "file_path": "fruit/a.pdf", "chunk_index": float64(1), "page": float64(3)}},
+		{PageContent: "car", Score: 0.5, Metadata: map[string]any{"file_name": "car.txt", "chunk_index": 0}},
+	}
+	
+	sources := GetSources(docs)
+	assert.Len(t, sources, 2)
+	assert.Equal(t, &Source{FileName: "a.pdf", FilePath: "fruit/a.pdf", ChunkIndex: 1, Page: 3, Score: 0.9,
+		Content: "apple\nis   red"}, sources[0])
+	assert.Equal(t, "car.txt", sources[1].FilePath)
+	
+	assert.Equal(t, "**Sources**\n1. fruit/a.pdf #2, page 3 (0.90)\n2. car.txt #1 (0.50)",
+		FormatSources("Sources", sources, false))
+	assert.Equal(t, "**Sources**\n1. fruit/a.pdf #2, page 3 (0.90)\n> apple is red\n2. car.txt #1 (0.50)\n> car",
+		FormatSources("Sources", sources, true))
+	assert.Equal(t, "", FormatSources("Sources", nil, true))
+	
+	SaveLastSources("user1", sources)
+	assert.Equal(t, sources, GetLastSources("user1"))
+	assert.Nil(t, GetLastSources("user2"))
+}
+
+func TestSourcesFromStore(t *testing.T) {
+	dir := initTestRagConf(t)
+	ctx := context.Background()
+	
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "animal"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "animal", "dog.txt"), []byte("dog dog"), 0644))
+	_, err := ReconcileKnowledgeBase(ctx)
+	assert.Nil(t, err)
+	
+	// metadata is loaded from db again, numbers become float64
+	store, err := NewLocalStore(&fakeEmbedder{})
+	assert.Nil(t, err)
+	docs, err := store.SimilaritySearch(ctx, "dog", 1)
+	assert.Nil(t, err)
+	
+	sources := GetSources(docs)
+	assert.Len(t, sources, 1)
+	assert.Equal(t, "animal/dog.txt", sources[0].FilePath)
+	assert.Equal(t, "dog.txt", sources[0].FileName)
+	assert.Equal(t, 0, sources[0].ChunkIndex)
+	assert.InDelta(t, 1, sources[0].Score, 0.0001)
+}
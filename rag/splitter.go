@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"path/filepath"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/langchaingo/textsplitter"
+)
+
+// codeSeparators split source code by top level definitions first, then by lines
+var codeSeparators = map[string][]string{
+	"go":     {"\nfunc ", "\nvar ", "\nconst ", "\ntype "},
+	"py":     {"\nclass ", "\ndef ", "\n\tdef ", "\n    def "},
+	"js":     {"\nfunction ", "\nconst ", "\nlet ", "\nvar ", "\nclass ", "\nexport "},
+	"ts":     {"\nfunction ", "\nconst ", "\nlet ", "\nvar ", "\nclass ", "\nexport ", "\ninterface ", "\ntype "},
+	"java":   {"\nclass ", "\npublic ", "\nprivate ", "\nprotected ", "\nstatic ", "\ninterface "},
+	"c":      {"\nstruct ", "\ntypedef ", "\nstatic ", "\nvoid ", "\nint ", "\n#define "},
+	"cpp":    {"\nclass ", "\nstruct ", "\nnamespace ", "\ntemplate ", "\nstatic ", "\nvoid ", "\nint "},
+	"rs":     {"\nfn ", "\npub fn ", "\nimpl ", "\nstruct ", "\npub struct ", "\nenum ", "\ntrait ", "\nmod "},
+	"rb":     {"\nclass ", "\nmodule ", "\ndef ", "\n  def "},
+	"php":    {"\nclass ", "\nfunction ", "\npublic function ", "\nprivate function ", "\nprotected function "},
+	"swift":  {"\nclass ", "\nstruct ", "\nfunc ", "\nenum ", "\nextension ", "\nprotocol "},
+	"kotlin": {"\nclass ", "\nfun ", "\nval ", "\nvar ", "\nobject ", "\ninterface "},
+	"shell":  {"\nfunction ", "\n}\n"},
+}
+
+// codeLanguages map file extension to language of codeSeparators
+var codeLanguages = map[string]string{
+	".go":    "go",
+	".py":    "py",
+	".js":    "js",
+	".jsx":   "js",
+	".mjs":   "js",
+	".ts":    "ts",
+	".tsx":   "ts",
+	".java":  "java",
+	".scala": "java",
+	".cs":    "java",
+	".c":     "c",
+	".h":     "c",
+	".cpp":   "cpp",
+	".cc":    "cpp",
+	".hpp":   "cpp",
+	".rs":    "rs",
+	".rb":    "rb",
+	".php":   "php",
+	".swift": "swift",
+	".kt":    "kotlin",
+	".sh":    "shell",
+}
+
+// getCodeLanguage get language of source file, return empty string if file is not source code
+func getCodeLanguage(fileName string) string {
+	return codeLanguages[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// isMarkdownFile check file is markdown
+func isMarkdownFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// getSplitter get text splitter by file extension, markdown is split by heading
+// and source code is split by top level definitions.
+func getSplitter(fileName string) textsplitter.TextSplitter {
+	chunkSize, chunkOverlap := conf.RagConfInfo.GetChunkSize(filepath.Ext(fileName))
+	
+	if isMarkdownFile(fileName) {
+		return textsplitter.NewMarkdownTextSplitter(
+			textsplitter.WithChunkSize(chunkSize),
+			textsplitter.WithChunkOverlap(chunkOverlap),
+			textsplitter.WithHeadingHierarchy(true),
+			textsplitter.WithCodeBlocks(true),
+		)
+	}
+	
+	if language := getCodeLanguage(fileName); language != "" {
+		separators := append([]string{}, codeSeparators[language]...)
+		separators = append(separators, conf.DefaultSpliter...)
+		return textsplitter.NewRecursiveCharacter(
+			textsplitter.WithChunkSize(chunkSize),
+			textsplitter.WithChunkOverlap(chunkOverlap),
+			textsplitter.WithSeparators(separators),
+			textsplitter.WithKeepSeparator(true),
+		)
+	}
+	
+	return textsplitter.NewRecursiveCharacter(
+		textsplitter.WithChunkSize(chunkSize),
+		textsplitter.WithChunkOverlap(chunkOverlap),
+		textsplitter.WithSeparators(conf.DefaultSpliter),
+	)
+}
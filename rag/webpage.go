@@ -0,0 +1,257 @@
+package rag
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"golang.org/x/net/html"
+)
+
+const (
+	// WebDir web pages learned by /learn <url> are saved in knowledge_path/[namespaces/<namespace>/]web/<host>/
+	WebDir = "web"
+	
+	webSourcePrefix   = "Source: "
+	maxWebPageNameLen = 100
+)
+
+var (
+	webPageNameReg = regexp.MustCompile(`[^a-zA-Z0-9_.\-]+`)
+	blankLinesReg  = regexp.MustCompile(`\n{3,}`)
+	
+	// webSkipTags content of these tags is not readable text
+	webSkipTags = map[string]bool{
+		"script": true, "style": true, "noscript": true, "nav": true, "header": true, "footer": true,
+		"aside": true, "form": true, "svg": true, "iframe": true, "template": true, "button": true,
+		"select": true, "head": true,
+	}
+	
+	webBlockTags = map[string]bool{
+		"p": true, "div": true, "section": true, "article": true, "main": true, "ul": true, "ol": true,
+		"table": true, "tr": true, "blockquote": true, "dl": true, "dt": true, "dd": true, "figure": true,
+		"figcaption": true, "hr": true, "body": true,
+	}
+	
+	webHeadingLevel = map[string]int{"h1": 1, "h2": 2, "h3": 3, "h4": 4, "h5": 5, "h6": 6}
+)
+
+// webPage readable content of html page
+type webPage struct {
+	URL   string
+	Title string
+	Text  string
+	Links []*url.URL
+}
+
+// Content get markdown content saved into knowledge file, source url is kept under title
+func (p *webPage) Content() string {
+	sb := new(strings.Builder)
+	if p.Title != "" {
+		sb.WriteString("# " + p.Title + "\n")
+	}
+	sb.WriteString(webSourcePrefix + p.URL + "\n\n")
+	sb.WriteString(p.Text)
+	return sb.String()
+}
+
+// parseWebPage extract title, readable text and links of html page, text of main or article is
+// preferred, navigation, scripts and styles are dropped.
+func parseWebPage(pageURL *url.URL, r io.Reader) (*webPage, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	
+	page := &webPage{URL: pageURL.String()}
+	var root *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "title":
+				if page.Title == "" && n.FirstChild != nil {
+					page.Title = strings.Join(strings.Fields(n.FirstChild.Data), " ")
+				}
+			case "main", "article":
+				if root == nil || root.Data == "body" {
+					root = n
+				}
+			case "body":
+				if root == nil {
+					root = n
+				}
+			case "a":
+				for _, attr := range n.Attr {
+					if attr.Key != "href" {
+						continue
+					}
+					if link := resolveWebLink(pageURL, attr.Val); link != nil {
+						page.Links = append(page.Links, link)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	
+	if root == nil {
+		root = doc
+	}
+	sb := new(strings.Builder)
+	writeWebText(root, sb, false)
+	page.Text = normalizeWebText(sb.String())
+	return page, nil
+}
+
+// resolveWebLink resolve href relative to page, only http and https links are kept
+func resolveWebLink(pageURL *url.URL, href string) *url.URL {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return nil
+	}
+	
+	link, err := pageURL.Parse(href)
+	if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+		return nil
+	}
+	link.Fragment = ""
+	link.RawFragment = ""
+	return link
+}
+
+func writeWebText(n *html.Node, sb *strings.Builder, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			sb.WriteString(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			return
+		}
+		if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(text)
+		return
+	case html.ElementNode:
+		if webSkipTags[n.Data] {
+			return
+		}
+	case html.CommentNode, html.DoctypeNode:
+		return
+	}
+	
+	tag := ""
+	if n.Type == html.ElementNode {
+		tag = n.Data
+	}
+	
+	switch {
+	case webHeadingLevel[tag] > 0:
+		sb.WriteString("\n\n" + strings.Repeat("#", webHeadingLevel[tag]) + " ")
+	case tag == "li":
+		sb.WriteString("\n- ")
+	case tag == "pre":
+		sb.WriteString("\n\n```\n")
+		pre = true
+	case tag == "br":
+		sb.WriteString("\n")
+	case webBlockTags[tag]:
+		sb.WriteString("\n\n")
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeWebText(c, sb, pre)
+	}
+
+	switch {
+	case tag == "pre":
+		sb.WriteString("\n```\n\n")
+	case webHeadingLevel[tag] > 0, webBlockTags[tag]:
+		sb.WriteString("\n\n")
+	}
+}
+
+// normalizeWebText trim spaces of every line and collapse blank lines
+func normalizeWebText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(lines[i]) == "-" {
+			lines[i] = ""
+		}
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinesReg.ReplaceAllString(text, "\n\n"))
+}
+
+// getWebPagePath get relative path of web page in knowledge path, same url always gets same path
+func getWebPagePath(namespace string, pageURL *url.URL) string {
+	name := webPageNameReg.ReplaceAllString(strings.Trim(pageURL.Path, "/"), "_")
+	if name == "" {
+		name = "index"
+	}
+	
+	hash := ""
+	if len(name) > maxWebPageNameLen {
+		hash = pageURL.Path
+		name = name[:maxWebPageNameLen]
+	}
+	if pageURL.RawQuery != "" {
+		hash += "?" + pageURL.RawQuery
+	}
+	if hash != "" {
+		sum := md5.Sum([]byte(hash))
+		name += "_" + hex.EncodeToString(sum[:])[:8]
+	}
+	
+	filePath := path.Join(WebDir, CleanNamespace(pageURL.Host), name+".md")
+	if namespace = CleanNamespace(namespace); namespace != "" {
+		filePath = path.Join(NamespaceDir, namespace, filePath)
+	}
+	return filePath
+}
+
+// isWebPageFile check file is saved by /learn <url>
+func isWebPageFile(filePath string) bool {
+	parts := strings.Split(filePath, "/")
+	if len(parts) > 2 && parts[0] == WebDir {
+		return true
+	}
+	return len(parts) > 4 && parts[0] == NamespaceDir && parts[2] == WebDir
+}
+
+// getSourceURL get source url of web page file, it is written in the first lines of file
+func getSourceURL(filePath string) string {
+	if !isWebPageFile(filePath) {
+		return ""
+	}
+	
+	f, err := os.Open(filepath.Join(*conf.RagConfInfo.KnowledgePath, filepath.FromSlash(filePath)))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 3 && scanner.Scan(); i++ {
+		if line := scanner.Text(); strings.HasPrefix(line, webSourcePrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, webSourcePrefix))
+		}
+	}
+	return ""
+}
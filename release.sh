@@ -39,7 +39,7 @@ compile_and_package() {
     echo "=============================="
 
     # Build the main bot binary
-    xgo -out MuseBot -targets="$os/$arch" .
+    xgo -out MuseBot -tags sqlite_fts5 -targets="$os/$arch" .
 
     # Build admin binary
     build_admin_local $os $arch
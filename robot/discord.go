@@ -79,6 +79,17 @@ func (d *DiscordRobot) getMsgContent() string {
 }
 
 func (d *DiscordRobot) requestLLMAndResp(content string) {
+	// file or url sent with /learn is added into knowledge base, /search and /reopen search chat history,
+	// /link links accounts
+	if d.Msg != nil {
+		command, prompt := ParseCommand(strings.TrimSpace(strings.ReplaceAll(content, "<@"+d.Session.State.User.ID+">", "")))
+		if command == "/learn" || command == "/search" || command == "/reopen" || command == "/link" {
+			d.Prompt = prompt
+			d.Robot.ExecCmd(command, func() {})
+			return
+		}
+	}
+	
 	d.Robot.TalkingPreCheck(func() {
 		if conf.RagConfInfo.Store != nil {
 			d.executeChain(content)
@@ -351,6 +362,27 @@ func registerSlashCommands(s *discordgo.Session) {
 		{Name: "mcp", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.mcp.description", nil), Options: []*discordgo.ApplicationCommandOption{
 			{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "Prompt", Required: true},
 		}},
+		{Name: "agent", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.agent.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Agent name", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "prompt", Description: "Prompt", Required: true},
+		}},
+		{Name: "sources", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.sources.description", nil)},
+		{Name: "learn", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.learn.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionAttachment, Name: "file", Description: "upload a file", Required: false},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "url", Description: "web page url and crawl depth", Required: false},
+		}},
+		{Name: "search", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.search.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "terms", Description: "search terms", Required: true},
+		}},
+		{Name: "reopen", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.reopen.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "id", Description: "record id of search result", Required: true},
+		}},
+		{Name: "link", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.link.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "code", Description: "link code shown on another platform", Required: false},
+		}},
+		{Name: "forgetme", Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.forgetme.description", nil), Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "code", Description: "confirmation code", Required: false},
+		}},
 	}
 	
 	for _, cmd := range commands {
@@ -568,7 +600,7 @@ func (d *DiscordRobot) sendImg() {
 		base64Content := base64.StdEncoding.EncodeToString(imageContent)
 		dataURI := fmt.Sprintf("data:image/%s;base64,%s", utils.DetectImageFormat(imageContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -641,7 +673,7 @@ func (d *DiscordRobot) sendVideo() {
 		base64Content := base64.StdEncoding.EncodeToString(videoContent)
 		dataURI := fmt.Sprintf("data:video/%s;base64,%s", utils.DetectVideoMimeType(videoContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -691,9 +723,12 @@ func (d *DiscordRobot) sendMultiAgent(agentType string) {
 			}()
 			
 			var err error
-			if agentType == "mcp_empty_content" {
+			switch agentType {
+			case "mcp_empty_content":
 				err = dpReq.ExecuteMcp()
-			} else {
+			case "agent_empty_content":
+				err = dpReq.ExecuteAgent()
+			default:
 				err = dpReq.ExecuteTask()
 			}
 			if err != nil {
@@ -705,6 +740,47 @@ func (d *DiscordRobot) sendMultiAgent(agentType string) {
 	})
 }
 
+// getDocument download first attachment of message or file option of /learn command
+func (d *DiscordRobot) getDocument() (string, []byte, error) {
+	var attachment *discordgo.MessageAttachment
+	if d.Msg != nil && len(d.Msg.Attachments) > 0 {
+		attachment = d.Msg.Attachments[0]
+	}
+	if d.Inter != nil && d.Inter.Type == discordgo.InteractionApplicationCommand {
+		data := d.Inter.ApplicationCommandData()
+		if option := data.GetOption("file"); option != nil && data.Resolved != nil {
+			if id, ok := option.Value.(string); ok {
+				attachment = data.Resolved.Attachments[id]
+			}
+		}
+	}
+	if attachment == nil {
+		return "", nil, nil
+	}
+	
+	content, err := utils.DownloadFile(attachment.URL)
+	if err != nil {
+		logger.Warn("download file fail", "url", attachment.URL, "err", err)
+		return "", nil, err
+	}
+	return attachment.Filename, content, nil
+}
+
 func (d *DiscordRobot) getPrompt() string {
-	return d.Prompt
+	if d.Prompt != "" {
+		return d.Prompt
+	}
+
+	// slash command put prompt in options, e.g. /agent name prompt
+	if d.Inter != nil && d.Inter.Type == discordgo.InteractionApplicationCommand {
+		values := make([]string, 0)
+		for _, option := range d.Inter.ApplicationCommandData().Options {
+			if option.Type == discordgo.ApplicationCommandOptionString {
+				values = append(values, option.StringValue())
+			}
+		}
+		return strings.Join(values, " ")
+	}
+	
+	return ""
 }
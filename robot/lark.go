@@ -33,6 +33,11 @@ type MessageText struct {
 	Text string `json:"text"`
 }
 
+type MessageFile struct {
+	FileKey  string `json:"file_key"`
+	FileName string `json:"file_name"`
+}
+
 var (
 	cli       *larkws.Client
 	BotName   string
@@ -300,7 +305,7 @@ func (l *LarkRobot) sendImg() {
 		}
 		
 		// save data record
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   l.Prompt,
 			Answer:     dataURI,
@@ -403,7 +408,7 @@ func (l *LarkRobot) sendVideo() {
 		base64Content := base64.StdEncoding.EncodeToString(videoContent)
 		dataURI := fmt.Sprintf("data:video/%s;base64,%s", utils.DetectVideoMimeType(videoContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   l.Prompt,
 			Answer:     dataURI,
@@ -715,6 +720,58 @@ func (l *LarkRobot) GetMessageContent() (bool, error) {
 	return botShowName == l.BotName, nil
 }
 
+// getDocument download file of message, or file message which /learn replies to
+func (l *LarkRobot) getDocument() (string, []byte, error) {
+	msgId := larkcore.StringValue(l.Message.Event.Message.MessageId)
+	msgType := larkcore.StringValue(l.Message.Event.Message.MessageType)
+	content := larkcore.StringValue(l.Message.Event.Message.Content)
+	
+	parentId := larkcore.StringValue(l.Message.Event.Message.ParentId)
+	if msgType != larkim.MsgTypeFile && parentId != "" {
+		resp, err := l.Client.Im.V1.Message.Get(l.Ctx, larkim.NewGetMessageReqBuilder().MessageId(parentId).Build())
+		if err != nil || !resp.Success() {
+			logger.Error("get parent message failed", "err", err, "resp", resp)
+			return "", nil, errors.New("get parent message failed")
+		}
+		if len(resp.Data.Items) == 0 || resp.Data.Items[0].Body == nil {
+			return "", nil, nil
+		}
+		
+		msgId = parentId
+		msgType = larkcore.StringValue(resp.Data.Items[0].MsgType)
+		content = larkcore.StringValue(resp.Data.Items[0].Body.Content)
+	}
+	
+	if msgType != larkim.MsgTypeFile {
+		return "", nil, nil
+	}
+	
+	msgFile := new(MessageFile)
+	err := json.Unmarshal([]byte(content), msgFile)
+	if err != nil {
+		logger.Warn("unmarshal message file failed", "err", err)
+		return "", nil, err
+	}
+	
+	resp, err := l.Client.Im.V1.MessageResource.Get(l.Ctx,
+		larkim.NewGetMessageResourceReqBuilder().
+			MessageId(msgId).
+			FileKey(msgFile.FileKey).
+			Type("file").
+			Build())
+	if err != nil || !resp.Success() {
+		logger.Error("get file failed", "err", err, "resp", resp)
+		return "", nil, errors.New("get file failed")
+	}
+	
+	bs, err := io.ReadAll(resp.File)
+	if err != nil {
+		logger.Error("read file failed", "err", err)
+		return "", nil, err
+	}
+	return msgFile.FileName, bs, nil
+}
+
 func (l *LarkRobot) getPrompt() string {
 	return l.Prompt
 }
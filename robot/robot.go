@@ -25,7 +25,7 @@ import (
 	"github.com/yincongcyincong/MuseBot/rag"
 	"github.com/yincongcyincong/MuseBot/utils"
 	"github.com/yincongcyincong/langchaingo/chains"
-	"github.com/yincongcyincong/langchaingo/vectorstores"
+	"github.com/yincongcyincong/langchaingo/schema"
 )
 
 var (
@@ -52,11 +52,28 @@ Available Commands:
 
 /mcp    - Use Multi-Agent Control Panel for complex task planning
 
+/agent  - Talk to a user defined agent: /agent <name> <prompt>
+
+/sources - Show excerpts of knowledge base sources of your last answer
+
+/learn  - Send a file with /learn caption or /learn <url> [depth] to add it into knowledge base
+
+/search - Search your chat history: /search <terms>, /reopen <id> continues a found exchange
+
+/link   - Link accounts of different platforms: /link shows a code, /link <code> on another platform merges them
+
+/forgetme - Permanently delete all your data: /forgetme shows a code, /forgetme <code> confirms it
+
 /help   - Show this help message
 
 `
 )
 
+const (
+	searchLimit       = 5
+	searchQuestionLen = 50
+)
+
 type RobotInfo struct {
 	Robot Robot
 }
@@ -81,6 +98,8 @@ type Robot interface {
 	handleUpdate(messageChan chan *param.MsgInfo)
 	
 	getPrompt() string
+	
+	getDocument() (string, []byte, error)
 }
 
 type botOption func(r *RobotInfo)
@@ -94,7 +113,7 @@ func NewRobot(options ...botOption) *RobotInfo {
 }
 
 func (r *RobotInfo) Exec() {
-	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	chatId, msgId, userId := r.getChatIdAndMsgIdAndPlatformUserID()
 	
 	if !r.checkUserAllow(userId) && !r.checkGroupAllow(chatId) {
 		logger.Warn("user/group not allow to use this bot", "userID", userId, "chat", chatId)
@@ -108,7 +127,29 @@ func (r *RobotInfo) Exec() {
 	}
 }
 
+// GetChatIdAndMsgIdAndUserID get chat id, message id and user id of account which platform user belongs to
 func (r *RobotInfo) GetChatIdAndMsgIdAndUserID() (string, string, string) {
+	chatId, msgId, platformUserId := r.getChatIdAndMsgIdAndPlatformUserID()
+	return chatId, msgId, db.ResolveUserId(r.getPlatform(), platformUserId)
+}
+
+// getPlatform get platform of robot
+func (r *RobotInfo) getPlatform() string {
+	switch r.Robot.(type) {
+	case *TelegramRobot:
+		return param.TelegramPlatform
+	case *DiscordRobot:
+		return param.DiscordPlatform
+	case *SlackRobot:
+		return param.SlackPlatform
+	case *LarkRobot:
+		return param.LarkPlatform
+	}
+	return param.WebPlatform
+}
+
+// getChatIdAndMsgIdAndPlatformUserID get chat id, message id and user id of platform
+func (r *RobotInfo) getChatIdAndMsgIdAndPlatformUserID() (string, string, string) {
 	chatId := ""
 	msgId := ""
 	userId := ""
@@ -442,10 +483,12 @@ func (r *RobotInfo) TalkingPreCheck(f func()) {
 		return
 	}
 	
-	defer utils.DecreaseUserChat(userId)
-	
 	// check user chat exceed max count
-	if utils.CheckUserChatExceed(userId) {
+	exceed, err := utils.CheckUserChatExceed(userId)
+	if err == nil {
+		defer utils.DecreaseUserChat(userId)
+	}
+	if exceed {
 		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "chat_exceed", nil),
 			msgId, tgbotapi.ModeMarkdown, nil)
 		return
@@ -532,12 +575,33 @@ func (r *RobotInfo) ExecCmd(cmd string, defaultFunc func()) {
 			emptyPromptFunc = t.sendForceReply("mcp_empty_content")
 		}
 		r.sendMultiAgent("mcp_empty_content", emptyPromptFunc)
+	case "agent", "/agent":
+		var emptyPromptFunc func()
+		if t, ok := r.Robot.(*TelegramRobot); ok {
+			emptyPromptFunc = t.sendForceReply("agent_empty_content")
+		}
+		r.sendMultiAgent("agent_empty_content", emptyPromptFunc)
+	case "reindex", "/reindex":
+		r.reindexKnowledgeBase()
+	case "sources", "/sources":
+		r.showSources()
+	case "learn", "/learn":
+		r.learnDocument()
+	case "search", "/search":
+		r.searchRecords()
+	case "reopen", "/reopen":
+		r.reopenRecord(r.Robot.getPrompt())
+	case "link", "/link":
+		r.linkAccount()
+	case "forgetme", "/forgetme":
+		r.forgetMe()
 	default:
 		defaultFunc()
 	}
 }
 
 func (r *RobotInfo) ExecChain(content string, msgChan chan *param.MsgInfo) {
+	defer close(msgChan)
 	r.TalkingPreCheck(func() {
 		chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
 		
@@ -550,24 +614,376 @@ func (r *RobotInfo) ExecChain(content string, msgChan chan *param.MsgInfo) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 		defer cancel()
 		
+		// forward llm message and remember the last one, sources are appended to it
+		llmChan := make(chan *param.MsgInfo)
+		forwardDone := make(chan struct{})
+		var lastMsg *param.MsgInfo
+		go func() {
+			defer close(forwardDone)
+			for msg := range llmChan {
+				lastMsg = msg
+				msgChan <- msg
+			}
+		}()
+		
 		text := content
 		dpLLM := rag.NewRag(
-			llm.WithMessageChan(msgChan),
+			llm.WithMessageChan(llmChan),
 			llm.WithContent(content),
 			llm.WithChatId(chatId),
 			llm.WithUserId(userId),
 		)
+		// search global documents and documents of chat namespace
+		dpLLM.Retriever = rag.NewConfRetriever(conf.RagConfInfo.GetNamespace(chatId),
+			llm.WithChatId(chatId), llm.WithUserId(userId))
 		qaChain := chains.NewRetrievalQAFromLLM(
 			dpLLM,
-			vectorstores.ToRetriever(conf.RagConfInfo.Store, 3),
+			dpLLM.Retriever,
 		)
-		_, err := chains.Run(ctx, qaChain, text)
+		qaChain.ReturnSourceDocuments = true
+		res, err := chains.Call(ctx, qaChain, map[string]any{"query": text})
+		close(llmChan)
+		<-forwardDone
 		if err != nil {
 			r.SendMsg(chatId, err.Error(), msgId, "", nil)
+			return
+		}
+		
+		docs, _ := res["source_documents"].([]schema.Document)
+		sources := rag.GetSources(docs)
+		if len(sources) == 0 {
+			return
+		}
+		rag.SaveLastSources(userId, sources)
+		
+		sourcesContent := rag.FormatSources(i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_sources", nil), sources, false)
+		if lastMsg != nil && utils.Utf16len(lastMsg.Content+"\n\n"+sourcesContent) <= llm.OneMsgLen {
+			lastMsg.Content += "\n\n" + sourcesContent
+			msgChan <- lastMsg
+		} else {
+			msgChan <- &param.MsgInfo{Content: sourcesContent}
 		}
 	})
 }
 
+// showSources show sources and excerpts of last knowledge base answer
+func (r *RobotInfo) showSources() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	
+	sources := rag.GetLastSources(userId)
+	if len(sources) == 0 {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_sources_empty", nil),
+			msgId, tgbotapi.ModeMarkdown, nil)
+		return
+	}
+	
+	r.SendMsg(chatId, rag.FormatSources(i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_sources", nil), sources, true),
+		msgId, tgbotapi.ModeMarkdown, nil)
+}
+
+// learnDocument add file sent by user into knowledge base namespace of chat
+func (r *RobotInfo) learnDocument() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	if conf.RagConfInfo.Store == nil {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_not_enable", nil),
+			msgId, tgbotapi.ModeMarkdown, nil)
+		return
+	}
+	
+	// discord interaction must be responded in 3 seconds, edit the response later
+	mode := ""
+	if d, ok := r.Robot.(*DiscordRobot); ok && d.Inter != nil {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "thinking", nil),
+			msgId, tgbotapi.ModeMarkdown, nil)
+		mode = param.DiscordEditMode
+	}
+	
+	if prompt := strings.TrimSpace(r.Robot.getPrompt()); IsLearnURL(prompt) {
+		r.SendMsg(chatId, LearnURL(chatId, userId, prompt), msgId, mode, nil)
+		return
+	}
+	
+	fileName, data, err := r.Robot.getDocument()
+	if err != nil {
+		logger.Warn("get document fail", "userID", userId, "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, mode, nil)
+		return
+	}
+	if len(data) == 0 {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_empty_file", nil),
+			msgId, mode, nil)
+		return
+	}
+	
+	r.SendMsg(chatId, LearnFile(chatId, userId, fileName, data), msgId, mode, nil)
+}
+
+// IsLearnURL check prompt of /learn is url, slack sends url like <https://xxx|xxx>
+func IsLearnURL(prompt string) bool {
+	prompt = strings.TrimPrefix(prompt, "<")
+	return strings.HasPrefix(prompt, "http://") || strings.HasPrefix(prompt, "https://")
+}
+
+// LearnURL crawl url in prompt like "https://xxx 1" into knowledge base namespace of chat and get reply message,
+// the second field is crawl depth
+func LearnURL(chatId, userId, prompt string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	
+	fields := strings.Fields(prompt)
+	depth := 0
+	if len(fields) > 1 {
+		depth, _ = strconv.Atoi(fields[1])
+	}
+	fields[0], _, _ = strings.Cut(strings.Trim(fields[0], "<>"), "|")
+	
+	res, err := rag.LearnURL(ctx, conf.RagConfInfo.GetNamespace(chatId), userId, fields[0], depth)
+	if errors.Is(err, rag.FileContentEmptyErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_url_empty", map[string]interface{}{
+			"url": fields[0],
+		})
+	}
+	if errors.Is(err, rag.PrivateURLErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_url_forbidden", map[string]interface{}{
+			"url": fields[0],
+		})
+	}
+	if err != nil {
+		logger.Warn("learn url fail", "userID", userId, "url", fields[0], "err", err)
+		return err.Error()
+	}
+	
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_url_succ", map[string]interface{}{
+		"url":     fields[0],
+		"pages":   res.Pages,
+		"chunks":  res.Chunks,
+		"skipped": res.Skipped,
+	})
+}
+
+// LearnFile embed file into knowledge base namespace of chat and get reply message
+func LearnFile(chatId, userId, fileName string, data []byte) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	
+	filePath, chunks, err := rag.LearnFile(ctx, conf.RagConfInfo.GetNamespace(chatId), userId, fileName, data)
+	if errors.Is(err, rag.UnsupportedFileErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_unsupported_file", map[string]interface{}{
+			"file": fileName,
+		})
+	}
+	if errors.Is(err, rag.FileContentEmptyErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_empty_file", nil)
+	}
+	if err != nil {
+		logger.Warn("learn file fail", "userID", userId, "file", fileName, "err", err)
+		return err.Error()
+	}
+	
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_succ", map[string]interface{}{
+		"file":   filePath,
+		"chunks": chunks,
+	})
+}
+
+// searchRecords search chat history of user, telegram shows buttons to reopen results
+func (r *RobotInfo) searchRecords() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	
+	msgContent, records := SearchRecords(userId, r.Robot.getPrompt())
+	var inlineKeyboard *tgbotapi.InlineKeyboardMarkup
+	if _, ok := r.Robot.(*TelegramRobot); ok && len(records) > 0 {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(records))
+		for i, record := range records {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(i+1), ReopenCommand(record.ID)))
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+		inlineKeyboard = &keyboard
+	}
+	
+	r.SendMsg(chatId, msgContent, msgId, "", inlineKeyboard)
+}
+
+// reopenRecord put searched record into context of user
+func (r *RobotInfo) reopenRecord(id string) {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	r.SendMsg(chatId, ReopenRecord(userId, id), msgId, "", nil)
+}
+
+// SearchRecords full text search records of user and get reply message with date and snippet of every record
+func SearchRecords(userId, query string) (string, []*db.SearchRecord) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "search_empty_content", nil), nil
+	}
+	
+	records, err := db.SearchRecords(userId, query, searchLimit)
+	if err != nil {
+		logger.Warn("search records fail", "userID", userId, "query", query, "err", err)
+		return err.Error(), nil
+	}
+	if len(records) == 0 {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "search_no_result", map[string]interface{}{
+			"query": query,
+		}), nil
+	}
+	
+	return FormatSearchRecords(i18n.GetMessage(*conf.BaseConfInfo.Lang, "search_result", map[string]interface{}{
+		"query": query,
+	}), records), records
+}
+
+// FormatSearchRecords format search results, every result ends with command to reopen it
+func FormatSearchRecords(title string, records []*db.SearchRecord) string {
+	var sb strings.Builder
+	sb.WriteString(title)
+	for i, record := range records {
+		sb.WriteString(fmt.Sprintf("\n\n%d. [%s] %s\n%s\n%s", i+1, time.Unix(record.CreateTime, 0).Format(time.DateOnly),
+			db.GetSnippet(record.Question, nil, searchQuestionLen), record.Snippet, ReopenCommand(record.ID)))
+	}
+	return sb.String()
+}
+
+// ReopenCommand command to reopen record
+func ReopenCommand(id int) string {
+	return "/reopen " + strconv.Itoa(id)
+}
+
+// ReopenRecord put record of user into context, so that next question continues that exchange
+func ReopenRecord(userId, id string) string {
+	recordId, err := strconv.Atoi(strings.TrimSpace(id))
+	if err != nil {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "reopen_fail", nil)
+	}
+	
+	record, err := db.GetRecordById(userId, recordId)
+	if err != nil {
+		logger.Warn("get record fail", "userID", userId, "id", recordId, "err", err)
+		return err.Error()
+	}
+	if record == nil {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "reopen_fail", nil)
+	}
+	
+	db.InsertMsgRecord(userId, &db.AQ{
+		Question: record.Question,
+		Answer:   record.Answer,
+		Content:  record.Content,
+		Mode:     record.Mode,
+	}, false)
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "reopen_succ", map[string]interface{}{
+		"question": db.GetSnippet(record.Question, nil, searchQuestionLen),
+	})
+}
+
+// linkAccount show link code or link account with code, only in private chat because code grants access to account
+func (r *RobotInfo) linkAccount() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	if !r.isPrivateChat() {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "link_private_chat", nil), msgId, "", nil)
+		return
+	}
+	
+	r.SendMsg(chatId, LinkAccount(userId, r.Robot.getPrompt()), msgId, "", nil)
+}
+
+// isPrivateChat check whether message is sent in private chat with bot
+func (r *RobotInfo) isPrivateChat() bool {
+	switch robot := r.Robot.(type) {
+	case *TelegramRobot:
+		return robot.getMessage() != nil && robot.getMessage().Chat.IsPrivate()
+	case *DiscordRobot:
+		if robot.Msg != nil {
+			return robot.Msg.GuildID == ""
+		}
+		return robot.Inter != nil && robot.Inter.GuildID == ""
+	case *SlackRobot:
+		if robot.Event != nil {
+			return robot.Event.ChannelType == "im"
+		}
+		return robot.CmdEvent != nil && strings.HasPrefix(robot.CmdEvent.ChannelID, "D")
+	case *LarkRobot:
+		return robot.Message != nil && larkcore.StringValue(robot.Message.Event.Message.ChatType) == "p2p"
+	}
+	return true
+}
+
+// LinkAccount create one time link code if code is empty, otherwise merge quota, history and settings of user
+// into account which created code
+func LinkAccount(userId, code string) string {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		linkCode, err := db.CreateLinkCode(userId)
+		if err != nil {
+			logger.Warn("create link code fail", "userID", userId, "err", err)
+			return err.Error()
+		}
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "link_code", map[string]interface{}{
+			"code": linkCode,
+		})
+	}
+	
+	targetId, err := db.LinkUser(code, userId)
+	if errors.Is(err, db.LinkCodeInvalidErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "link_code_invalid", nil)
+	}
+	if errors.Is(err, db.LinkSelfErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "link_self", nil)
+	}
+	if err != nil {
+		logger.Warn("link user fail", "userID", userId, "err", err)
+		return err.Error()
+	}
+	
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "link_succ", map[string]interface{}{
+		"user_id": targetId,
+	})
+}
+
+// forgetMe delete all data of user after user confirms it with code
+func (r *RobotInfo) forgetMe() {
+	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
+	r.SendMsg(chatId, ForgetMe(userId, r.Robot.getPrompt()), msgId, "", nil)
+}
+
+// ForgetMe create confirmation code if code is empty, otherwise delete all data of user and get deletion receipt
+func ForgetMe(userId, code string) string {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		forgetCode, err := db.CreateForgetCode(userId)
+		if err != nil {
+			logger.Warn("create forget code fail", "userID", userId, "err", err)
+			return err.Error()
+		}
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "forget_confirm", map[string]interface{}{
+			"code": forgetCode,
+		})
+	}
+	
+	err := db.CheckForgetCode(userId, code)
+	if errors.Is(err, db.ForgetCodeInvalidErr) {
+		return i18n.GetMessage(*conf.BaseConfInfo.Lang, "forget_code_invalid", nil)
+	}
+	if err != nil {
+		logger.Warn("check forget code fail", "userID", userId, "err", err)
+		return err.Error()
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	
+	receipt, err := rag.ForgetUser(ctx, userId)
+	if err != nil {
+		logger.Warn("forget user fail", "userID", userId, "err", err)
+		return err.Error()
+	}
+	
+	return i18n.GetMessage(*conf.BaseConfInfo.Lang, "forget_succ", map[string]interface{}{
+		"id":   receipt.ID,
+		"hash": receipt.Hash,
+	})
+}
+
 func (r *RobotInfo) showBalanceInfo() {
 	chatId, msgId, _ := r.GetChatIdAndMsgIdAndUserID()
 	
@@ -646,6 +1062,37 @@ func (r *RobotInfo) clearAllRecord() {
 	
 }
 
+// reindexKnowledgeBase admin delete all knowledge vectors and embed files again
+func (r *RobotInfo) reindexKnowledgeBase() {
+	chatId, msgId, userId := r.getChatIdAndMsgIdAndPlatformUserID()
+	if !r.checkAdminUser(userId) {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "not_admin", nil),
+			msgId, tgbotapi.ModeMarkdown, nil)
+		return
+	}
+	
+	if conf.RagConfInfo.Store == nil {
+		r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_not_enable", nil),
+			msgId, tgbotapi.ModeMarkdown, nil)
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	
+	res, err := rag.ReindexAll(ctx)
+	if err != nil {
+		logger.Warn("reindex knowledge base fail", "err", err)
+		r.SendMsg(chatId, err.Error(), msgId, tgbotapi.ModeMarkdown, nil)
+		return
+	}
+	
+	r.SendMsg(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, "reindex_succ", map[string]interface{}{
+		"added":  res.Added,
+		"chunks": res.Chunks,
+	}), msgId, tgbotapi.ModeMarkdown, nil)
+}
+
 func (r *RobotInfo) retryLastQuestion() {
 	chatId, msgId, userId := r.GetChatIdAndMsgIdAndUserID()
 	
@@ -696,9 +1143,12 @@ func (r *RobotInfo) sendMultiAgent(agentType string, emptyPromptFunc func()) {
 			}()
 			
 			var err error
-			if agentType == "mcp_empty_content" {
+			switch agentType {
+			case "mcp_empty_content":
 				err = dpReq.ExecuteMcp()
-			} else {
+			case "agent_empty_content":
+				err = dpReq.ExecuteAgent()
+			default:
 				err = dpReq.ExecuteTask()
 			}
 			if err != nil {
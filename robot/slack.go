@@ -574,7 +574,7 @@ func (s *SlackRobot) sendImg() {
 		
 		// 你可以记录数据库
 		dataURI := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(imageContent)
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -659,7 +659,7 @@ func (s *SlackRobot) sendVideo() {
 		base64Content := base64.StdEncoding.EncodeToString(videoContent)
 		dataURI := fmt.Sprintf("data:video/%s;base64,%s", utils.DetectVideoMimeType(videoContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userID,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -742,6 +742,21 @@ func submissionHandler(callback *slack.InteractionCallback) {
 	
 }
 
+// getDocument download first file of message
+func (s *SlackRobot) getDocument() (string, []byte, error) {
+	if s.Event == nil || s.Event.Message == nil || len(s.Event.Message.Files) == 0 {
+		return "", nil, nil
+	}
+	
+	file := s.Event.Message.Files[0]
+	bs, err := s.downloadSlackFile(file.URLPrivateDownload)
+	if err != nil {
+		logger.Error("download file failed", "err", err)
+		return "", nil, err
+	}
+	return file.Name, bs, nil
+}
+
 func (s *SlackRobot) getPrompt() string {
 	return s.Prompt
 }
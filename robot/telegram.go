@@ -116,6 +116,30 @@ func CreateBot() *tgbotapi.BotAPI {
 			Command:     "mcp",
 			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.mcp.description", nil),
 		},
+		tgbotapi.BotCommand{
+			Command:     "agent",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.agent.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     "sources",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.sources.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     "learn",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.learn.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     "search",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.search.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     "link",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.link.description", nil),
+		},
+		tgbotapi.BotCommand{
+			Command:     "forgetme",
+			Description: i18n.GetMessage(*conf.BaseConfInfo.Lang, "commands.forgetme.description", nil),
+		},
 	)
 	bot.Send(cmdCfg)
 	
@@ -302,7 +326,7 @@ func sleepUtilNoLimit(msgId int, err error) bool {
 // handleCommandAndCallback telegram command and callback function
 func (t *TelegramRobot) handleCommandAndCallback() bool {
 	// if it's command, directly
-	if t.Update.Message != nil && t.Update.Message.IsCommand() {
+	if t.Update.Message != nil && (t.Update.Message.IsCommand() || t.getCaptionCommand() != "") {
 		go t.handleCommand()
 		return true
 	}
@@ -353,13 +377,16 @@ func (t *TelegramRobot) handleCommand() {
 	}()
 	
 	cmd := t.Update.Message.Command()
+	if cmd == "" {
+		cmd = t.getCaptionCommand()
+	}
 	_, _, userID := t.Robot.GetChatIdAndMsgIdAndUserID()
 	logger.Info("command info", "userID", userID, "cmd", cmd)
 	
 	// check if at bot
 	chatType := t.getMessage().Chat.Type
 	if (chatType == "group" || chatType == "supergroup") && *conf.BaseConfInfo.NeedATBOt {
-		if !strings.Contains(t.Update.Message.Text, "@"+t.Bot.Self.UserName) {
+		if !strings.Contains(t.Update.Message.Text+t.Update.Message.Caption, "@"+t.Bot.Self.UserName) {
 			logger.Warn("not at bot", "userID", userID, "cmd", cmd)
 			return
 		}
@@ -519,6 +546,12 @@ func (t *TelegramRobot) handleCallbackQuery() {
 		t.Update.CallbackQuery.Message.MessageID = t.Update.CallbackQuery.Message.ReplyToMessage.MessageID
 	}
 	
+	// search result button reopens record
+	if command, id := ParseCommand(t.Update.CallbackQuery.Data); command == "/reopen" {
+		t.Robot.reopenRecord(id)
+		return
+	}
+	
 	t.Robot.ExecCmd(t.Update.CallbackQuery.Data, t.chooseMode)
 }
 
@@ -623,7 +656,7 @@ func (t *TelegramRobot) sendVideo() {
 		base64Content := base64.StdEncoding.EncodeToString(videoContent)
 		dataURI := fmt.Sprintf("data:video/%s;base64,%s", utils.DetectVideoMimeType(videoContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -738,7 +771,7 @@ func (t *TelegramRobot) sendImg() {
 		base64Content := base64.StdEncoding.EncodeToString(imageContent)
 		dataURI := fmt.Sprintf("data:image/%s;base64,%s", utils.DetectImageFormat(imageContent), base64Content)
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     userId,
 			Question:   prompt,
 			Answer:     dataURI,
@@ -769,6 +802,8 @@ func (t *TelegramRobot) ExecuteForceReply() {
 		t.Robot.sendMultiAgent("task_empty_content", t.sendForceReply("task_empty_content"))
 	case i18n.GetMessage(*conf.BaseConfInfo.Lang, "mcp_empty_content", nil):
 		t.Robot.sendMultiAgent("task_empty_content", t.sendForceReply("mcp_empty_content"))
+	case i18n.GetMessage(*conf.BaseConfInfo.Lang, "agent_empty_content", nil):
+		t.Robot.sendMultiAgent("agent_empty_content", t.sendForceReply("agent_empty_content"))
 	}
 }
 
@@ -857,6 +892,46 @@ func (t *TelegramRobot) GetPhotoContent() []byte {
 	return photoContent
 }
 
+// getCaptionCommand get command from caption of document, like /learn or /learn@botUserName
+func (t *TelegramRobot) getCaptionCommand() string {
+	if t.Update.Message == nil || t.Update.Message.Document == nil {
+		return ""
+	}
+	
+	command, _ := ParseCommand(strings.TrimSpace(t.Update.Message.Caption))
+	command = strings.TrimPrefix(command, "/")
+	return strings.TrimSuffix(command, "@"+t.Bot.Self.UserName)
+}
+
+// getDocument download document of message or the message it replies to
+func (t *TelegramRobot) getDocument() (string, []byte, error) {
+	msg := t.getMessage()
+	if msg == nil {
+		return "", nil, nil
+	}
+	
+	document := msg.Document
+	if document == nil && msg.ReplyToMessage != nil {
+		document = msg.ReplyToMessage.Document
+	}
+	if document == nil {
+		return "", nil, nil
+	}
+	
+	file, err := t.Bot.GetFile(tgbotapi.FileConfig{FileID: document.FileID})
+	if err != nil {
+		logger.Warn("get file fail", "err", err)
+		return "", nil, err
+	}
+	
+	data, err := utils.DownloadFile(file.Link(t.Bot.Token))
+	if err != nil {
+		logger.Warn("download file fail", "err", err)
+		return "", nil, err
+	}
+	return document.FileName, data, nil
+}
+
 func (t *TelegramRobot) getMessage() *tgbotapi.Message {
 	if t.Update.Message != nil {
 		return t.Update.Message
@@ -868,10 +943,8 @@ func (t *TelegramRobot) getMessage() *tgbotapi.Message {
 }
 
 func (t *TelegramRobot) getPrompt() string {
-	prompt := t.getMessage().Text
-	prompt = utils.ReplaceCommand(prompt, "/mcp", t.Bot.Self.UserName)
-	prompt = utils.ReplaceCommand(prompt, "/task", t.Bot.Self.UserName)
-	return prompt
+	return utils.TrimCommand(t.getMessage().Text, t.Bot.Self.UserName,
+		"/mcp", "/task", "/agent", "/learn", "/search", "/reopen", "/link", "/forgetme")
 }
 
 func (t *TelegramRobot) sendForceReply(agentType string) func() {
@@ -76,3 +76,43 @@ func TestSleepUtilNoLimit(t *testing.T) {
 		t.Error("Expected sleepUtilNoLimit to return false on non rate limit error")
 	}
 }
+
+func TestGetCaptionCommand(t *testing.T) {
+	fakeBotUserName := "TestBot"
+	
+	update := makeFakeUpdateWithText("", fakeBotUserName, "private")
+	update.Message.Caption = "/learn@" + fakeBotUserName
+	tel := NewTelegramRobot(update, &tgbotapi.BotAPI{
+		Self: tgbotapi.User{UserName: fakeBotUserName},
+	})
+	if cmd := tel.getCaptionCommand(); cmd != "" {
+		t.Errorf("caption without document should not be command, got %s", cmd)
+	}
+	
+	update.Message.Document = &tgbotapi.Document{FileID: "1", FileName: "a.pdf"}
+	if cmd := tel.getCaptionCommand(); cmd != "learn" {
+		t.Errorf("expected learn command, got %s", cmd)
+	}
+	
+	update.Message.Caption = "please learn it"
+	if cmd := tel.getCaptionCommand(); cmd != "" {
+		t.Errorf("caption without command should be empty, got %s", cmd)
+	}
+}
+
+func TestTelegramGetPrompt(t *testing.T) {
+	tel := NewTelegramRobot(makeFakeUpdateWithText("/learn@TestBot https://x.com/docs/linking", "TestBot", "private"),
+		&tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "TestBot"}})
+	if got := tel.getPrompt(); got != "https://x.com/docs/linking" {
+		t.Errorf("getPrompt() = %q; want url", got)
+	}
+	
+	// bare /forgetme asks for a code, code is passed without command
+	for text, want := range map[string]string{"/forgetme": "", "/forgetme ABC123": "ABC123", "/forgetme@TestBot ABC123": "ABC123"} {
+		tel = NewTelegramRobot(makeFakeUpdateWithText(text, "TestBot", "private"),
+			&tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "TestBot"}})
+		if got := tel.getPrompt(); got != want {
+			t.Errorf("getPrompt(%q) = %q; want %q", text, got, want)
+		}
+	}
+}
@@ -25,6 +25,7 @@ type Web struct {
 	RealUserId string
 	Prompt     string
 	BodyData   []byte
+	FileName   string
 	
 	OriginalPrompt string
 	
@@ -82,6 +83,19 @@ func (web *Web) Exec() {
 		web.sendMultiAgent("task_empty_content")
 	case "/mcp":
 		web.sendMultiAgent("mcp_empty_content")
+	case "/agent":
+		web.sendMultiAgent("agent_empty_content")
+	case "/learn":
+		web.learnDocument()
+	case "/search":
+		msgContent, _ := SearchRecords(web.RealUserId, web.Prompt)
+		web.SendMsg(msgContent)
+	case "/reopen":
+		web.SendMsg(ReopenRecord(web.RealUserId, web.Prompt))
+	case "/link":
+		web.SendMsg(LinkAccount(web.RealUserId, web.Prompt))
+	case "/forgetme":
+		web.SendMsg(ForgetMe(web.RealUserId, web.Prompt))
 	default:
 		web.sendChatMessage()
 	}
@@ -89,7 +103,7 @@ func (web *Web) Exec() {
 
 func (web *Web) sendHelpConfigurationOptions() {
 	web.SendMsg(helpText)
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     helpText,
@@ -107,7 +121,7 @@ func (web *Web) sendModeConfigurationOptions() {
 			param.DeepseekModels[prompt] || param.DeepseekLocalModels[prompt] ||
 			param.OpenRouterModels[prompt] || param.VolModels[prompt] {
 			web.Robot.handleModeUpdate(prompt)
-			db.InsertRecordInfo(&db.Record{
+			db.InsertRecordAsync(&db.Record{
 				UserId:     web.RealUserId,
 				Question:   web.OriginalPrompt,
 				Answer:     i18n.GetMessage(*conf.BaseConfInfo.Lang, "mode_choose", nil) + prompt,
@@ -167,7 +181,7 @@ func (web *Web) sendModeConfigurationOptions() {
 	
 	web.SendMsg(totalContent)
 	
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     totalContent,
@@ -198,7 +212,7 @@ func (web *Web) showBalanceInfo() {
 	
 	web.SendMsg(msgContent)
 	
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     msgContent,
@@ -250,7 +264,7 @@ func (web *Web) showStateInfo() {
 	msgContent := fmt.Sprintf(template, userInfo.Token, todayTokey, weekToken, monthToken)
 	web.SendMsg(msgContent)
 	
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     msgContent,
@@ -267,7 +281,7 @@ func (web *Web) clearAllRecord() {
 	deleteSuccMsg := i18n.GetMessage(*conf.BaseConfInfo.Lang, "delete_succ", nil)
 	web.SendMsg(deleteSuccMsg)
 	
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     deleteSuccMsg,
@@ -279,6 +293,38 @@ func (web *Web) clearAllRecord() {
 	
 }
 
+// learnDocument add uploaded file or url in prompt into knowledge base, file name is file_name param or prompt
+func (web *Web) learnDocument() {
+	if conf.RagConfInfo.Store == nil {
+		web.SendMsg(i18n.GetMessage(*conf.BaseConfInfo.Lang, "rag_not_enable", nil))
+		return
+	}
+	
+	var msgContent string
+	fileName := web.FileName
+	if fileName == "" {
+		fileName = strings.TrimSpace(web.Prompt)
+	}
+	switch {
+	case len(web.BodyData) == 0 && IsLearnURL(fileName):
+		msgContent = LearnURL(web.RealUserId, web.RealUserId, fileName)
+	case len(web.BodyData) == 0:
+		web.SendMsg(i18n.GetMessage(*conf.BaseConfInfo.Lang, "learn_empty_file", nil))
+		return
+	default:
+		msgContent = LearnFile(web.RealUserId, web.RealUserId, fileName, web.BodyData)
+	}
+	web.SendMsg(msgContent)
+	db.InsertRecordAsync(&db.Record{
+		UserId:     web.RealUserId,
+		Question:   web.OriginalPrompt,
+		Answer:     msgContent,
+		Token:      0,
+		IsDeleted:  0,
+		RecordType: param.WEBRecordType,
+	})
+}
+
 func (web *Web) retryLastQuestion() {
 	userId := web.RealUserId
 	
@@ -322,9 +368,12 @@ func (web *Web) sendMultiAgent(agentType string) {
 		}()
 		
 		var err error
-		if agentType == "mcp_empty_content" {
+		switch agentType {
+		case "mcp_empty_content":
 			err = dpReq.ExecuteMcp()
-		} else {
+		case "agent_empty_content":
+			err = dpReq.ExecuteAgent()
+		default:
 			err = dpReq.ExecuteTask()
 		}
 		
@@ -340,7 +389,7 @@ func (web *Web) sendMultiAgent(agentType string) {
 		web.Flusher.Flush()
 	}
 	
-	db.InsertRecordInfo(&db.Record{
+	db.InsertRecordAsync(&db.Record{
 		UserId:     web.RealUserId,
 		Question:   web.OriginalPrompt,
 		Answer:     totalContent,
@@ -415,7 +464,7 @@ func (web *Web) sendImg() {
 		}
 		
 		// save message record
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     web.RealUserId,
 			Question:   web.OriginalPrompt,
 			Answer:     dataURI,
@@ -427,7 +476,7 @@ func (web *Web) sendImg() {
 		})
 		
 		// save data record
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     web.RealUserId,
 			Question:   web.OriginalPrompt,
 			Answer:     dataURI,
@@ -494,7 +543,7 @@ func (web *Web) sendVideo() {
 		fmt.Fprintf(web.W, "%s", dataURI)
 		web.Flusher.Flush()
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     web.RealUserId,
 			Question:   web.OriginalPrompt,
 			Answer:     dataURI,
@@ -504,7 +553,7 @@ func (web *Web) sendVideo() {
 			Mode:       mode,
 		})
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     web.RealUserId,
 			Question:   web.OriginalPrompt,
 			Answer:     dataURI,
@@ -559,7 +608,7 @@ func (web *Web) sendChatMessage() {
 			originDataURI = fmt.Sprintf("data:audio/%s;base64,%s", format, base64Content)
 		}
 		
-		db.InsertRecordInfo(&db.Record{
+		db.InsertRecordAsync(&db.Record{
 			UserId:     web.RealUserId,
 			Question:   web.OriginalPrompt,
 			Answer:     totalContent,
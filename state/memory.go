@@ -0,0 +1,159 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	value    []byte
+	expireAt time.Time
+}
+
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && now.After(i.expireAt)
+}
+
+// Memory state of one process, it is the default store when only one replica runs
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]*memoryItem
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		items: make(map[string]*memoryItem),
+	}
+}
+
+// get return item which isn't expired, caller must hold lock
+func (m *Memory) get(key string, now time.Time) *memoryItem {
+	item, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	if item.expired(now) {
+		delete(m.items, key)
+		return nil
+	}
+	return item
+}
+
+func (m *Memory) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	item := m.get(key, time.Now())
+	if item == nil {
+		return nil, nil
+	}
+	return append([]byte{}, item.value...), nil
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.items[key] = &memoryItem{
+		value:    append([]byte{}, value...),
+		expireAt: expireAt(ttl),
+	}
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	delete(m.items, key)
+	return nil
+}
+
+func (m *Memory) Keys(prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	now := time.Now()
+	keys := make([]string, 0)
+	for key := range m.items {
+		if strings.HasPrefix(key, prefix) && m.get(key, now) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *Memory) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	var value int64
+	item := m.get(key, time.Now())
+	if item != nil {
+		var err error
+		value, err = strconv.ParseInt(string(item.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+	value += delta
+	
+	newItem := &memoryItem{value: []byte(strconv.FormatInt(value, 10))}
+	if ttl > 0 {
+		newItem.expireAt = expireAt(ttl)
+	} else if item != nil {
+		newItem.expireAt = item.expireAt
+	}
+	m.items[key] = newItem
+	return value, nil
+}
+
+func (m *Memory) TryLock(key string, ttl time.Duration) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if m.get(key, time.Now()) != nil {
+		return "", false, nil
+	}
+	token := newToken()
+	m.items[key] = &memoryItem{
+		value:    []byte(token),
+		expireAt: expireAt(ttl),
+	}
+	return token, true, nil
+}
+
+func (m *Memory) Unlock(key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	item := m.get(key, time.Now())
+	if item != nil && string(item.value) == token {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// newToken random token of lock owner
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
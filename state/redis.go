@@ -0,0 +1,123 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisPoolSize    = 10
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 5 * time.Second
+	redisScanCount   = 200
+)
+
+// unlockScript delete lock only if it is held by token, lock may expire and be acquired by another replica
+var unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+
+// Redis state shared by replicas, redis compatible servers like valkey, dragonfly and keydb work too
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(addr, password string, db int, prefix string) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     password,
+			DB:           db,
+			PoolSize:     redisPoolSize,
+			DialTimeout:  redisDialTimeout,
+			ReadTimeout:  redisIOTimeout,
+			WriteTimeout: redisIOTimeout,
+		}),
+		prefix: prefix,
+	}
+}
+
+func (r *Redis) Ping() error {
+	if err := r.client.Ping(context.Background()).Err(); err != nil {
+		return fmt.Errorf("connect redis fail: %w", err)
+	}
+	return nil
+}
+
+func (r *Redis) Get(key string) ([]byte, error) {
+	value, err := r.client.Get(context.Background(), r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(context.Background(), r.prefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(key string) error {
+	return r.client.Del(context.Background(), r.prefix+key).Err()
+}
+
+func (r *Redis) Keys(prefix string) ([]string, error) {
+	ctx := context.Background()
+	keys := make([]string, 0)
+	iter := r.client.Scan(ctx, 0, escapeGlob(r.prefix+prefix)+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), r.prefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Redis) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx := context.Background()
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.IncrBy(ctx, r.prefix+key, delta)
+		if ttl > 0 {
+			pipe.PExpire(ctx, r.prefix+key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *Redis) TryLock(key string, ttl time.Duration) (string, bool, error) {
+	token := newToken()
+	ok, err := r.client.SetNX(context.Background(), r.prefix+key, token, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+	return token, ok, nil
+}
+
+func (r *Redis) Unlock(key, token string) error {
+	return unlockScript.Run(context.Background(), r.client, []string{r.prefix + key}, token).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// escapeGlob escape special characters of redis glob pattern
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
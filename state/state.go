@@ -0,0 +1,92 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+const (
+	MemoryStore = "memory"
+	RedisStore  = "redis"
+	
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+var (
+	LockTimeoutErr = errors.New("wait lock timeout")
+	
+	// Default is used by conversation cache, chat counters and locks, it is replaced by InitState
+	Default Store = NewMemory()
+)
+
+// Store state shared by bot replicas, values are opaque bytes and keys are not prefixed by callers
+type Store interface {
+	// Get return nil if key doesn't exist
+	Get(key string) ([]byte, error)
+	// Set store value, ttl 0 means never expire
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	// Keys return keys starting with prefix
+	Keys(prefix string) ([]string, error)
+	// IncrBy add delta to counter and return new value, expire time of counter is reset if ttl isn't 0
+	IncrBy(key string, delta int64, ttl time.Duration) (int64, error)
+	// TryLock acquire lock without waiting, token is used to release it
+	TryLock(key string, ttl time.Duration) (token string, ok bool, err error)
+	// Unlock release lock if it is still held by token
+	Unlock(key, token string) error
+	Close() error
+}
+
+// InitState create state store of conf, all replicas must use the same redis and prefix
+func InitState() {
+	store, err := NewStore(*conf.StateConfInfo.StateStore)
+	if err != nil {
+		logger.Fatal("init state store fail", "err", err)
+	}
+	Default = store
+	logger.Info("init state store success", "type", *conf.StateConfInfo.StateStore)
+}
+
+// NewStore create store of type
+func NewStore(storeType string) (Store, error) {
+	switch storeType {
+	case "", MemoryStore:
+		return NewMemory(), nil
+	case RedisStore:
+		store := NewRedis(*conf.StateConfInfo.RedisAddr, *conf.StateConfInfo.RedisPassword,
+			*conf.StateConfInfo.RedisDB, *conf.StateConfInfo.StatePrefix)
+		if err := store.Ping(); err != nil {
+			store.Close()
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unsupported state store: %s", storeType)
+	}
+}
+
+// Lock wait until lock of key is acquired, ttl protects lock from replica which crashes before unlock
+func Lock(store Store, key string, ttl, wait time.Duration) (func(), error) {
+	deadline := time.Now().Add(wait)
+	for {
+		token, ok, err := store.TryLock(key, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() {
+				if err := store.Unlock(key, token); err != nil {
+					logger.Error("unlock fail", "key", key, "err", err)
+				}
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, LockTimeoutErr
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"testing"
+	"time"
+	
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	value, err := store.Get("a")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	
+	assert.Nil(t, store.Set("a", []byte("1"), 0))
+	assert.Nil(t, store.Set("ab", []byte("2"), 0))
+	assert.Nil(t, store.Set("b", []byte("3"), 0))
+	value, err = store.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), value)
+	
+	keys, err := store.Keys("a")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "ab"}, keys)
+	
+	assert.Nil(t, store.Delete("a"))
+	value, err = store.Get("a")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	
+	count, err := store.IncrBy("counter", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = store.IncrBy("counter", -1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	
+	token, ok, err := store.TryLock("lock", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, ok, err = store.TryLock("lock", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	
+	// lock is only released by its owner
+	assert.Nil(t, store.Unlock("lock", "other"))
+	_, ok, _ = store.TryLock("lock", time.Minute)
+	assert.False(t, ok)
+	assert.Nil(t, store.Unlock("lock", token))
+	_, ok, _ = store.TryLock("lock", time.Minute)
+	assert.True(t, ok)
+}
+
+func TestMemory(t *testing.T) {
+	store := NewMemory()
+	testStore(t, store)
+	
+	assert.Nil(t, store.Set("expired", []byte("1"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	value, err := store.Get("expired")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("pass")
+	store := NewRedis(server.Addr(), "pass", 1, "test:")
+	defer store.Close()
+	
+	assert.Nil(t, store.Ping())
+	testStore(t, store)
+	
+	// keys are prefixed in redis
+	assert.True(t, server.DB(1).Exists("test:b"))
+	
+	// expire time is set in redis
+	assert.Nil(t, store.Set("expired", []byte("1"), time.Minute))
+	_, err := store.IncrBy("counter", 1, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, server.DB(1).TTL("test:counter"))
+	server.FastForward(2 * time.Minute)
+	value, err := store.Get("expired")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	
+	// error reply doesn't break connection
+	assert.Nil(t, store.Set("text", []byte("x"), 0))
+	_, err = store.IncrBy("text", 1, 0)
+	assert.NotNil(t, err)
+	assert.Nil(t, store.Ping())
+	
+	wrong := NewRedis(server.Addr(), "wrong", 0, "")
+	defer wrong.Close()
+	_, err = wrong.Get("a")
+	assert.NotNil(t, err)
+}
+
+func TestLock(t *testing.T) {
+	store := NewMemory()
+	unlock, err := Lock(store, "lock", time.Minute, time.Second)
+	assert.Nil(t, err)
+	
+	_, err = Lock(store, "lock", time.Minute, 20*time.Millisecond)
+	assert.ErrorIs(t, err, LockTimeoutErr)
+	
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		unlock()
+	}()
+	unlock, err = Lock(store, "lock", time.Minute, time.Second)
+	assert.Nil(t, err)
+	unlock()
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const dataKeyLen = 32
+
+var (
+	KeyNotFoundErr = errors.New("encryption key not found")
+)
+
+// Keyring master keys of envelope encryption, every value is encrypted by a random data key
+// and data key is encrypted by current master key. old master keys are kept to decrypt old values
+type Keyring struct {
+	CurrentId string
+	keys      map[string][]byte
+}
+
+// ParseKeyring parse keys like key2:base64key,key1:base64key, first key is current key.
+// nil is returned if keys is empty
+func ParseKeyring(keys string) (*Keyring, error) {
+	keys = strings.TrimSpace(keys)
+	if keys == "" {
+		return nil, nil
+	}
+	
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range strings.Split(keys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(item), ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid encryption key: %s, use id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("decode encryption key %s fail: %v", id, err)
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 16, 24 or 32 bytes", id)
+		}
+		if _, ok = k.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate encryption key: %s", id)
+		}
+		
+		k.keys[id] = key
+		if k.CurrentId == "" {
+			k.CurrentId = id
+		}
+	}
+	return k, nil
+}
+
+// Seal encrypt plaintexts with a new data key, return ciphertexts, id of master key and encrypted data key
+func (k *Keyring) Seal(plaintexts ...string) ([]string, string, string, error) {
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", "", err
+	}
+	
+	// key id is authenticated so that data key can't be moved to another key id
+	wrappedKey, err := gcmSeal(k.keys[k.CurrentId], dataKey, []byte(k.CurrentId))
+	if err != nil {
+		return nil, "", "", err
+	}
+	
+	ciphertexts := make([]string, len(plaintexts))
+	for i, plaintext := range plaintexts {
+		ciphertexts[i], err = gcmSeal(dataKey, []byte(plaintext), nil)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+	return ciphertexts, k.CurrentId, wrappedKey, nil
+}
+
+// Open decrypt ciphertexts sealed by master key of key id
+func (k *Keyring) Open(keyId, wrappedKey string, ciphertexts ...string) ([]string, error) {
+	masterKey, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", KeyNotFoundErr, keyId)
+	}
+	
+	dataKey, err := gcmOpen(masterKey, wrappedKey, []byte(keyId))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key fail: %v", err)
+	}
+	
+	plaintexts := make([]string, len(ciphertexts))
+	for i, ciphertext := range ciphertexts {
+		plaintext, err := gcmOpen(dataKey, ciphertext, nil)
+		if err != nil {
+			return nil, err
+		}
+		plaintexts[i] = string(plaintext)
+	}
+	return plaintexts, nil
+}
+
+// gcmSeal encrypt plaintext by AES-GCM, return base64 of nonce and ciphertext
+func gcmSeal(key, plaintext, additionalData []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additionalData)), nil
+}
+
+func gcmOpen(key []byte, ciphertext string, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32)))
+	key2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 16)))
+	
+	keyring, err := ParseKeyring("")
+	assert.Nil(t, err)
+	assert.Nil(t, keyring)
+	
+	_, err = ParseKeyring("k1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.NotNil(t, err)
+	_, err = ParseKeyring("k1:" + key1 + ",k1:" + key2)
+	assert.NotNil(t, err)
+	
+	oldKeyring, err := ParseKeyring("k1:" + key1)
+	assert.Nil(t, err)
+	ciphertexts, keyId, dataKey, err := oldKeyring.Seal("question", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", keyId)
+	assert.NotEqual(t, "question", ciphertexts[0])
+	
+	// old values are decrypted after key is rotated
+	keyring, err = ParseKeyring("k2:" + key2 + ", k1:" + key1)
+	assert.Nil(t, err)
+	assert.Equal(t, "k2", keyring.CurrentId)
+	plaintexts, err := keyring.Open(keyId, dataKey, ciphertexts...)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"question", ""}, plaintexts)
+	
+	// data key is bound to key id
+	_, err = keyring.Open("k2", dataKey, ciphertexts...)
+	assert.NotNil(t, err)
+	
+	_, err = keyring.Open("k3", dataKey, ciphertexts...)
+	assert.ErrorIs(t, err, KeyNotFoundErr)
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	
+	"github.com/yincongcyincong/MuseBot/logger"
+)
+
+const (
+	createMigrationTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INT NOT NULL PRIMARY KEY,
+				name VARCHAR(255) NOT NULL DEFAULT '',
+				apply_time BIGINT NOT NULL DEFAULT 0
+			)`
+)
+
+// Migration up migration of schema, migrations of one dialect are applied in order of version
+// and every version is only applied once
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(db *sql.DB) error
+}
+
+// MigrationStatus apply status of migration
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	ApplyTime int64  `json:"apply_time"`
+}
+
+// ExecSQL migration which executes statements in order
+func ExecSQL(statements ...string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		for _, statement := range statements {
+			if _, err := db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AddColumn migration which adds column if table doesn't have it, tables created by old version lack columns
+func AddColumn(dialect, table, column, definition string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		exist, err := ColumnExist(db, dialect, table, column)
+		if err != nil || exist {
+			return err
+		}
+		
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		if err != nil {
+			return fmt.Errorf("add column %s.%s fail: %v", table, column, err)
+		}
+		logger.Info("add column success", "tableName", table, "column", column)
+		return nil
+	}
+}
+
+// DropColumn migration which drops column if table has it
+func DropColumn(dialect, table, column string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		exist, err := ColumnExist(db, dialect, table, column)
+		if err != nil || !exist {
+			return err
+		}
+		
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+		if err != nil {
+			return fmt.Errorf("drop column %s.%s fail: %v", table, column, err)
+		}
+		logger.Info("drop column success", "tableName", table, "column", column)
+		return nil
+	}
+}
+
+// CreateIndex migration which creates index if it doesn't exist, mysql doesn't support CREATE INDEX IF NOT EXISTS
+func CreateIndex(dialect, table, index, columns string, unique bool) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		createSQL := "CREATE INDEX"
+		if unique {
+			createSQL = "CREATE UNIQUE INDEX"
+		}
+		
+		if dialect != Mysql {
+			_, err := db.Exec(fmt.Sprintf("%s IF NOT EXISTS %s ON %s(%s)", createSQL, index, table, columns))
+			return err
+		}
+		
+		var count int
+		err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`,
+			table, index).Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		_, err = db.Exec(fmt.Sprintf("%s %s ON %s(%s)", createSQL, index, table, columns))
+		return err
+	}
+}
+
+// Migrations run migrations one by one
+func Migrations(ups ...func(db *sql.DB) error) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		for _, up := range ups {
+			if err := up(db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ColumnExist check whether table has column
+func ColumnExist(db *sql.DB, dialect, table, column string) (bool, error) {
+	var query string
+	switch dialect {
+	case Sqlite3:
+		query = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	case Mysql:
+		query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+	case Postgres:
+		query = `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?`
+	default:
+		return false, fmt.Errorf("unsupported DBType: %s", dialect)
+	}
+	
+	var count int
+	err := db.QueryRow(query, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("search column fail: %v", err)
+	}
+	return count > 0, nil
+}
+
+// GetMigrationStatus get apply status of every migration ordered by version
+func GetMigrationStatus(db *sql.DB, migrations []*Migration) ([]*MigrationStatus, error) {
+	_, err := db.Exec(createMigrationTableSQL)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations fail: %v", err)
+	}
+	
+	rows, err := db.Query(`SELECT version, apply_time FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	applied := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var applyTime int64
+		if err := rows.Scan(&version, &applyTime); err != nil {
+			return nil, err
+		}
+		applied[version] = applyTime
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	
+	res := make([]*MigrationStatus, 0, len(sorted))
+	for _, m := range sorted {
+		applyTime, ok := applied[m.Version]
+		res = append(res, &MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			ApplyTime: applyTime,
+		})
+	}
+	return res, nil
+}
+
+// RunMigrations apply pending migrations in order of version, return number of applied migrations.
+// It stops at first failed migration, which is applied again next time.
+func RunMigrations(db *sql.DB, migrations []*Migration) (int, error) {
+	status, err := GetMigrationStatus(db, migrations)
+	if err != nil {
+		return 0, err
+	}
+	
+	byVersion := make(map[int]*Migration, len(migrations))
+	for _, m := range migrations {
+		if _, ok := byVersion[m.Version]; ok {
+			return 0, fmt.Errorf("duplicate migration version: %d", m.Version)
+		}
+		byVersion[m.Version] = m
+	}
+	
+	count := 0
+	for _, s := range status {
+		if s.Applied {
+			continue
+		}
+		
+		m := byVersion[s.Version]
+		err = m.Up(db)
+		if err != nil {
+			return count, fmt.Errorf("migration %d %s fail: %w", m.Version, m.Name, err)
+		}
+		
+		_, err = db.Exec(`INSERT INTO schema_migrations (version, name, apply_time) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().Unix())
+		if err != nil {
+			return count, fmt.Errorf("record migration %d fail: %v", m.Version, err)
+		}
+		logger.Info("apply migration success", "version", m.Version, "name", m.Name)
+		count++
+	}
+	
+	return count, nil
+}
+
+// Migrate print migration status, pending migrations are applied first if action is up
+func Migrate(w io.Writer, db *sql.DB, migrations []*Migration, action string) error {
+	switch action {
+	case "status":
+	case "up":
+		count, err := RunMigrations(db, migrations)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "applied %d migrations\n", count)
+	default:
+		return fmt.Errorf("unknown migrate action: %s, use status or up", action)
+	}
+	
+	status, err := GetMigrationStatus(db, migrations)
+	if err != nil {
+		return err
+	}
+	PrintMigrationStatus(w, status)
+	return nil
+}
+
+// PrintMigrationStatus print apply status of migrations
+func PrintMigrationStatus(w io.Writer, status []*MigrationStatus) {
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + time.Unix(s.ApplyTime, 0).Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%04d  %-40s %s\n", s.Version, s.Name, state)
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"testing"
+	
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func openMigrateTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open(Sqlite3, ":memory:")
+	assert.Nil(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRunMigrations(t *testing.T) {
+	db := openMigrateTestDB(t)
+	
+	var order []int
+	record := func(version int, err error) func(db *sql.DB) error {
+		return func(db *sql.DB) error {
+			order = append(order, version)
+			return err
+		}
+	}
+	
+	migrations := []*Migration{
+		{Version: 2, Name: "second", Up: record(2, nil)},
+		{Version: 1, Name: "first", Up: record(1, nil)},
+		{Version: 3, Name: "third", Up: record(3, errors.New("boom"))},
+	}
+	
+	count, err := RunMigrations(db, migrations)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []int{1, 2, 3}, order)
+	
+	status, err := GetMigrationStatus(db, migrations)
+	assert.Nil(t, err)
+	assert.Len(t, status, 3)
+	assert.True(t, status[0].Applied)
+	assert.True(t, status[1].Applied)
+	assert.False(t, status[2].Applied)
+	
+	// failed migration is applied again
+	order = nil
+	migrations[2].Up = record(3, nil)
+	count, err = RunMigrations(db, migrations)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []int{3}, order)
+	
+	_, err = RunMigrations(db, append(migrations, &Migration{Version: 1, Name: "dup", Up: record(1, nil)}))
+	assert.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	db := openMigrateTestDB(t)
+	migrations := []*Migration{
+		{Version: 1, Name: "create_users", Up: ExecSQL(`CREATE TABLE users (id INTEGER PRIMARY KEY)`)},
+	}
+	
+	var buf bytes.Buffer
+	assert.Nil(t, Migrate(&buf, db, migrations, "status"))
+	assert.Contains(t, buf.String(), "0001")
+	assert.Contains(t, buf.String(), "pending")
+	
+	buf.Reset()
+	assert.Nil(t, Migrate(&buf, db, migrations, "up"))
+	assert.Contains(t, buf.String(), "applied 1 migrations")
+	assert.NotContains(t, buf.String(), "pending")
+	
+	assert.NotNil(t, Migrate(&buf, db, migrations, "down"))
+}
+
+func TestColumnMigrations(t *testing.T) {
+	db := openMigrateTestDB(t)
+	_, err := db.Exec(`CREATE TABLE bot (id INTEGER PRIMARY KEY, secret_file TEXT NOT NULL DEFAULT '')`)
+	assert.Nil(t, err)
+	
+	up := Migrations(
+		AddColumn(Sqlite3, "bot", "key_file", "TEXT NOT NULL DEFAULT ''"),
+		AddColumn(Sqlite3, "bot", "key_file", "TEXT NOT NULL DEFAULT ''"),
+		DropColumn(Sqlite3, "bot", "secret_file"),
+		DropColumn(Sqlite3, "bot", "secret_file"),
+		CreateIndex(Sqlite3, "bot", "idx_bot_key_file", "key_file", false),
+		CreateIndex(Sqlite3, "bot", "idx_bot_key_file", "key_file", false),
+	)
+	assert.Nil(t, up(db))
+	
+	exist, err := ColumnExist(db, Sqlite3, "bot", "key_file")
+	assert.Nil(t, err)
+	assert.True(t, exist)
+	
+	exist, err = ColumnExist(db, Sqlite3, "bot", "secret_file")
+	assert.Nil(t, err)
+	assert.False(t, exist)
+	
+	var count int
+	assert.Nil(t, db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_bot_key_file'`).Scan(&count))
+	assert.Equal(t, 1, count)
+	
+	_, err = ColumnExist(db, "oracle", "bot", "key_file")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	Sqlite3  = "sqlite3"
+	Mysql    = "mysql"
+	Postgres = "postgres"
+)
+
+var (
+	PostgresDriverErr = errors.New("postgres driver is not compiled in, build with: go build -tags postgres")
+)
+
+// OpenDB open database of db type, placeholders ? of postgres queries are replaced by $1 $2 ...
+// so that queries can be shared with sqlite3 and mysql
+func OpenDB(dbType, dsn string) (*sql.DB, error) {
+	if dbType != Postgres {
+		return sql.Open(dbType, dsn)
+	}
+	
+	if !slices.Contains(sql.Drivers(), Postgres) {
+		return nil, PostgresDriverErr
+	}
+	return OpenRebindDB(Postgres, dsn)
+}
+
+// OpenRebindDB open database of driver whose queries are rebound to numbered placeholders
+func OpenRebindDB(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+	
+	return sql.OpenDB(&rebindConnector{dsn: dsn, driver: d}), nil
+}
+
+// Rebind replace ? placeholders by $1 $2 ..., question marks in quoted strings and identifiers are kept
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+type rebindConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindConn{Conn: conn}, nil
+}
+
+func (c *rebindConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// rebindConn rebind query of every statement, optional interfaces of inner connection are kept
+type rebindConn struct {
+	driver.Conn
+}
+
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(Rebind(query))
+}
+
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, Rebind(query))
+	}
+	return c.Prepare(query)
+}
+
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		return ec.ExecContext(ctx, Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if qc, ok := c.Conn.(driver.QueryerContext); ok {
+		return qc.QueryContext(ctx, Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *rebindConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *rebindConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (c *rebindConn) ResetSession(ctx context.Context) error {
+	if rc, ok := c.Conn.(driver.SessionResetter); ok {
+		return rc.ResetSession(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+	
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "SELECT 1", Rebind("SELECT 1"))
+	assert.Equal(t, "SELECT id FROM users WHERE user_id = $1 LIMIT $2 OFFSET $3",
+		Rebind("SELECT id FROM users WHERE user_id = ? LIMIT ? OFFSET ?"))
+	assert.Equal(t, `UPDATE bot SET name = '?', address = $1 WHERE "a?" = $2`,
+		Rebind(`UPDATE bot SET name = '?', address = ? WHERE "a?" = ?`))
+	assert.Equal(t, "SELECT '你好' || $1", Rebind("SELECT '你好' || ?"))
+}
+
+func TestOpenRebindDB(t *testing.T) {
+	db, err := OpenRebindDB("sqlite3", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, note TEXT NOT NULL)`)
+	assert.Nil(t, err)
+	
+	var id int64
+	assert.Nil(t, db.QueryRow(`INSERT INTO users (name, note) VALUES (?, '?') RETURNING id`, "a").Scan(&id))
+	assert.Equal(t, int64(1), id)
+	
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	stmt, err := tx.Prepare(`INSERT INTO users (name, note) VALUES (?, ?)`)
+	assert.Nil(t, err)
+	_, err = stmt.Exec("b", "note")
+	assert.Nil(t, err)
+	stmt.Close()
+	assert.Nil(t, tx.Commit())
+	
+	var name, note string
+	assert.Nil(t, db.QueryRow(`SELECT name, note FROM users WHERE id = ? AND name != ?`, 1, "b").Scan(&name, &note))
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "?", note)
+	
+	if !slices.Contains(sql.Drivers(), Postgres) {
+		_, err = OpenDB(Postgres, "postgres://localhost/musebot")
+		assert.ErrorIs(t, err, PostgresDriverErr)
+	}
+}
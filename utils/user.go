@@ -1,32 +1,46 @@
 package utils
 
 import (
-	"sync"
+	"time"
 	
 	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/logger"
+	"github.com/yincongcyincong/MuseBot/state"
 )
 
-var (
-	userChatMap = sync.Map{}
+const (
+	userChatKeyPrefix = "user_chat:"
+	
+	// counter expires if replica crashes before decreasing it, it must outlive the longest chat
+	// because tasks and mcp chats time out after 15 minutes
+	userChatTTL = 30 * time.Minute
 )
 
-func CheckUserChatExceed(userId string) bool {
-	times := 1
-	if timeInter, ok := userChatMap.Load(userId); ok {
-		times = timeInter.(int)
-		if times >= *conf.BaseConfInfo.MaxUserChat {
-			return true
-		}
-		times++
+// CheckUserChatExceed increase chatting count of user and check whether it exceeds max_user_chat,
+// caller must call DecreaseUserChat after chatting if error is nil. count is kept in state store so that
+// it is shared by replicas
+func CheckUserChatExceed(userId string) (bool, error) {
+	times, err := state.Default.IncrBy(userChatKeyPrefix+userId, 1, userChatTTL)
+	if err != nil {
+		logger.Error("increase user chat fail", "userId", userId, "err", err)
+		return false, err
 	}
-	userChatMap.Store(userId, times)
-	return false
+	return times > int64(*conf.BaseConfInfo.MaxUserChat), nil
 }
 
+// DecreaseUserChat decrease chatting count of user and refresh its expire time, count never goes below zero
 func DecreaseUserChat(userId string) {
-	if timeInter, ok := userChatMap.Load(userId); ok {
-		times := timeInter.(int)
-		times--
-		userChatMap.Store(userId, times)
+	key := userChatKeyPrefix + userId
+	times, err := state.Default.IncrBy(key, -1, userChatTTL)
+	if err != nil {
+		logger.Error("decrease user chat fail", "userId", userId, "err", err)
+		return
+	}
+	
+	// counter expired during chatting
+	if times < 0 {
+		if _, err = state.Default.IncrBy(key, -times, userChatTTL); err != nil {
+			logger.Error("reset user chat fail", "userId", userId, "err", err)
+		}
 	}
 }
@@ -2,16 +2,53 @@ package utils
 
 import (
 	"testing"
+	
+	"github.com/stretchr/testify/assert"
+	"github.com/yincongcyincong/MuseBot/conf"
+	"github.com/yincongcyincong/MuseBot/state"
 )
 
 func TestDecreaseUserChat(t *testing.T) {
 	userId := "999999999"
 	// 初始化次数为 3
-	userChatMap.Store(userId, 3)
+	_, err := state.Default.IncrBy(userChatKeyPrefix+userId, 3, 0)
+	assert.Nil(t, err)
 	
 	DecreaseUserChat(userId)
 	
-	if val, ok := userChatMap.Load(userId); !ok || val.(int) != 2 {
-		t.Errorf("Expected times to be 2, got %v", val)
+	times, err := state.Default.IncrBy(userChatKeyPrefix+userId, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), times)
+}
+
+func TestCheckUserChatExceed(t *testing.T) {
+	maxUserChat := 2
+	oldMaxUserChat := conf.BaseConfInfo.MaxUserChat
+	conf.BaseConfInfo.MaxUserChat = &maxUserChat
+	defer func() {
+		conf.BaseConfInfo.MaxUserChat = oldMaxUserChat
+	}()
+	
+	userId := "888888888"
+	for _, want := range []bool{false, false, true} {
+		exceed, err := CheckUserChatExceed(userId)
+		assert.Nil(t, err)
+		assert.Equal(t, want, exceed)
 	}
+	
+	DecreaseUserChat(userId)
+	DecreaseUserChat(userId)
+	exceed, err := CheckUserChatExceed(userId)
+	assert.Nil(t, err)
+	assert.False(t, exceed)
+}
+
+func TestDecreaseUserChatExpired(t *testing.T) {
+	userId := "777777777"
+	
+	// counter expired during chatting, it doesn't go below zero
+	DecreaseUserChat(userId)
+	times, err := state.Default.IncrBy(userChatKeyPrefix+userId, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), times)
 }
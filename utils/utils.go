@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 	"unicode/utf16"
 	
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -47,6 +48,25 @@ func ReplaceCommand(content string, command string, botName string) string {
 	return prompt
 }
 
+// TrimCommand remove mention of bot and leading command token, command inside text like url is kept
+func TrimCommand(content string, botName string, commands ...string) string {
+	if botName != "" {
+		content = strings.ReplaceAll(content, "@"+botName, "")
+	}
+	content = strings.TrimSpace(content)
+	
+	token, rest := content, ""
+	if i := strings.IndexFunc(content, unicode.IsSpace); i >= 0 {
+		token, rest = content[:i], content[i:]
+	}
+	for _, command := range commands {
+		if token == command {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return content
+}
+
 func ForceReply(chatId int64, msgId int, i18MsgId string, bot *tgbotapi.BotAPI) error {
 	msg := tgbotapi.NewMessage(chatId, i18n.GetMessage(*conf.BaseConfInfo.Lang, i18MsgId, nil))
 	msg.ReplyMarkup = tgbotapi.ForceReply{
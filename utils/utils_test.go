@@ -10,7 +10,7 @@ import (
 func TestUtf16len(t *testing.T) {
 	tests := map[string]int{
 		"hello":   5,
-		"你好":    2,
+		"你好":      2,
 		"𠀀":       2, // surrogate pair in utf16
 		"":        0,
 		"abc𠀀def": 8,
@@ -42,6 +42,25 @@ func TestReplaceCommand(t *testing.T) {
 	}
 }
 
+func TestTrimCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"/learn https://x.com/docs/linking", "https://x.com/docs/linking"},
+		{"/learn@botname https://x.com/search/link", "https://x.com/search/link"},
+		{"@botname /search link here", "link here"},
+		{"/search", ""},
+		{"what is /link in https://x.com/search", "what is /link in https://x.com/search"},
+		{"/linkage text", "/linkage text"},
+	}
+	for _, tt := range tests {
+		if got := TrimCommand(tt.content, "botname", "/learn", "/search", "/link"); got != tt.want {
+			t.Errorf("TrimCommand(%q) = %q; want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
 func TestMD5(t *testing.T) {
 	input := "hello world"
 	want := "5eb63bbbe01eeed093cb22bb8f5acdc3"